@@ -19,6 +19,7 @@ import (
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -36,11 +37,19 @@ var activityCmd = &cobra.Command{
 
 		utils.RequireValue("token", localConfig.Token.Value)
 
-		activity, err := http.GetActivityLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, page, number)
+		activity, meta, err := http.GetActivityLogsPage(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, page, number)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
+		if utils.JSONEnvelope {
+			if activity == nil {
+				activity = []models.ActivityLog{}
+			}
+			printer.Envelope(activity, meta)
+			return
+		}
+
 		printer.ActivityLogs(activity, len(activity), jsonFlag)
 	},
 }
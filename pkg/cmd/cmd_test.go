@@ -0,0 +1,123 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/global"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// executeCommand runs the CLI with the args against a test API server, using a temporary config dir with analytics
+// disabled. Commands that fail exit the process, so use executeCommandExit to test failures.
+func executeCommand(t *testing.T, handler http.HandlerFunc, args ...string) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	runCommand(t, server.URL, args)
+}
+
+// executeCommandExit runs the CLI like executeCommand, but in a child process running the current test, so that the
+// command may exit. It returns the command's exit code and stderr. Each test or subtest may only call it once.
+func executeCommandExit(t *testing.T, handler http.HandlerFunc, args ...string) (int, string) {
+	if apiHost := os.Getenv("DOPPLER_TEST_API_HOST"); apiHost != "" {
+		runCommand(t, apiHost, args)
+		os.Exit(0)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var patterns []string
+	for _, name := range strings.Split(t.Name(), "/") {
+		patterns = append(patterns, "^"+regexp.QuoteMeta(name)+"$")
+	}
+	// #nosec G204
+	c := exec.Command(os.Args[0], "-test.run="+strings.Join(patterns, "/"))
+	c.Env = append(os.Environ(), "DOPPLER_TEST_API_HOST="+server.URL)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	err := c.Run()
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return exitError.ExitCode(), stderr.String()
+	}
+	assert.NoError(t, err)
+	return 0, stderr.String()
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+	fn()
+
+	output, err := os.ReadFile(file.Name())
+	assert.NoError(t, err)
+	return string(output)
+}
+
+func runCommand(t *testing.T, apiHost string, args []string) {
+	configDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(configDir, ".doppler.yaml"), []byte("analytics:\n  disable: true\n"), 0600))
+
+	global.WaitGroup = new(sync.WaitGroup)
+	args = append(args, "--api-host", apiHost, "--token", "dp.st.test", "--insecure-allow-plaintext-http",
+		"--config-dir", configDir, "--no-check-version", "--no-read-env", "--silent")
+	rootCmd.SetArgs(args)
+	target, _, err := rootCmd.Find(args)
+	assert.NoError(t, err)
+	defer resetFlags(target)
+
+	assert.NoError(t, rootCmd.Execute())
+	global.WaitGroup.Wait()
+}
+
+// resetFlags restores the flags of the command and its parents to their defaults, so each test starts from a clean state
+func resetFlags(cmd *cobra.Command) {
+	for c := cmd; c != nil; c = c.Parent() {
+		c.Flags().VisitAll(func(flag *pflag.Flag) {
+			if !flag.Changed {
+				return
+			}
+			if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+				sliceValue.Replace(nil) // #nosec G104
+			} else {
+				flag.Value.Set(flag.DefValue) // #nosec G104
+			}
+			flag.Changed = false
+		})
+	}
+}
@@ -28,8 +28,12 @@ import (
 
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
-	Use:       "completion",
-	Short:     "Print shell completion script",
+	Use:   "completion",
+	Short: "Print shell completion script",
+	Long: `Print shell completion script.
+
+Secret names (e.g. for 'doppler secrets get') are only completed when DOPPLER_COMPLETE_SECRETS=1 is set.
+The names are fetched once and cached for the remainder of the shell session.`,
 	ValidArgs: []string{"bash", "zsh", "fish"},
 	Args:      cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
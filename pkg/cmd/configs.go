@@ -18,11 +18,13 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -31,44 +33,125 @@ import (
 var configsCmd = &cobra.Command{
 	Use:   "configs",
 	Short: "Manage configs",
-	Args:  cobra.NoArgs,
-	Run:   configs,
+	Long: `List a project's configs.
+
+By default, configs are printed as a table. Aligning the table requires the width of every row, so nothing is
+printed until the full response has been received, which may take a while for large projects on slow connections.
+
+With --plain, each config is printed as a tab-separated row as soon as it's received. The columns are the same as
+the table's. Rows are only buffered when the configs must be complete before printing: when sorting (--sort,
+--reverse), fetching additional info (--with-counts, --with-author), listing multiple projects, or using
+--print-etag or --json-envelope. If the request fails partway through, the rows printed so far are kept.
+--only-names behaves the same way, but prints just each config's name.
+
+--json output is always buffered.`,
+	Example: `doppler configs --project backend
+doppler configs --environment dev
+doppler configs --projects backend,frontend
+doppler configs --filter 'dev_pr*'
+doppler configs --plain | cut -f1
+for config in $(doppler configs --only-names); do echo "$config"; done`,
+	Args: cobra.NoArgs,
+	Run:  configs,
 }
 
 var configsGetCmd = &cobra.Command{
-	Use:               "get [config]",
-	Short:             "Get info for a config",
-	Args:              cobra.MaximumNArgs(1),
-	ValidArgsFunction: configNamesValidArgs,
+	Use:   "get [config...]",
+	Short: "Get info for one or more configs",
+	Long: `Get info for one or more configs.
+
+When multiple configs are specified, they're fetched in parallel and printed together. A config that can't be
+fetched is reported without affecting the others, unless --fail-fast is specified.`,
+	Example: `doppler configs get dev_personal --project backend
+doppler configs get dev stg prd --project backend
+doppler configs get prd --project backend --field last_fetch_at`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: configNameArgsValidArgs,
 	Run:               getConfigs,
 }
 
+var configsDiffCmd = &cobra.Command{
+	Use:   "diff <from> <to>",
+	Short: "Compare the secrets of two configs",
+	Long: `Compare the secrets of two configs in the same project, listing the secrets that would be added (+),
+changed (~), or removed (-) to make the first config match the second. Raw secret values are compared, and
+Doppler's metadata secrets (e.g. DOPPLER_CONFIG) are ignored. Values are only printed with --reveal.`,
+	Example: `doppler configs diff stg prd --project backend`,
+	Args:    cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= 2 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return configNamesValidArgs(cmd, args, toComplete)
+	},
+	Run: diffConfigs,
+}
+
 var configsCreateCmd = &cobra.Command{
 	Use:   "create [name]",
 	Short: "Create a config",
-	Args:  cobra.MaximumNArgs(1),
-	Run:   createConfigs,
+	Long: `Create a config. The environment is inferred from the name's prefix (e.g. dev_personal is created in the dev
+environment) unless --environment is specified. A name of "-" reads the name from stdin.
+
+With --from-env-file, the config is seeded with the file's secrets. If the secrets can't be saved, the config is
+deleted so that retrying starts clean, unless --keep-on-failure is specified.`,
+	Example: `doppler configs create dev_personal
+doppler configs create ci --environment dev
+NAME=$(doppler configs create ci_$BUILD_ID --environment dev --only-name)
+echo pr_123 | doppler configs create --name -
+doppler configs create dev_personal --from-env-file .env`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  createConfigs,
 }
 
 var configsDeleteCmd = &cobra.Command{
-	Use:               "delete [config]",
-	Short:             "Delete a config",
-	Args:              cobra.MaximumNArgs(1),
-	ValidArgsFunction: configNamesValidArgs,
+	Use:   "delete [config...]",
+	Short: "Delete one or more configs",
+	Long: fmt.Sprintf(`Delete one or more configs.
+
+Locked configs aren't deleted unless --force is specified, in which case they're unlocked first.
+
+When multiple configs are specified, a config that can't be deleted doesn't prevent the others from being deleted.
+The failures are listed once all configs have been processed, and the command exits with code %d if some configs
+were deleted and 1 if none were.`, partialFailureExitCode),
+	Example: `doppler configs delete dev_personal
+doppler configs delete dev_personal --cascade-tokens --yes
+doppler configs delete dev_pr1 dev_pr2 dev_pr3 --yes`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: configNameArgsValidArgs,
 	Run:               deleteConfigs,
 }
 
 var configsUpdateCmd = &cobra.Command{
-	Use:               "update [config]",
-	Short:             "Update a config",
+	Use:   "update [config]",
+	Short: "Update a config",
+	Long: `Update a config's name and/or description.
+
+Pass an empty description to clear it. Configs can also be renamed with 'doppler configs rename'.`,
+	Example: `doppler configs update dev_personal --name dev_jane
+doppler configs update dev_personal --description "Jane's sandbox"
+doppler configs update dev_personal --description ""`,
 	Args:              cobra.MaximumNArgs(1),
-	ValidArgsFunction: configNamesValidArgs,
+	ValidArgsFunction: configNameArgValidArgs,
 	Run:               updateConfigs,
 }
 
+var configsRenameCmd = &cobra.Command{
+	Use:   "rename <config> <name>",
+	Short: "Rename a config",
+	Long: `Rename a config. This is equivalent to 'doppler configs update <config> --name <name>', but fails up front if
+a config with the new name already exists.`,
+	Example: `doppler configs rename dev_personal dev_jane
+doppler configs rename dev_personal dev_jane --project backend --yes`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: configNameArgValidArgs,
+	Run:               renameConfigs,
+}
+
 var configsLockCmd = &cobra.Command{
 	Use:               "lock [config]",
 	Short:             "Lock a config",
+	Example:           `doppler configs lock prd_critical`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: unlockedConfigNamesValidArgs,
 	Run:               lockConfigs,
@@ -77,58 +160,372 @@ var configsLockCmd = &cobra.Command{
 var configsUnlockCmd = &cobra.Command{
 	Use:               "unlock [config]",
 	Short:             "Unlock a config",
+	Example:           `doppler configs unlock prd_critical`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: lockedConfigNamesValidArgs,
 	Run:               unlockConfigs,
 }
 
 var configsCloneCmd = &cobra.Command{
-	Use:               "clone [config]",
-	Short:             "Clone a config",
+	Use:   "clone [config]",
+	Short: "Clone a config",
+	Long: `Clone a config, including its secrets.
+
+The new config is created in the source config's environment, unless --environment specifies another one. The API
+only clones configs within their own environment, so cloning into another environment creates the config and then
+copies the source config's raw secret values into it.`,
+	Example: `doppler configs clone dev --name dev_copy
+doppler configs clone dev --name stg_preview --environment stg`,
 	Args:              cobra.MaximumNArgs(1),
-	ValidArgsFunction: configNamesValidArgs,
+	ValidArgsFunction: configNameArgValidArgs,
 	Run:               cloneConfigs,
 }
 
 func configs(cmd *cobra.Command, args []string) {
-	jsonFlag := utils.OutputJSON
+	format := configsOutputFormat(cmd)
+	jsonFlag := format == printer.JSONConfigsFormat
 	environment := cmd.Flag("environment").Value.String()
 	number := utils.GetIntFlag(cmd, "number", 16)
 	page := utils.GetIntFlag(cmd, "page", 16)
+	deployed := utils.GetBoolFlagIfChanged(cmd, "deployed", false)
+	notDeployed := utils.GetBoolFlagIfChanged(cmd, "not-deployed", false)
+	sortBy := utils.GetFlagIfChanged(cmd, "sort", "")
+	reverse := utils.GetBoolFlagIfChanged(cmd, "reverse", false)
+	withCounts := utils.GetBoolFlagIfChanged(cmd, "with-counts", false)
+	withAuthor := utils.GetBoolFlagIfChanged(cmd, "with-author", false)
+	outputOnError := utils.GetBoolFlagIfChanged(cmd, "output-on-error", false)
+	printETag := utils.GetBoolFlagIfChanged(cmd, "print-etag", false)
+	plain := utils.GetBoolFlagIfChanged(cmd, "plain", false) && !jsonFlag
+	if plain && format == printer.CSVConfigsFormat {
+		utils.HandleError(errors.New("--plain can't be used with --format csv"))
+	}
+	onlyNames := utils.GetBoolFlagIfChanged(cmd, "only-names", false)
+	filter := utils.GetFlagIfChanged(cmd, "filter", "")
+	if filter != "" {
+		if err := controllers.ValidateConfigNamePattern(filter); err != nil {
+			utils.HandleError(err)
+		}
+		if printETag {
+			utils.HandleError(errors.New("--print-etag can't be used with --filter"))
+		}
+	}
+	if onlyNames {
+		if jsonFlag || utils.JSONEnvelope {
+			utils.HandleError(errors.New("--only-names can't be used with --json"))
+		}
+		if format == printer.CSVConfigsFormat {
+			utils.HandleError(errors.New("--only-names can't be used with --format csv"))
+		}
+	}
+	// the summary is informational, so it's omitted along with other info messages
+	summary := !utils.GetBoolFlagIfChanged(cmd, "no-summary", false) && !utils.Silent
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	configs, err := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, environment, page, number)
-	if !err.IsNil() {
-		utils.HandleError(err.Unwrap(), err.Message)
+	if sortBy != "" && !utils.Contains(controllers.ConfigSortOptions, sortBy) {
+		utils.HandleError(fmt.Errorf("invalid sort option %q. Valid options are %v", sortBy, controllers.ConfigSortOptions))
+	}
+
+	// filter and sort the fetched configs before printing
+	filterConfigs := func(configs []models.ConfigInfo) []models.ConfigInfo {
+		if configs == nil {
+			configs = []models.ConfigInfo{}
+		}
+		if deployed || notDeployed {
+			configs = controllers.FilterConfigsByDeployment(configs, deployed)
+		}
+		if filter != "" {
+			configs = controllers.FilterConfigsByName(configs, filter)
+			handleNoFilterMatches(len(configs), filter, "configs")
+			// every config was fetched, so --page and --number apply to the matches
+			start := utils.Min((page-1)*number, len(configs))
+			configs = configs[start:utils.Min(start+number, len(configs))]
+		}
+		if withCounts {
+			for _, err := range controllers.AddSecretCounts(localConfig, configs) {
+				utils.LogWarning(fmt.Sprintf("%s: %s", err.Message, err.Unwrap()))
+			}
+		}
+		if withAuthor {
+			for _, err := range controllers.AddLastModifiedBy(localConfig, configs) {
+				utils.LogWarning(fmt.Sprintf("%s: %s", err.Message, err.Unwrap()))
+			}
+		}
+		controllers.SortConfigs(configs, sortBy)
+		if reverse {
+			for i, j := 0, len(configs)-1; i < j; i, j = i+1, j-1 {
+				configs[i], configs[j] = configs[j], configs[i]
+			}
+		}
+		handleEmptyResult(cmd, len(configs), "configs")
+		return configs
+	}
+
+	var projects []string
+	if cmd.Flags().Changed("projects") {
+		var parseErr error
+		projects, parseErr = cmd.Flags().GetStringSlice("projects")
+		if parseErr != nil {
+			utils.HandleError(parseErr, "Unable to parse --projects flag")
+		}
+	}
+	if utils.GetBoolFlagIfChanged(cmd, "all-projects", false) {
+		var err controllers.Error
+		projects, err = controllers.GetProjectIDs(localConfig)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	}
+
+	if len(projects) > 0 {
+		if printETag {
+			utils.HandleError(errors.New("--print-etag can't be used when listing configs for multiple projects"))
+		}
+
+		perProject := number
+		if filter != "" {
+			// a pattern may match configs on any page
+			perProject = 0
+		}
+		configs, errs := controllers.GetConfigsForProjects(localConfig, projects, environment, page, perProject)
+		if len(errs) == len(projects) && !outputOnError {
+			utils.HandleError(errs[0].Unwrap(), errs[0].Message)
+		}
+		logFailures(errs)
+
+		if utils.JSONEnvelope {
+			printer.Envelope(filterConfigs(configs), models.ListMeta{Page: page})
+		} else if onlyNames {
+			printer.ConfigNames(filterConfigs(configs))
+		} else if plain {
+			printer.ConfigsPlain(filterConfigs(configs))
+		} else if format == printer.CSVConfigsFormat {
+			printer.ConfigsCSV(filterConfigs(configs))
+		} else {
+			stopPager := printer.StartPager()
+			printer.ConfigsInfo(filterConfigs(configs), jsonFlag, summary)
+			stopPager()
+		}
+		handlePartialFailure(cmd, len(errs), len(projects), "projects' configs")
+		return
+	}
+
+	// plain rows are formatted independently, so they're printed as they're received unless every config is needed first
+	if (plain || onlyNames) && !utils.JSONEnvelope && filter == "" && sortBy == "" && !reverse && !withCounts && !withAuthor && !printETag {
+		count := 0
+		err := http.StreamConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, environment, page, number, func(r io.Reader) error {
+			return controllers.DecodeConfigsStream(r, func(info models.ConfigInfo) error {
+				if (deployed || notDeployed) && controllers.IsConfigDeployed(info) != deployed {
+					return nil
+				}
+				count++
+				if onlyNames {
+					utils.Print(info.Name)
+				} else {
+					utils.Print(printer.PlainConfigRow(info, false, false))
+				}
+				return nil
+			})
+		})
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		handleEmptyResult(cmd, count, "configs")
+		return
+	}
+
+	var configs []models.ConfigInfo
+	meta := models.ListMeta{Page: page}
+	if filter != "" {
+		// a pattern may match configs on any page
+		var err controllers.Error
+		configs, err = controllers.GetAllConfigs(localConfig, localConfig.EnclaveProject.Value, environment)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	} else {
+		var err http.Error
+		configs, meta, err = http.GetConfigsPage(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, environment, page, number)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	}
+	if printETag {
+		logETag(meta.ETag)
 	}
 
-	printer.ConfigsInfo(configs, jsonFlag)
+	if utils.JSONEnvelope {
+		printer.Envelope(filterConfigs(configs), meta)
+		return
+	}
+	if onlyNames {
+		printer.ConfigNames(filterConfigs(configs))
+		return
+	}
+	if plain {
+		printer.ConfigsPlain(filterConfigs(configs))
+		return
+	}
+	if format == printer.CSVConfigsFormat {
+		printer.ConfigsCSV(filterConfigs(configs))
+		return
+	}
+
+	stopPager := printer.StartPager()
+	printer.ConfigsInfo(filterConfigs(configs), jsonFlag, summary)
+	stopPager()
 }
 
 func getConfigs(cmd *cobra.Command, args []string) {
-	jsonFlag := utils.OutputJSON
+	format := configsOutputFormat(cmd)
+	jsonFlag := format == printer.JSONConfigsFormat
+	failFast := utils.GetBoolFlagIfChanged(cmd, "fail-fast", false)
+	printETag := utils.GetBoolFlagIfChanged(cmd, "print-etag", false)
+	// the deprecated enclave command doesn't define --field
+	field := utils.GetFlagIfChanged(cmd, "field", "")
+	if field != "" {
+		if jsonFlag || format == printer.CSVConfigsFormat {
+			utils.HandleError(errors.New("--field can't be used with --json or --format"))
+		}
+		if fields := controllers.ConfigInfoFields(); !utils.Contains(fields, field) {
+			utils.HandleError(fmt.Errorf("invalid field %q. Valid fields are %v", field, fields))
+		}
+	}
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	config := localConfig.EnclaveConfig.Value
+	configs := []string{localConfig.EnclaveConfig.Value}
 	if len(args) > 0 {
-		config = args[0]
+		configs = append([]string{}, args...)
 	}
 
-	configInfo, err := http.GetConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	if utils.GetBoolFlagIfChanged(cmd, "fuzzy", false) {
+		names, err := controllers.GetConfigNames(localConfig)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		for i, config := range configs {
+			matches := controllers.MatchConfigNames(config, names)
+			if len(matches) == 0 {
+				utils.HandleError(fmt.Errorf("no configs match %q", config))
+			}
+			if len(matches) > 1 {
+				utils.HandleError(fmt.Errorf("%q matches multiple configs: %s", config, strings.Join(matches, ", ")), "Please specify a more specific name")
+			}
+			configs[i] = matches[0]
+		}
+	}
+
+	if printETag && len(configs) > 1 {
+		utils.HandleError(errors.New("--print-etag can't be used when getting multiple configs"))
+	}
+
+	if len(configs) == 1 {
+		configInfo, etag, err := http.GetConfigWithETag(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, configs[0])
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		if printETag {
+			logETag(etag)
+		}
+
+		if field != "" {
+			printConfigsField([]models.ConfigInfo{configInfo}, field)
+		} else if format == printer.CSVConfigsFormat {
+			printer.ConfigsCSV([]models.ConfigInfo{configInfo})
+		} else {
+			printer.ConfigInfo(configInfo, jsonFlag)
+		}
+		return
+	}
+
+	configInfos, errs := controllers.GetConfigsByName(localConfig, configs, failFast)
+	if len(errs) > 0 && (failFast || len(errs) == len(configs)) && !utils.GetBoolFlagIfChanged(cmd, "output-on-error", false) {
+		utils.HandleError(errs[0].Unwrap(), errs[0].Message)
+	}
+	logFailures(errs)
+
+	if configInfos == nil {
+		configInfos = []models.ConfigInfo{}
+	}
+	if field != "" {
+		printConfigsField(configInfos, field)
+	} else if format == printer.CSVConfigsFormat {
+		printer.ConfigsCSV(configInfos)
+	} else {
+		printer.ConfigsInfo(configInfos, jsonFlag, false)
+	}
+	// with --fail-fast, configs that were skipped also weren't fetched
+	handlePartialFailure(cmd, len(configs)-len(configInfos), len(configs), "configs")
+}
+
+// printConfigsField prints the field of each config on its own line
+func printConfigsField(configs []models.ConfigInfo, field string) {
+	for _, info := range configs {
+		value, err := controllers.ConfigInfoField(info, field)
+		if err != nil {
+			utils.HandleError(err, "Unable to read field")
+		}
+		utils.Print(value)
+	}
+}
+
+// configsOutputFormat resolves the --format flag, which supersedes --json. --json remains an alias for --format json.
+// Errors are printed as JSON only when the format is json.
+func configsOutputFormat(cmd *cobra.Command) string {
+	// the deprecated enclave commands don't define --format
+	format := utils.GetFlagIfChanged(cmd, "format", "")
+	if format == "" {
+		if utils.OutputJSON {
+			return printer.JSONConfigsFormat
+		}
+		return printer.TableConfigsFormat
+	}
+
+	if !utils.Contains(printer.ConfigsFormats, format) {
+		utils.HandleError(fmt.Errorf("invalid format %q. Valid formats are %v", format, printer.ConfigsFormats))
+	}
+	if utils.JSONEnvelope && format != printer.JSONConfigsFormat {
+		utils.HandleError(fmt.Errorf("--json-envelope can't be used with --format %s", format))
+	}
+	utils.OutputJSON = format == printer.JSONConfigsFormat
+	return format
+}
+
+// logETag prints the ETag returned by the API to stderr, where it doesn't interfere with the printed configs.
+// The value is printed as-is so that it can be sent back in an If-None-Match header
+func logETag(etag string) {
+	if etag == "" {
+		utils.LogWarning("The API response doesn't contain an ETag")
+		return
+	}
+	utils.Log(etag)
+}
+
+func diffConfigs(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	reveal := utils.GetBoolFlag(cmd, "reveal")
+	localConfig := configuration.LocalConfig(cmd)
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	changes, err := controllers.DiffConfigSecrets(localConfig, args[0], args[1])
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
-	printer.ConfigInfo(configInfo, jsonFlag)
+	printer.ConfigSecretsDiff(args[0], args[1], changes, reveal, jsonFlag)
 }
 
 func createConfigs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
+	onlyName := utils.GetBoolFlag(cmd, "only-name")
 	environment := cmd.Flag("environment").Value.String()
+	envFile := utils.GetFlagIfChanged(cmd, "from-env-file", "")
+	keepOnFailure := utils.GetBoolFlagIfChanged(cmd, "keep-on-failure", false)
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
@@ -137,17 +534,48 @@ func createConfigs(cmd *cobra.Command, args []string) {
 	if len(args) > 0 {
 		name = args[0]
 	}
+	if name == "-" && envFile == "-" {
+		utils.HandleError(errors.New("the name and --from-env-file can't both be read from stdin"))
+	}
+
+	if name == "-" {
+		stdin, err := utils.GetStdIn()
+		if err != nil {
+			utils.HandleError(err, "Unable to read the config name from stdin")
+		}
+		if stdin == nil {
+			utils.HandleError(errors.New("a name of '-' reads the name from stdin, but no input was piped to stdin"))
+		}
+		name = strings.TrimSpace(*stdin)
+		if strings.ContainsAny(name, "\r\n") {
+			utils.HandleError(errors.New("expected a single config name on stdin, but received multiple lines"))
+		}
+	}
 
 	if name == "" {
 		utils.HandleError(errors.New("you must specify a name"))
 	}
 
-	if environment == "" && strings.Index(name, "_") != -1 {
-		environment = name[0:strings.Index(name, "_")]
+	if environment == "" {
+		environments, err := controllers.GetEnvironmentIDs(localConfig)
+		if err.IsNil() {
+			environment = controllers.InferEnvironment(name, environments)
+		} else if strings.Contains(name, "_") {
+			// unable to validate against the project's environments, so assume the prefix is correct
+			utils.LogDebugError(err.Unwrap())
+			environment = name[0:strings.Index(name, "_")]
+		}
+
+		if environment == "" {
+			utils.HandleError(errors.New("unable to infer the environment from the config name; you must specify an environment"))
+		}
+		utils.LogDebug(fmt.Sprintf("Inferred environment %s", environment))
 	}
 
-	if environment == "" {
-		utils.HandleError(errors.New("you must specify an environment"))
+	// the file is read before creating the config so that an invalid file doesn't leave an empty config behind
+	var seedSecrets map[string]string
+	if envFile != "" {
+		seedSecrets = readConfigSeedFile(cmd, envFile)
 	}
 
 	info, err := http.CreateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, name, environment)
@@ -155,60 +583,268 @@ func createConfigs(cmd *cobra.Command, args []string) {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
-	if !utils.Silent {
+	if len(seedSecrets) > 0 {
+		seedConfig(localConfig, info, seedSecrets, envFile, keepOnFailure)
+	}
+
+	if onlyName {
+		utils.Print(info.Name)
+	} else if !utils.Silent {
 		printer.ConfigInfo(info, jsonFlag)
 	}
+
+	runSuccessHook(cmd, "config.create", info.Project, info.Name, map[string]string{"DOPPLER_ENVIRONMENT": info.Environment})
+}
+
+// readConfigSeedFile reads and parses the env file used to seed a new config. --trim, --dotenv-expand,
+// --allow-duplicates, and --keep-crlf are handled the same way as for an env file passed to 'secrets upload'
+func readConfigSeedFile(cmd *cobra.Command, path string) map[string]string {
+	trim := utils.GetBoolFlagIfChanged(cmd, "trim", true)
+	dotenvExpand := utils.GetBoolFlagIfChanged(cmd, "dotenv-expand", false)
+	allowDuplicates := utils.GetBoolFlagIfChanged(cmd, "allow-duplicates", false)
+	keepCRLF := utils.GetBoolFlagIfChanged(cmd, "keep-crlf", false)
+
+	var file []byte
+	var err error
+	if path == "-" {
+		file, err = readUploadStdin()
+	} else {
+		file, err = readUploadFile(path)
+	}
+	if err != nil {
+		utils.HandleError(err, "Unable to read env file")
+	}
+
+	data := string(file)
+	if !keepCRLF {
+		data = utils.NormalizeLineEndings(data)
+	}
+	values, err := utils.ParseDotEnv(data, dotenvExpand, allowDuplicates)
+	if err != nil {
+		handleEnvFileError(err)
+	}
+	validateSecretNames(cmd, secretNames(values))
+	controllers.RemoveMetadataSecrets(values)
+	if trim {
+		trimSecretValues(values)
+	}
+	return values
+}
+
+// seedConfig saves the secrets to the newly created config. if they can't be saved, the config is deleted (unless
+// keepOnFailure is true) so that retrying doesn't fail because the config already exists
+func seedConfig(localConfig models.ScopedOptions, info models.ConfigInfo, values map[string]string, envFile string, keepOnFailure bool) {
+	secrets := map[string]interface{}{}
+	for name, value := range values {
+		secrets[name] = value
+	}
+
+	_, err := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, info.Name, secrets, nil)
+	if err.IsNil() {
+		return
+	}
+
+	if keepOnFailure {
+		utils.HandleError(err.Unwrap(), err.Message, fmt.Sprintf("Config %s was created without secrets. Upload them with 'doppler secrets upload %s --project %s --config %s'", info.Name, envFile, localConfig.EnclaveProject.Value, info.Name))
+	}
+
+	deleteErr := http.DeleteConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, info.Name)
+	if !deleteErr.IsNil() {
+		utils.LogDebugError(deleteErr.Unwrap())
+		utils.HandleError(err.Unwrap(), err.Message, fmt.Sprintf("Unable to roll back: config %s was created without secrets and couldn't be deleted. Delete it with 'doppler configs delete %s --project %s'", info.Name, info.Name, localConfig.EnclaveProject.Value))
+	}
+	utils.HandleError(err.Unwrap(), err.Message, fmt.Sprintf("Rolled back: deleted config %s, which was created before saving its secrets failed", info.Name))
 }
 
 func deleteConfigs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	yes := utils.GetBoolFlag(cmd, "yes")
+	cascadeTokens := utils.GetBoolFlagIfChanged(cmd, "cascade-tokens", false)
+	// the deprecated enclave command doesn't define --force
+	force := utils.GetBoolFlagIfChanged(cmd, "force", false)
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	config := localConfig.EnclaveConfig.Value
-	if len(args) > 0 {
-		config = args[0]
+	configs := args
+	if len(configs) == 0 {
+		configs = []string{localConfig.EnclaveConfig.Value}
 	}
+	batch := len(configs) > 1
 
-	prompt := "Delete config"
-	if config != "" {
-		prompt = fmt.Sprintf("%s %s", prompt, config)
+	// when deleting a single config, errors are fatal. when deleting multiple, the failures are summarized at the end
+	var failures []controllers.Error
+	fail := func(config string, err error, message string) {
+		if !batch {
+			utils.HandleError(err, message)
+		}
+		failures = append(failures, controllers.Error{Err: err, Message: fmt.Sprintf("Unable to delete config %s", config)})
 	}
 
-	if yes || utils.ConfirmationPrompt(prompt, false) {
-		err := http.DeleteConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	// a config's lock state and service tokens are fetched before prompting, so that the prompt can list them
+	var toDelete []string
+	var toUnlock []string
+	tokens := map[string][]models.ConfigServiceToken{}
+	tokenCount := 0
+	for _, config := range configs {
+		info, err := http.GetConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
 		if !err.IsNil() {
-			utils.HandleError(err.Unwrap(), err.Message)
+			fail(config, err.Unwrap(), err.Message)
+			continue
+		}
+		if info.Locked && !force {
+			if !batch {
+				utils.HandleError(errors.New("config is locked"), "Use --force to unlock and delete it, or unlock it via 'doppler configs unlock'")
+			}
+			fail(config, errors.New("config is locked. Use --force to unlock and delete it"), "")
+			continue
 		}
 
-		if !utils.Silent {
-			configs, err := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, "", 1, 100)
+		// without --cascade-tokens, the tokens are only listed to warn about them, so a token that can't list service
+		// tokens can still delete configs
+		configTokens, err := http.GetConfigServiceTokens(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+		if !err.IsNil() {
+			if cascadeTokens {
+				fail(config, err.Unwrap(), err.Message)
+				continue
+			}
+			utils.LogDebug(fmt.Sprintf("Unable to list the service tokens of config %s", config))
+			utils.LogDebugError(err.Unwrap())
+		}
+
+		if len(configTokens) > 0 && !cascadeTokens {
+			if !batch {
+				utils.HandleError(fmt.Errorf("config has %d service token(s)", len(configTokens)), "Use --cascade-tokens to revoke them along with the config, or revoke them manually via 'doppler configs tokens revoke'")
+			}
+			fail(config, fmt.Errorf("config has %d service token(s). Use --cascade-tokens to revoke them along with the config", len(configTokens)), "")
+			continue
+		}
+
+		toDelete = append(toDelete, config)
+		if info.Locked {
+			toUnlock = append(toUnlock, config)
+		}
+		tokens[config] = configTokens
+		tokenCount += len(configTokens)
+	}
+	logFailures(failures)
+	if len(toDelete) == 0 {
+		utils.HandleError(fmt.Errorf("unable to delete any of the %d configs", len(configs)))
+	}
+
+	prompt := "Delete config"
+	if batch {
+		prompt = fmt.Sprintf("Delete %d configs (%s)", len(toDelete), strings.Join(toDelete, ", "))
+	} else if toDelete[0] != "" {
+		prompt = fmt.Sprintf("%s %s", prompt, toDelete[0])
+	}
+	if tokenCount > 0 {
+		prompt = fmt.Sprintf("%s and revoke %d service token(s)", prompt, tokenCount)
+	}
+	if len(toUnlock) > 0 {
+		prompt = fmt.Sprintf("%s (unlocking %s)", prompt, strings.Join(toUnlock, ", "))
+	}
+
+	if !yes && !utils.ConfirmationPrompt(prompt, false) {
+		return
+	}
+
+	preflightFailures := len(failures)
+	var deleted []string
+	for _, config := range toDelete {
+		revoked := 0
+		for _, token := range tokens[config] {
+			err := http.DeleteConfigServiceToken(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, token.Slug, "")
 			if !err.IsNil() {
-				utils.HandleError(err.Unwrap(), err.Message)
+				fail(config, err.Unwrap(), err.Message)
+				break
+			}
+			revoked++
+		}
+		if revoked > 0 && !utils.Silent {
+			message := fmt.Sprintf("Revoked %d service token(s)", revoked)
+			if batch {
+				message = fmt.Sprintf("%s of config %s", message, config)
 			}
+			utils.Log(message)
+		}
+		if revoked < len(tokens[config]) {
+			continue
+		}
 
-			printer.ConfigsInfo(configs, jsonFlag)
+		err := controllers.DeleteConfig(localConfig, config, utils.Contains(toUnlock, config))
+		if !err.IsNil() {
+			fail(config, err.Unwrap(), err.Message)
+			continue
 		}
+		deleted = append(deleted, config)
+	}
+
+	if !utils.Silent && len(deleted) > 0 {
+		remaining, err := http.GetConfigs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, "", 1, 100)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		printer.ConfigsInfo(remaining, jsonFlag, false)
+	}
+
+	for _, config := range deleted {
+		runSuccessHook(cmd, "config.delete", localConfig.EnclaveProject.Value, config, nil)
+	}
+
+	if !batch {
+		return
+	}
+	logFailures(failures[preflightFailures:])
+	if !utils.Silent && len(deleted) > 0 {
+		utils.Log(fmt.Sprintf("Deleted %d of %d configs: %s", len(deleted), len(configs), strings.Join(deleted, ", ")))
+	}
+	if len(deleted) == 0 {
+		utils.HandleError(fmt.Errorf("unable to delete any of the %d configs", len(configs)))
+	}
+	if len(failures) > 0 {
+		utils.ErrExit(fmt.Errorf("unable to delete %d of %d configs", len(failures), len(configs)), partialFailureExitCode)
 	}
 }
 
 func updateConfigs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	name := cmd.Flag("name").Value.String()
+	updateDescription := cmd.Flags().Changed("description")
+	description := utils.GetFlagIfChanged(cmd, "description", "")
 	yes := utils.GetBoolFlag(cmd, "yes")
+	failIfNoChange := utils.GetBoolFlagIfChanged(cmd, "fail-if-no-change", false)
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
-	utils.RequireValue("name", name)
+	if name == "" && !updateDescription {
+		utils.HandleError(errors.New("you must specify --name and/or --description"))
+	}
 
 	config := localConfig.EnclaveConfig.Value
 	if len(args) > 0 {
 		config = args[0]
 	}
 
-	if !yes {
+	currentInfo, err := http.GetConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+	rename := name != "" && currentInfo.Name != name
+	if !rename && (!updateDescription || currentInfo.Description == description) {
+		message := fmt.Sprintf("Config is already named %s", name)
+		if updateDescription {
+			message = "Config is already up to date"
+		}
+		handleNoChange(failIfNoChange, message)
+		if !utils.Silent {
+			printer.ConfigInfo(currentInfo, jsonFlag)
+		}
+		return
+	}
+
+	if rename && !yes {
 		utils.PrintWarning("Renaming this config may break your current deploys.")
 		if !utils.ConfirmationPrompt("Continue?", false) {
 			utils.Log("Aborting")
@@ -216,14 +852,64 @@ func updateConfigs(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	info, err := http.UpdateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name)
+	if !rename {
+		name = ""
+	}
+	var newDescription *string
+	if updateDescription {
+		newDescription = &description
+	}
+	info, err := http.UpdateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name, newDescription)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	if !utils.Silent {
+		printer.ConfigInfo(info, jsonFlag)
+	}
+
+	runSuccessHook(cmd, "config.update", info.Project, info.Name, map[string]string{"DOPPLER_PREVIOUS_CONFIG": config})
+}
+
+func renameConfigs(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	yes := utils.GetBoolFlag(cmd, "yes")
+	localConfig := configuration.LocalConfig(cmd)
+	config, name := args[0], args[1]
+
+	utils.RequireValue("token", localConfig.Token.Value)
+	utils.RequireValue("name", name)
+	if name == config {
+		utils.HandleError(fmt.Errorf("config is already named %s", name))
+	}
+
+	// checked up front so that a duplicate name results in a clear error
+	exists, err := controllers.ConfigExists(localConfig, name)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
+	if exists {
+		utils.HandleError(fmt.Errorf("config %s already exists. Choose another name, or delete the existing config first", name))
+	}
+
+	if !yes {
+		utils.PrintWarning("Renaming this config may break your current deploys.")
+		if !utils.ConfirmationPrompt("Continue?", false) {
+			utils.Log("Aborting")
+			return
+		}
+	}
+
+	info, httpErr := http.UpdateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name, nil)
+	if !httpErr.IsNil() {
+		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
+	}
 
 	if !utils.Silent {
 		printer.ConfigInfo(info, jsonFlag)
 	}
+
+	runSuccessHook(cmd, "config.update", info.Project, info.Name, map[string]string{"DOPPLER_PREVIOUS_CONFIG": config})
 }
 
 func lockConfigs(cmd *cobra.Command, args []string) {
@@ -252,6 +938,8 @@ func lockConfigs(cmd *cobra.Command, args []string) {
 		if !utils.Silent {
 			printer.ConfigInfo(configInfo, jsonFlag)
 		}
+
+		runSuccessHook(cmd, "config.lock", configInfo.Project, configInfo.Name, nil)
 	}
 }
 
@@ -281,6 +969,8 @@ func unlockConfigs(cmd *cobra.Command, args []string) {
 		if !utils.Silent {
 			printer.ConfigInfo(configInfo, jsonFlag)
 		}
+
+		runSuccessHook(cmd, "config.unlock", configInfo.Project, configInfo.Name, nil)
 	}
 }
 
@@ -288,6 +978,7 @@ func cloneConfigs(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	localConfig := configuration.LocalConfig(cmd)
 	name := cmd.Flag("name").Value.String()
+	environment := cmd.Flag("environment").Value.String()
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
@@ -296,61 +987,119 @@ func cloneConfigs(cmd *cobra.Command, args []string) {
 		config = args[0]
 	}
 
-	configInfo, err := http.CloneConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name)
-	if !err.IsNil() {
-		utils.HandleError(err.Unwrap(), err.Message)
+	// checked up front so that a clone into another environment doesn't fail after fetching the source's secrets
+	if name != "" {
+		names, err := controllers.GetConfigNames(localConfig)
+		if !err.IsNil() {
+			utils.LogDebugError(err.Unwrap())
+		} else if utils.Contains(names, name) {
+			utils.HandleError(fmt.Errorf("config %s already exists", name))
+		}
+	}
+
+	var configInfo models.ConfigInfo
+	if environment == "" {
+		var err http.Error
+		configInfo, err = http.CloneConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	} else {
+		configInfo = cloneConfigToEnvironment(localConfig, config, name, environment)
 	}
 
 	if !utils.Silent {
 		printer.ConfigInfo(configInfo, jsonFlag)
 	}
+
+	runSuccessHook(cmd, "config.clone", configInfo.Project, configInfo.Name, map[string]string{"DOPPLER_SOURCE_CONFIG": config})
 }
 
-func configNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	persistentValidArgsFunction(cmd)
+// cloneConfigToEnvironment clones the config into the specified environment. the API only clones configs within their
+// own environment, so a clone into another environment is created and then seeded with the source's secrets
+func cloneConfigToEnvironment(localConfig models.ScopedOptions, config string, name string, environment string) models.ConfigInfo {
+	source, err := http.GetConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+	if source.Environment == environment {
+		configInfo, err := http.CloneConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, config, name)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		return configInfo
+	}
 
-	localConfig := configuration.LocalConfig(cmd)
-	names, err := controllers.GetConfigNames(localConfig)
-	if err.IsNil() {
-		return names, cobra.ShellCompDirectiveNoFileComp
+	scoped := localConfig
+	scoped.EnclaveConfig.Value = config
+	values, unreadable, secretsErr := controllers.GetRawSecretValues(scoped)
+	if !secretsErr.IsNil() {
+		utils.HandleError(secretsErr.Unwrap(), secretsErr.Message)
+	}
+	if len(unreadable) > 0 {
+		utils.HandleError(fmt.Errorf("unable to read the values of these secrets: %s", strings.Join(unreadable, ", ")), fmt.Sprintf("Unable to clone config %s", config))
+	}
+
+	configInfo, err := http.CreateConfig(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, name, environment)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	if len(values) > 0 {
+		// an incomplete clone is deleted so that retrying doesn't fail because the config already exists
+		seedConfig(localConfig, configInfo, values, "", false)
 	}
-	return nil, cobra.ShellCompDirectiveNoFileComp
+	return configInfo
 }
 
-func lockedConfigNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	persistentValidArgsFunction(cmd)
+func configNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return configNameCompletions(cmd, nil, func(models.ConfigInfo) bool { return true }), cobra.ShellCompDirectiveNoFileComp
+}
 
-	localConfig := configuration.LocalConfig(cmd)
-	configs, err := controllers.GetConfigs(localConfig)
-	if !err.IsNil() {
+// configNameArgsValidArgs completes the config names of commands that accept multiple configs, omitting the configs
+// that were already specified
+func configNameArgsValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return configNameCompletions(cmd, args, func(models.ConfigInfo) bool { return true }), cobra.ShellCompDirectiveNoFileComp
+}
+
+// configNameArgValidArgs completes the config name of commands that accept a single config
+func configNameArgValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
+	return configNamesValidArgs(cmd, args, toComplete)
+}
 
-	var names []string
-	for _, config := range configs {
-		if config.Locked {
-			names = append(names, config.Name)
-		}
-	}
-	return names, cobra.ShellCompDirectiveNoFileComp
+func lockedConfigNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return configNameCompletions(cmd, nil, func(config models.ConfigInfo) bool { return config.Locked }), cobra.ShellCompDirectiveNoFileComp
 }
 
 func unlockedConfigNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return configNameCompletions(cmd, nil, func(config models.ConfigInfo) bool { return !config.Locked }), cobra.ShellCompDirectiveNoFileComp
+}
+
+// configNameCompletions returns the names of the project's configs that satisfy include, other than those in exclude.
+// Completion is best effort, so no names are returned if there's no token or the configs can't be fetched.
+func configNameCompletions(cmd *cobra.Command, exclude []string, include func(models.ConfigInfo) bool) []string {
 	persistentValidArgsFunction(cmd)
 
 	localConfig := configuration.LocalConfig(cmd)
+	// fetching configs without a token exits with an error, which the shell would display
+	if strings.TrimSpace(localConfig.Token.Value) == "" {
+		return nil
+	}
 	configs, err := controllers.GetConfigs(localConfig)
 	if !err.IsNil() {
-		return nil, cobra.ShellCompDirectiveNoFileComp
+		return nil
 	}
 
 	var names []string
 	for _, config := range configs {
-		if !config.Locked {
+		if include(config) && !utils.Contains(exclude, config.Name) {
 			names = append(names, config.Name)
 		}
 	}
-	return names, cobra.ShellCompDirectiveNoFileComp
+	return names
 }
 
 func init() {
@@ -360,18 +1109,66 @@ func init() {
 	configsCmd.RegisterFlagCompletionFunc("environment", configEnvironmentIDsValidArgs)
 	configsCmd.Flags().IntP("number", "n", 100, "max number of configs to display")
 	configsCmd.Flags().Int("page", 1, "page to display")
+	configsCmd.Flags().StringSlice("projects", []string{}, "list configs for multiple projects (e.g. backend,frontend)")
+	configsCmd.RegisterFlagCompletionFunc("projects", projectIDsValidArgs)
+	configsCmd.Flags().Bool("all-projects", false, "list configs for all projects")
+	configsCmd.Flags().Bool("output-on-error", false, fmt.Sprintf("when listing configs for multiple projects, print the configs that were fetched even if some projects failed, then exit with code %d", partialFailureExitCode))
+	configsCmd.MarkFlagsMutuallyExclusive("project", "projects", "all-projects")
+	configsCmd.Flags().Bool("deployed", false, "only show configs that have been deployed (i.e. had their secrets fetched)")
+	configsCmd.Flags().Bool("not-deployed", false, "only show configs that have never been deployed")
+	configsCmd.MarkFlagsMutuallyExclusive("deployed", "not-deployed")
+	configsCmd.Flags().String("sort", "", fmt.Sprintf("sort configs by field. one of %v. sorting by deployed lists never-deployed configs first, followed by the least recently deployed", controllers.ConfigSortOptions))
+	configsCmd.Flags().Bool("reverse", false, "reverse the order of the configs")
+	configsCmd.Flags().Bool("with-counts", false, "include the number of secrets in each config. this requires an additional request per config")
+	configsCmd.Flags().Bool("with-author", false, "include the user who last modified each config. this requires an additional request per config")
+	configsCmd.Flags().String("format", "", fmt.Sprintf("output format. one of %v. supersedes --json, which is equivalent to --format json", printer.ConfigsFormats))
+	configsCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return printer.ConfigsFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	configsCmd.Flags().Bool("plain", false, "print each config as a tab-separated row without a header or summary. rows are printed as they're received unless the configs must be sorted or enriched first")
+	configsCmd.Flags().Bool("only-names", false, "only print the config names, one per line. like --plain, names are printed as they're received when possible")
+	configsCmd.MarkFlagsMutuallyExclusive("plain", "only-names")
+	configsCmd.Flags().Bool("no-summary", false, "don't print the summary line (e.g. \"6 configs (3 dev, 1 stg, 2 prd)\") after the table")
+	configsCmd.Flags().Bool("print-etag", false, "print the ETag returned by the API to stderr, e.g. to detect whether the configs changed since the last run")
+	configsCmd.Flags().String("filter", "", fmt.Sprintf("only show configs whose names match this glob pattern (e.g. 'dev_*'). * matches any characters and ? matches a single character. all configs are fetched and --page and --number apply to the matches. exits with code %d if no configs match", noFilterMatchExitCode))
+	configsCmd.Flags().Bool("fail-empty", false, fmt.Sprintf("exit with code %d if no configs are found (e.g. because of the filters)", emptyResultExitCode))
 
 	configsGetCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsGetCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	configsGetCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsGetCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	configsGetCmd.Flags().Bool("fuzzy", false, "match a partial config name. fails if the name matches multiple configs")
+	configsGetCmd.Flags().String("format", "", fmt.Sprintf("output format. one of %v. supersedes --json, which is equivalent to --format json", printer.ConfigsFormats))
+	configsGetCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return printer.ConfigsFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	configsGetCmd.Flags().String("field", "", "only print this field of the config (e.g. created_at), without formatting. fields are named as in --json output. when getting multiple configs, each config's value is printed on its own line")
+	configsGetCmd.RegisterFlagCompletionFunc("field", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return controllers.ConfigInfoFields(), cobra.ShellCompDirectiveNoFileComp
+	})
+	configsGetCmd.Flags().Bool("print-etag", false, "print the ETag returned by the API to stderr, e.g. to detect whether the config changed since the last run")
+	configsGetCmd.Flags().Bool("fail-fast", false, "when getting multiple configs, exit as soon as any config can't be fetched")
+	configsGetCmd.Flags().Bool("output-on-error", false, fmt.Sprintf("when getting multiple configs, print the configs that were fetched even if some failed, then exit with code %d", partialFailureExitCode))
 	configsCmd.AddCommand(configsGetCmd)
 
+	configsDiffCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	configsDiffCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	configsDiffCmd.Flags().Bool("reveal", false, "include the secrets' values")
+	configsCmd.AddCommand(configsDiffCmd)
+
 	configsCreateCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsCreateCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
-	configsCreateCmd.Flags().String("name", "", "config name")
+	configsCreateCmd.Flags().String("name", "", "config name. use '-' to read the name from stdin")
 	configsCreateCmd.Flags().StringP("environment", "e", "", "config environment")
 	configsCreateCmd.RegisterFlagCompletionFunc("environment", configEnvironmentIDsValidArgs)
+	configsCreateCmd.Flags().Bool("only-name", false, "print only the created config's name, even when --silent is specified")
+	configsCreateCmd.Flags().String("from-env-file", "", "seed the config with the secrets in this env file. use '-' to read the file from stdin")
+	configsCreateCmd.Flags().Bool("keep-on-failure", false, "keep the created config if saving the secrets from --from-env-file fails. by default it's deleted")
+	configsCreateCmd.Flags().Bool("force", false, "save the secrets from --from-env-file even if their names are invalid")
+	configsCreateCmd.Flags().Bool("trim", true, "strip leading and trailing whitespace from --from-env-file values, including quoted values. use --trim=false to save them exactly as written")
+	configsCreateCmd.Flags().Bool("dotenv-expand", false, "resolve ${NAME} references in --from-env-file to values defined earlier in the file")
+	configsCreateCmd.Flags().Bool("allow-duplicates", false, "allow --from-env-file to define a key more than once, using the last value. by default duplicate keys are an error")
+	configsCreateCmd.Flags().Bool("keep-crlf", false, "preserve Windows line endings (CRLF) in --from-env-file. by default they're converted to LF")
 	configsCmd.AddCommand(configsCreateCmd)
 
 	configsUpdateCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
@@ -379,10 +1176,9 @@ func init() {
 	configsUpdateCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsUpdateCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsUpdateCmd.Flags().String("name", "", "config name")
-	if err := configsUpdateCmd.MarkFlagRequired("name"); err != nil {
-		utils.HandleError(err)
-	}
+	configsUpdateCmd.Flags().String("description", "", "config description. pass an empty string to clear it")
 	configsUpdateCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	configsUpdateCmd.Flags().Bool("fail-if-no-change", false, fmt.Sprintf("exit with code %d if the config already has the specified name and description", noChangeExitCode))
 	configsCmd.AddCommand(configsUpdateCmd)
 
 	configsDeleteCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
@@ -390,19 +1186,26 @@ func init() {
 	configsDeleteCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsDeleteCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsDeleteCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	configsDeleteCmd.Flags().Bool("cascade-tokens", false, "revoke the config's service tokens before deleting it")
+	configsDeleteCmd.Flags().Bool("force", false, "delete the config even if it's locked, by unlocking it first")
 	configsCmd.AddCommand(configsDeleteCmd)
 
+	configsRenameCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	configsRenameCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	configsRenameCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	configsCmd.AddCommand(configsRenameCmd)
+
 	configsLockCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsLockCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	configsLockCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
-	configsLockCmd.RegisterFlagCompletionFunc("config", lockedConfigNamesValidArgs)
+	configsLockCmd.RegisterFlagCompletionFunc("config", unlockedConfigNamesValidArgs)
 	configsLockCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	configsCmd.AddCommand(configsLockCmd)
 
 	configsUnlockCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsUnlockCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	configsUnlockCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
-	configsUnlockCmd.RegisterFlagCompletionFunc("config", unlockedConfigNamesValidArgs)
+	configsUnlockCmd.RegisterFlagCompletionFunc("config", lockedConfigNamesValidArgs)
 	configsUnlockCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	configsCmd.AddCommand(configsUnlockCmd)
 
@@ -411,6 +1214,8 @@ func init() {
 	configsCloneCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsCloneCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsCloneCmd.Flags().String("name", "", "new config name")
+	configsCloneCmd.Flags().StringP("environment", "e", "", "environment of the new config. defaults to the source config's environment")
+	configsCloneCmd.RegisterFlagCompletionFunc("environment", configEnvironmentIDsValidArgs)
 	configsCmd.AddCommand(configsCloneCmd)
 
 	rootCmd.AddCommand(configsCmd)
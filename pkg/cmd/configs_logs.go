@@ -16,9 +16,16 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -27,21 +34,64 @@ import (
 var configsLogsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "List config audit logs",
-	Args:  cobra.NoArgs,
-	Run:   configsLogs,
+	Example: `doppler configs logs --config dev
+doppler configs logs --config dev --sort user --number 50
+doppler configs logs --config dev --abbrev
+doppler configs logs --config prd --actor jane@example.com
+doppler configs logs --config prd --all --max 10000 --json > prd-audit.json`,
+	Args: cobra.NoArgs,
+	Run:  configsLogs,
 }
 
 var configsLogsGetCmd = &cobra.Command{
-	Use:               "get [log_id]",
-	Short:             "Get config audit log",
+	Use:   "get [log_id]",
+	Short: "Get config audit log",
+	Long: `Get config audit log.
+
+The log ID may be abbreviated to any prefix that uniquely identifies the log (e.g. as displayed by 'doppler configs logs --abbrev').`,
+	Example:           `doppler configs logs get LOG_ID --config dev`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: configLogIDsValidArgs,
 	Run:               getConfigsLogs,
 }
 
+var configsLogsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export config audit logs for ingestion by a SIEM",
+	Long: `Export config audit logs for ingestion by a SIEM (e.g. Splunk or ELK), printing one event per line, oldest first.
+
+Formats:
+  ndjson  newline-delimited JSON objects with the fields id, timestamp, actor, action, project, environment,
+          config, message, and diff
+  cef     ArcSight Common Event Format
+
+Each event's diff lists the names of the secrets that were added, changed, or removed. Secret values are never exported.
+--since and --until accept an RFC 3339 time (e.g. 2024-01-02T15:04:05Z), a date (e.g. 2024-01-02), or a duration
+ago (e.g. 24h).`,
+	Example: `doppler configs logs export --config prd --format ndjson --since 24h >> doppler-audit.ndjson
+doppler configs logs export --config prd --format cef --since 2024-01-01 --until 2024-02-01
+doppler configs logs export --config prd --since 168h --actor jane@example.com`,
+	Args: cobra.NoArgs,
+	Run:  exportConfigsLogs,
+}
+
+var configsLogsUndoCmd = &cobra.Command{
+	Use:     "undo",
+	Short:   "Rollback the most recent config change",
+	Example: `doppler configs logs undo --config dev`,
+	Args:    cobra.NoArgs,
+	Run:     undoConfigsLogs,
+}
+
 var configsLogsRollbackCmd = &cobra.Command{
-	Use:               "rollback [log_id]",
-	Short:             "Rollback a config change",
+	Use:   "rollback [log_id]",
+	Short: "Rollback a config change",
+	Long: `Rollback a config change
+
+Instead of a log id, --before rolls back to the most recent log created at or before the specified time. It accepts
+an RFC 3339 time (e.g. 2024-01-02T15:04:05Z), a date (e.g. 2024-01-02), or a duration (e.g. 24h, meaning 24 hours ago).`,
+	Example: `doppler configs logs rollback LOG_ID --config dev
+doppler configs logs rollback --before 2024-01-02T15:04:05Z --config dev`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: configLogIDsValidArgs,
 	Run:               rollbackConfigsLogs,
@@ -55,12 +105,106 @@ func configsLogs(cmd *cobra.Command, args []string) {
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	logs, err := http.GetConfigLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, page, number)
-	if !err.IsNil() {
-		utils.HandleError(err.Unwrap(), err.Message)
+	sortBy := utils.GetFlagIfChanged(cmd, "sort", "")
+	reverse := utils.GetBoolFlagIfChanged(cmd, "reverse", false)
+	if sortBy != "" && !utils.Contains(configLogSortOptions, sortBy) {
+		utils.HandleError(fmt.Errorf("invalid sort option %q. Valid options are %v", sortBy, configLogSortOptions))
+	}
+	// sorting applies to all of the logs, so they're all fetched before --page and --number select the logs to display
+	sorting := sortBy != "" || reverse
+
+	// the deprecated enclave command doesn't define --all or --max
+	all := utils.GetBoolFlagIfChanged(cmd, "all", false)
+	max := utils.GetIntFlagIfChanged(cmd, "max", 16, 0)
+	if cmd.Flags().Changed("max") && !all {
+		utils.HandleError(errors.New("--max can only be used with --all"))
+	}
+	if max < 0 {
+		utils.HandleError(errors.New("--max must not be negative"))
+	}
+
+	var logs []models.ConfigLog
+	var meta models.ListMeta
+	if all {
+		for _, flag := range []string{"page", "number", "actor"} {
+			if cmd.Flags().Changed(flag) {
+				utils.HandleError(fmt.Errorf("--%s can't be used with --all", flag))
+			}
+		}
+		var err controllers.Error
+		logs, err = controllers.GetAllConfigLogs(localConfig, max)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		meta = models.ListMeta{Page: 1}
+	} else if actor := utils.GetFlagIfChanged(cmd, "actor", ""); actor != "" {
+		// the matching logs are collected from as many pages as necessary, so there's no page to select
+		if cmd.Flags().Changed("page") {
+			utils.HandleError(errors.New("--page can't be used with --actor"))
+		}
+		limit := number
+		if sorting {
+			limit = 0
+		}
+		var err controllers.Error
+		logs, err = controllers.GetConfigLogsByActor(localConfig, actor, limit)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		page = 1
+		meta = models.ListMeta{Page: 1}
+	} else if sorting {
+		var err controllers.Error
+		logs, err = controllers.GetAllConfigLogs(localConfig, 0)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		meta = models.ListMeta{Page: page}
+	} else {
+		var err http.Error
+		logs, meta, err = http.GetConfigLogsPage(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, page, number)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+	}
+
+	if sorting {
+		sortConfigLogs(logs, sortBy)
+		if reverse {
+			for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+				logs[i], logs[j] = logs[j], logs[i]
+			}
+		}
+		if !all {
+			start := utils.Min((page-1)*number, len(logs))
+			logs = logs[start:utils.Min(start+number, len(logs))]
+		}
 	}
+	handleEmptyResult(cmd, len(logs), "logs")
 
+	// abbreviated IDs are for display, so machine-readable output always includes the full IDs
+	if utils.GetBoolFlagIfChanged(cmd, "abbrev", false) && !jsonFlag && !utils.JSONEnvelope {
+		var ids []string
+		for _, log := range logs {
+			ids = append(ids, log.ID)
+		}
+		abbreviations := utils.AbbreviateIDs(ids, utils.MinAbbrevLength)
+		for i := range logs {
+			logs[i].ID = abbreviations[logs[i].ID]
+		}
+	}
+
+	if utils.JSONEnvelope {
+		if logs == nil {
+			logs = []models.ConfigLog{}
+		}
+		printer.Envelope(logs, meta)
+		return
+	}
+
+	stopPager := printer.StartPager()
 	printer.ConfigLogs(logs, len(logs), jsonFlag)
+	stopPager()
 }
 
 func getConfigsLogs(cmd *cobra.Command, args []string) {
@@ -75,12 +219,71 @@ func getConfigsLogs(cmd *cobra.Command, args []string) {
 	}
 	utils.RequireValue("log", log)
 
+	log, resolveErr := controllers.ResolveConfigLogID(localConfig, log)
+	if !resolveErr.IsNil() {
+		utils.HandleError(resolveErr.Unwrap(), resolveErr.Message)
+	}
+
 	configLog, err := http.GetConfigLog(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
+	stopPager := printer.StartPager()
 	printer.ConfigLog(configLog, jsonFlag, true)
+	stopPager()
+}
+
+func exportConfigsLogs(cmd *cobra.Command, args []string) {
+	format := cmd.Flag("format").Value.String()
+	localConfig := configuration.LocalConfig(cmd)
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	if !utils.Contains(controllers.AuditFormats, format) {
+		utils.HandleError(fmt.Errorf("invalid format %q. Valid formats are %v", format, controllers.AuditFormats))
+	}
+
+	now := time.Now()
+	var since, until time.Time
+	if value := cmd.Flag("since").Value.String(); value != "" {
+		var err error
+		if since, err = utils.ParseTimeBound(value, now, utils.TimeZone); err != nil {
+			utils.HandleError(err, "Invalid --since")
+		}
+	}
+	if value := cmd.Flag("until").Value.String(); value != "" {
+		var err error
+		if until, err = utils.ParseTimeBound(value, now, utils.TimeZone); err != nil {
+			utils.HandleError(err, "Invalid --until")
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		utils.HandleError(errors.New("--until must not be before --since"))
+	}
+
+	logs, err := controllers.GetConfigLogsBetween(localConfig, since, until)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+	if actor := cmd.Flag("actor").Value.String(); actor != "" {
+		var actorLogs []models.ConfigLog
+		for _, log := range logs {
+			if controllers.ConfigLogMatchesActor(log, actor) {
+				actorLogs = append(actorLogs, log)
+			}
+		}
+		logs = actorLogs
+	}
+
+	// logs are fetched newest first, while SIEMs expect events in chronological order
+	for i := len(logs) - 1; i >= 0; i-- {
+		line, formatErr := controllers.FormatAuditEvent(controllers.NewAuditEvent(logs[i]), format)
+		if formatErr != nil {
+			utils.HandleError(formatErr, "Unable to format audit event")
+		}
+		utils.Print(line)
+	}
 }
 
 func rollbackConfigsLogs(cmd *cobra.Command, args []string) {
@@ -93,8 +296,60 @@ func rollbackConfigsLogs(cmd *cobra.Command, args []string) {
 	if len(args) > 0 {
 		log = args[0]
 	}
+
+	// the deprecated enclave command doesn't define --before
+	if before := utils.GetFlagIfChanged(cmd, "before", ""); before != "" {
+		if log != "" {
+			utils.HandleError(errors.New("--before can't be used with a log id"))
+		}
+
+		beforeTime, parseErr := utils.ParseTimeBound(before, time.Now(), utils.TimeZone)
+		if parseErr != nil {
+			utils.HandleError(parseErr, "Invalid --before")
+		}
+
+		configLog, found, err := controllers.GetLatestConfigLogBefore(localConfig, beforeTime)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+		if !found {
+			utils.HandleError(fmt.Errorf("No logs were created at or before %s", beforeTime.Format(time.RFC3339)))
+		}
+
+		log = configLog.ID
+		if !utils.Silent {
+			utils.Log(fmt.Sprintf("Rolling back to log %s (%s, created %s)", configLog.ID, configLog.Text, configLog.CreatedAt))
+		}
+	}
 	utils.RequireValue("log", log)
 
+	rollbackConfigLog(cmd, localConfig, log, jsonFlag)
+}
+
+func undoConfigsLogs(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	yes := utils.GetBoolFlag(cmd, "yes")
+	localConfig := configuration.LocalConfig(cmd)
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	logs, err := http.GetConfigLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, 1, 1)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+	if len(logs) == 0 {
+		utils.HandleError(errors.New("This config has no changes to undo"))
+	}
+
+	latest := logs[0]
+	prompt := fmt.Sprintf("Roll back the most recent change (log %s: %s)?", latest.ID, latest.Text)
+	if yes || utils.ConfirmationPrompt(prompt, false) {
+		rollbackConfigLog(cmd, localConfig, latest.ID, jsonFlag)
+	}
+}
+
+// rollbackConfigLog rolls back the specified log and prints the log recording the rollback
+func rollbackConfigLog(cmd *cobra.Command, localConfig models.ScopedOptions, log string, jsonFlag bool) {
 	configLog, err := http.RollbackConfigLog(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, log)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
@@ -102,6 +357,34 @@ func rollbackConfigsLogs(cmd *cobra.Command, args []string) {
 
 	if !utils.Silent {
 		printer.ConfigLog(configLog, jsonFlag, true)
+		if !jsonFlag && configLog.ID != "" {
+			utils.Log(fmt.Sprintf("The rollback was recorded as log %s. To revert it, run 'doppler configs logs rollback %s'", configLog.ID, configLog.ID))
+		}
+	}
+
+	runSuccessHook(cmd, "config.rollback", localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, map[string]string{"DOPPLER_LOG": log, "DOPPLER_ROLLBACK_LOG": configLog.ID})
+}
+
+var configLogSortOptions = []string{"date", "user"}
+
+// sortConfigLogs sorts logs oldest-first by date, or alphabetically by user. Logs are left in server order if sortBy is empty.
+func sortConfigLogs(logs []models.ConfigLog, sortBy string) {
+	switch sortBy {
+	case "date":
+		sort.SliceStable(logs, func(i, j int) bool {
+			a, errA := time.Parse(time.RFC3339, logs[i].CreatedAt)
+			b, errB := time.Parse(time.RFC3339, logs[j].CreatedAt)
+			if errA != nil || errB != nil {
+				return logs[i].CreatedAt < logs[j].CreatedAt
+			}
+			return a.Before(b)
+		})
+	case "user":
+		sort.SliceStable(logs, func(i, j int) bool {
+			a := strings.ToLower(logs[i].User.Name + " " + logs[i].User.Email)
+			b := strings.ToLower(logs[j].User.Name + " " + logs[j].User.Email)
+			return a < b
+		})
 	}
 }
 
@@ -123,9 +406,19 @@ func init() {
 	configsLogsCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsLogsCmd.Flags().Int("page", 1, "log page to display")
 	configsLogsCmd.Flags().IntP("number", "n", 20, "max number of logs to display")
+	configsLogsCmd.Flags().String("sort", "", fmt.Sprintf("sort the logs. one of %v. all of the config's logs are fetched and sorted before --page and --number select the logs to display", configLogSortOptions))
+	configsLogsCmd.RegisterFlagCompletionFunc("sort", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return configLogSortOptions, cobra.ShellCompDirectiveNoFileComp
+	})
+	configsLogsCmd.Flags().Bool("reverse", false, "reverse the order of the logs. like --sort, this applies to all of the config's logs before --page and --number select the logs to display")
+	configsLogsCmd.Flags().Bool("fail-empty", false, fmt.Sprintf("exit with code %d if no logs are found", emptyResultExitCode))
+	configsLogsCmd.Flags().String("actor", "", "only show logs of changes made by this user or service token, matched by email, name, or token slug. logs are fetched until --number matching logs are found")
+	configsLogsCmd.Flags().Bool("all", false, "fetch the config's entire audit history rather than a single page, fetching up to --concurrency pages at once")
+	configsLogsCmd.Flags().Int("max", 0, "with --all, the max number of logs to fetch. 0 fetches all logs")
+	configsLogsCmd.Flags().Bool("abbrev", false, "display each log ID as its shortest unique prefix (of at least 7 characters) among the displayed logs")
 	configsCmd.AddCommand(configsLogsCmd)
 
-	configsLogsGetCmd.Flags().String("log", "", "audit log id")
+	configsLogsGetCmd.Flags().String("log", "", "audit log id (or a unique prefix of it)")
 	configsLogsGetCmd.RegisterFlagCompletionFunc("log", configLogIDsValidArgs)
 	configsLogsGetCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsLogsGetCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
@@ -133,11 +426,32 @@ func init() {
 	configsLogsGetCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsLogsCmd.AddCommand(configsLogsGetCmd)
 
+	configsLogsExportCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	configsLogsExportCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	configsLogsExportCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	configsLogsExportCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	configsLogsExportCmd.Flags().String("format", controllers.NDJSONAuditFormat, fmt.Sprintf("output format. one of %v", controllers.AuditFormats))
+	configsLogsExportCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return controllers.AuditFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	configsLogsExportCmd.Flags().String("since", "", "only export logs created at or after this time")
+	configsLogsExportCmd.Flags().String("until", "", "only export logs created at or before this time")
+	configsLogsExportCmd.Flags().String("actor", "", "only export logs of changes made by this user or service token, matched by email, name, or token slug")
+	configsLogsCmd.AddCommand(configsLogsExportCmd)
+
 	configsLogsRollbackCmd.Flags().String("log", "", "audit log id")
 	configsLogsRollbackCmd.RegisterFlagCompletionFunc("log", configLogIDsValidArgs)
+	configsLogsRollbackCmd.Flags().String("before", "", "roll back to the most recent log created at or before this time")
 	configsLogsRollbackCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	configsLogsRollbackCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	configsLogsRollbackCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	configsLogsRollbackCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	configsLogsCmd.AddCommand(configsLogsRollbackCmd)
+
+	configsLogsUndoCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	configsLogsUndoCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	configsLogsUndoCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	configsLogsUndoCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	configsLogsUndoCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	configsLogsCmd.AddCommand(configsLogsUndoCmd)
 }
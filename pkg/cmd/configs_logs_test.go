@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigsLogsSort(t *testing.T) {
+	// five logs, newest first
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		var logs []string
+		for i := 5 - (page-1)*perPage; i > 0 && i > 5-page*perPage; i-- {
+			logs = append(logs, fmt.Sprintf(`{"id":"log%d","created_at":"2024-01-0%dT00:00:00Z"}`, i, i))
+		}
+		fmt.Fprintf(w, `{"logs":[%s],"page":%d,"success":true}`, strings.Join(logs, ","), page)
+	}
+	ids := func(args ...string) []string {
+		output := captureStdout(t, func() {
+			executeCommand(t, handler, append([]string{"configs", "logs", "-p", "backend", "-c", "dev", "--json", "--page-size", "2"}, args...)...)
+		})
+		var logs []models.ConfigLog
+		assert.NoError(t, json.Unmarshal([]byte(output), &logs), output)
+		var ids []string
+		for _, log := range logs {
+			ids = append(ids, log.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"log5", "log4"}, ids("--number", "2"))
+	// the sort applies to all of the logs before --page and --number select some of them
+	assert.Equal(t, []string{"log1", "log2"}, ids("--number", "2", "--sort", "date"))
+	assert.Equal(t, []string{"log3", "log4"}, ids("--number", "2", "--page", "2", "--sort", "date"))
+	assert.Equal(t, []string{"log1", "log2", "log3"}, ids("--number", "3", "--reverse"))
+}
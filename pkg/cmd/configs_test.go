@@ -0,0 +1,372 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// configsAPI records the requests made to a test API that serves a single locked config, failing the request paths in fail
+type configsAPI struct {
+	mutex    sync.Mutex
+	requests []string
+	fail     map[string]bool
+}
+
+func (api *configsAPI) handler(w http.ResponseWriter, r *http.Request) {
+	api.mutex.Lock()
+	api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+	api.mutex.Unlock()
+
+	if api.fail[r.Method+" "+r.URL.Path] {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"messages":["Request failed"],"success":false}`)
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/configs/config/tokens":
+		fmt.Fprint(w, `{"tokens":[]}`)
+	default:
+		fmt.Fprintf(w, `{"config":{"name":%q,"project":"backend","environment":"dev","locked":true},"success":true}`, r.URL.Query().Get("config"))
+	}
+}
+
+func TestDeleteConfigsLocked(t *testing.T) {
+	t.Run("locked", func(t *testing.T) {
+		api := &configsAPI{}
+		code, stderr := executeCommandExit(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "config is locked")
+		assert.Equal(t, []string{"GET /v3/configs/config"}, api.requests)
+	})
+
+	t.Run("force", func(t *testing.T) {
+		api := &configsAPI{}
+		executeCommand(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes", "--force")
+		assert.Equal(t, []string{"GET /v3/configs/config", "GET /v3/configs/config/tokens", "POST /v3/configs/config/unlock", "DELETE /v3/configs/config"}, api.requests)
+	})
+
+	t.Run("relock", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"DELETE /v3/configs/config": true}}
+		code, stderr := executeCommandExit(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes", "--force")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "Unable to delete config")
+		assert.Equal(t, []string{"GET /v3/configs/config", "GET /v3/configs/config/tokens", "POST /v3/configs/config/unlock", "DELETE /v3/configs/config", "POST /v3/configs/config/lock"}, api.requests)
+	})
+
+	t.Run("relock failed", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"DELETE /v3/configs/config": true, "POST /v3/configs/config/lock": true}}
+		code, stderr := executeCommandExit(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes", "--force")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "The config was unlocked and couldn't be locked again")
+		assert.Contains(t, stderr, "unable to lock config dev again")
+	})
+}
+
+func TestCreateConfigsFromEnvFile(t *testing.T) {
+	handler := func(api *configsAPI) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && r.URL.Path == "/v3/configs" {
+				api.mutex.Lock()
+				api.requests = append(api.requests, "POST /v3/configs")
+				api.mutex.Unlock()
+				fmt.Fprint(w, `{"config":{"name":"dev_personal","project":"backend","environment":"dev"},"success":true}`)
+				return
+			}
+			api.handler(w, r)
+		}
+	}
+	writeEnvFile := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), ".env")
+		assert.NoError(t, os.WriteFile(path, []byte("HOST=localhost\n"), 0600))
+		return path
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"POST /v3/configs/config/secrets": true}}
+		code, stderr := executeCommandExit(t, handler(api), "configs", "create", "dev_personal", "-p", "backend", "-e", "dev", "--from-env-file", writeEnvFile(t))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "Rolled back: deleted config dev_personal")
+		assert.Equal(t, []string{"POST /v3/configs", "POST /v3/configs/config/secrets", "DELETE /v3/configs/config"}, api.requests)
+	})
+
+	t.Run("keep on failure", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"POST /v3/configs/config/secrets": true}}
+		code, stderr := executeCommandExit(t, handler(api), "configs", "create", "dev_personal", "-p", "backend", "-e", "dev", "--from-env-file", writeEnvFile(t), "--keep-on-failure")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "Config dev_personal was created without secrets")
+		assert.Equal(t, []string{"POST /v3/configs", "POST /v3/configs/config/secrets"}, api.requests)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		api := &configsAPI{}
+		executeCommand(t, handler(api), "configs", "create", "dev_personal", "-p", "backend", "-e", "dev", "--from-env-file", writeEnvFile(t))
+		assert.Equal(t, []string{"POST /v3/configs", "POST /v3/configs/config/secrets"}, api.requests)
+	})
+}
+
+func TestRenameConfigs(t *testing.T) {
+	handler := func(api *configsAPI, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.URL.Query().Get("config") == "dev_jane" {
+				api.mutex.Lock()
+				api.requests = append(api.requests, "GET /v3/configs/config?config=dev_jane")
+				api.mutex.Unlock()
+				w.WriteHeader(status)
+				fmt.Fprint(w, `{"config":{"name":"dev_jane","project":"backend"},"messages":["Request failed"]}`)
+				return
+			}
+			api.handler(w, r)
+		}
+	}
+	args := []string{"configs", "rename", "dev_personal", "dev_jane", "-p", "backend", "--yes"}
+
+	t.Run("available", func(t *testing.T) {
+		api := &configsAPI{}
+		executeCommand(t, handler(api, http.StatusNotFound), args...)
+		assert.Equal(t, []string{"GET /v3/configs/config?config=dev_jane", "POST /v3/configs/config"}, api.requests)
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		api := &configsAPI{}
+		code, stderr := executeCommandExit(t, handler(api, http.StatusOK), args...)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "config dev_jane already exists")
+		assert.Equal(t, []string{"GET /v3/configs/config?config=dev_jane"}, api.requests)
+	})
+
+	t.Run("check failed", func(t *testing.T) {
+		api := &configsAPI{}
+		code, stderr := executeCommandExit(t, handler(api, http.StatusForbidden), args...)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "Unable to check whether config dev_jane exists")
+		assert.Equal(t, []string{"GET /v3/configs/config?config=dev_jane"}, api.requests)
+	})
+}
+
+func TestConfigsFilter(t *testing.T) {
+	// 5 configs, 2 per page, so the matches span the last two pages
+	names := []string{"dev", "stg", "prd", "prd_eu", "prd_us"}
+	handler := func(api *configsAPI) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var page int
+			fmt.Sscan(r.URL.Query().Get("page"), &page) // #nosec G104
+			api.mutex.Lock()
+			api.requests = append(api.requests, fmt.Sprintf("%s %s?page=%d&per_page=%s", r.Method, r.URL.Path, page, r.URL.Query().Get("per_page")))
+			api.mutex.Unlock()
+
+			var configs []string
+			for i := (page - 1) * 2; i < len(names) && i < page*2; i++ {
+				configs = append(configs, fmt.Sprintf(`{"name":%q,"project":"backend","environment":"prd"}`, names[i]))
+			}
+			fmt.Fprintf(w, `{"configs":[%s],"page":%d,"success":true}`, strings.Join(configs, ","), page)
+		}
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		api := &configsAPI{}
+		output := captureStdout(t, func() {
+			executeCommand(t, handler(api), "configs", "-p", "backend", "--filter", "prd_*", "--only-names", "--page-size", "2")
+		})
+		assert.Equal(t, "prd_eu\nprd_us\n", output)
+		assert.Equal(t, []string{"GET /v3/configs?page=1&per_page=2", "GET /v3/configs?page=2&per_page=2", "GET /v3/configs?page=3&per_page=2"}, api.requests)
+	})
+
+	t.Run("page of matches", func(t *testing.T) {
+		api := &configsAPI{}
+		output := captureStdout(t, func() {
+			executeCommand(t, handler(api), "configs", "-p", "backend", "--filter", "prd*", "--only-names", "--page-size", "2", "--number", "2", "--page", "2")
+		})
+		assert.Equal(t, "prd_us\n", output)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(&configsAPI{}), "configs", "-p", "backend", "--filter", "qa_*", "--page-size", "2")
+		assert.Equal(t, noFilterMatchExitCode, code)
+		assert.Contains(t, stderr, `no configs match "qa_*"`)
+	})
+}
+
+func TestConfigsPrintETag(t *testing.T) {
+	handler := func(etag string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+			}
+			if r.URL.Path == "/v3/configs" {
+				fmt.Fprint(w, `{"configs":[{"name":"dev","project":"backend","environment":"dev"}],"page":1,"success":true}`)
+				return
+			}
+			fmt.Fprint(w, `{"config":{"name":"dev","project":"backend","environment":"dev"},"success":true}`)
+		}
+	}
+
+	t.Run("configs", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(`W/"configs-etag"`), "configs", "-p", "backend", "--print-etag")
+		assert.Equal(t, 0, code)
+		// the ETag is printed as-is, so it can be sent in an If-None-Match header
+		assert.Equal(t, "W/\"configs-etag\"\n", stderr)
+	})
+
+	t.Run("configs get", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(`"config-etag"`), "configs", "get", "dev", "-p", "backend", "--print-etag")
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "\"config-etag\"\n", stderr)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(""), "configs", "-p", "backend", "--print-etag")
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stderr, "The API response doesn't contain an ETag")
+	})
+
+	t.Run("multiple projects", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(`"configs-etag"`), "configs", "--projects", "backend,frontend", "--print-etag")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "--print-etag can't be used when listing configs for multiple projects")
+	})
+}
+
+func TestConfigsOnlyNames(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		project := r.URL.Query().Get("project")
+		fmt.Fprintf(w, `{"configs":[{"name":"stg","project":%[1]q,"environment":"stg"},{"name":"dev","project":%[1]q,"environment":"dev"}],"page":1,"success":true}`, project)
+	}
+	list := func(args ...string) string {
+		return captureStdout(t, func() {
+			executeCommand(t, handler, append([]string{"configs", "--only-names"}, args...)...)
+		})
+	}
+
+	// names are streamed in server order
+	assert.Equal(t, "stg\ndev\n", list("-p", "backend"))
+	// or printed once the configs are sorted
+	assert.Equal(t, "dev\nstg\n", list("-p", "backend", "--sort", "name"))
+	assert.Equal(t, "stg\ndev\nstg\ndev\n", list("--projects", "backend,frontend"))
+
+	t.Run("json", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler, "configs", "-p", "backend", "--only-names", "--json")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "--only-names can't be used with --json")
+	})
+}
+
+func TestDeleteConfigsBatch(t *testing.T) {
+	// fails the requests for the configs in fail, serving the other configs unlocked and without service tokens
+	handler := func(api *configsAPI, fail map[string]bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			config := r.URL.Query().Get("config")
+			api.mutex.Lock()
+			api.requests = append(api.requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, config))
+			api.mutex.Unlock()
+
+			switch {
+			case fail[r.Method+" "+config]:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"messages":["Request failed"],"success":false}`)
+			case r.URL.Path == "/v3/configs/config/tokens":
+				fmt.Fprint(w, `{"tokens":[]}`)
+			default:
+				fmt.Fprintf(w, `{"config":{"name":%q,"project":"backend","environment":"dev","locked":false},"success":true}`, config)
+			}
+		}
+	}
+
+	t.Run("deletion failed", func(t *testing.T) {
+		api := &configsAPI{}
+		code, stderr := executeCommandExit(t, handler(api, map[string]bool{"DELETE stg": true}), "configs", "delete", "dev", "stg", "prd", "-p", "backend", "--yes")
+		assert.Equal(t, partialFailureExitCode, code)
+		assert.Contains(t, stderr, "Unable to delete config stg")
+		assert.Contains(t, stderr, "unable to delete 1 of 3 configs")
+		// a failed deletion doesn't stop the remaining deletions
+		assert.Contains(t, api.requests, "DELETE /v3/configs/config prd")
+	})
+
+	t.Run("check failed", func(t *testing.T) {
+		api := &configsAPI{}
+		code, stderr := executeCommandExit(t, handler(api, map[string]bool{"GET stg": true}), "configs", "delete", "dev", "stg", "prd", "-p", "backend", "--yes")
+		assert.Equal(t, partialFailureExitCode, code)
+		assert.Contains(t, stderr, "Unable to delete config stg")
+		assert.Contains(t, stderr, "unable to delete 1 of 3 configs")
+		assert.NotContains(t, api.requests, "DELETE /v3/configs/config stg")
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler(&configsAPI{}, map[string]bool{"DELETE dev": true, "DELETE stg": true}), "configs", "delete", "dev", "stg", "-p", "backend", "--yes")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "unable to delete any of the 2 configs")
+	})
+}
+
+func TestDeleteConfigsServiceTokens(t *testing.T) {
+	t.Run("unable to list", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"GET /v3/configs/config/tokens": true}}
+		executeCommand(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes", "--force")
+		assert.Contains(t, api.requests, "DELETE /v3/configs/config")
+	})
+
+	t.Run("unable to list with cascade", func(t *testing.T) {
+		api := &configsAPI{fail: map[string]bool{"GET /v3/configs/config/tokens": true}}
+		code, _ := executeCommandExit(t, api.handler, "configs", "delete", "dev", "-p", "backend", "--yes", "--force", "--cascade-tokens")
+		assert.Equal(t, 1, code)
+		assert.NotContains(t, api.requests, "DELETE /v3/configs/config")
+	})
+}
+
+func TestCreateConfigsEnvFileOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(t, os.WriteFile(path, []byte("HOST=localhost\r\nURL=\"http://${HOST}\"\r\nCERT=\"a\r\nb\"\r\nHOST=db\r\nPADDED=\"  x  \"\r\n"), 0600))
+
+	create := func(args ...string) map[string]interface{} {
+		var body struct {
+			Secrets map[string]interface{} `json:"secrets"`
+		}
+		api := &configsAPI{}
+		executeCommand(t, func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodPost && r.URL.Path == "/v3/configs":
+				fmt.Fprint(w, `{"config":{"name":"dev_personal","project":"backend","environment":"dev"},"success":true}`)
+			case r.Method == http.MethodPost && r.URL.Path == "/v3/configs/config/secrets":
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				fmt.Fprint(w, `{"secrets":{}}`)
+			default:
+				api.handler(w, r)
+			}
+		}, append([]string{"configs", "create", "dev_personal", "-p", "backend", "-e", "dev", "--from-env-file", path, "--allow-duplicates"}, args...)...)
+		return body.Secrets
+	}
+
+	// references are taken literally, line endings are converted to LF, and values are trimmed
+	assert.Equal(t, map[string]interface{}{"HOST": "db", "URL": "http://${HOST}", "CERT": "a\nb", "PADDED": "x"}, create())
+	assert.Equal(t, map[string]interface{}{"HOST": "db", "URL": "http://localhost", "CERT": "a\nb", "PADDED": "x"}, create("--dotenv-expand"))
+	assert.Equal(t, "a\r\nb", create("--keep-crlf")["CERT"])
+	assert.Equal(t, "  x  ", create("--trim=false")["PADDED"])
+
+	t.Run("duplicates", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, (&configsAPI{}).handler, "configs", "create", "dev_personal", "-p", "backend", "-e", "dev", "--from-env-file", path)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "duplicate key HOST")
+	})
+}
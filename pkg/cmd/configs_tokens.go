@@ -29,31 +29,35 @@ import (
 )
 
 var configsTokensCmd = &cobra.Command{
-	Use:   "tokens",
-	Short: "List a config's service tokens",
-	Args:  cobra.NoArgs,
-	Run:   configsTokens,
+	Use:     "tokens",
+	Short:   "List a config's service tokens",
+	Example: `doppler configs tokens --config prd`,
+	Args:    cobra.NoArgs,
+	Run:     configsTokens,
 }
 
 var configsTokensGetCmd = &cobra.Command{
 	Use:               "get [slug]",
 	Short:             "Get a config's service token",
+	Example:           `doppler configs tokens get SLUG --config prd`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: configTokenSlugsValidArgs,
 	Run:               getConfigsTokens,
 }
 
 var configsTokensCreateCmd = &cobra.Command{
-	Use:   "create [name]",
-	Short: "Create a service token for a config",
-	Args:  cobra.MaximumNArgs(1),
-	Run:   createConfigsTokens,
+	Use:     "create [name]",
+	Short:   "Create a service token for a config",
+	Example: `doppler configs tokens create ci-deploy --config prd --plain`,
+	Args:    cobra.MaximumNArgs(1),
+	Run:     createConfigsTokens,
 }
 
 var configsTokensRevokeCmd = &cobra.Command{
 	Use:               "revoke [slug|token]",
 	Aliases:           []string{"delete"},
 	Short:             "Revoke a service token from a config",
+	Example:           `doppler configs tokens revoke SLUG --config prd`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: configTokenSlugsValidArgs,
 	Run:               revokeConfigsTokens,
@@ -18,6 +18,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
@@ -52,7 +53,20 @@ var configureDebugCmd = &cobra.Command{
 	Long: `View current configuration utilizing all config sources.
 
 This includes specified flags (--token=123), environment variables (DOPPLER_TOKEN=123),
-and your config file. Flags have the highest priority; config file has the least.`,
+the project file, and your config file. Flags have the highest priority, followed by
+environment variables, then the project file and the config file. Within the config file,
+the most specific scope containing the current directory takes precedence over less specific
+scopes (e.g. the global "/" scope). The project file takes precedence over config file scopes
+for its directory and its parents, but not over scopes for its subdirectories.
+
+The project file is the .doppler.yaml file nearest to the current directory, searching the
+directory and then each of its parents. It sets the default project and config with the
+"project" and "config" keys (e.g. "project: backend"). This file is distinct from the
+doppler.yaml file read by 'doppler setup'. An invalid project file is ignored with a warning.
+
+The source of each value is displayed alongside it. Tokens are redacted. JSON output groups the values
+by scope (e.g. {"/": {"token": "..."}}). Use --sources to instead print each option's value, scope,
+and source (e.g. {"token": {"value": "...", "scope": "/", "source": "Config File"}}).`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		jsonFlag := utils.OutputJSON
@@ -61,7 +75,11 @@ and your config file. Flags have the highest priority; config file has the least
 		utils.Log(fmt.Sprintf("%s %s", color.Green.Render("Configuration directory:"), configuration.UserConfigDir))
 
 		config := configuration.LocalConfig(cmd)
-		printer.ScopedConfigSource(config, jsonFlag, true, false)
+		if jsonFlag && utils.GetBoolFlag(cmd, "sources") {
+			printer.ScopedConfigSources(config, true)
+			return
+		}
+		printer.ScopedConfigSource(config, jsonFlag, true, true)
 	},
 }
 
@@ -254,6 +272,84 @@ var configureResetCmd = &cobra.Command{
 	},
 }
 
+var configureHostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Manage host presets",
+	Long: `Manage host presets, which are named pairs of API and dashboard hosts (e.g. for a self-hosted deployment).
+
+Use a preset with --host-preset to set both hosts at once. --api-host and --dashboard-host take precedence over the preset.`,
+	Args: cobra.NoArgs,
+}
+
+var configureHostsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved host presets",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer.HostPresets(configuration.HostPresets(), utils.OutputJSON)
+	},
+}
+
+var configureHostsAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Save a host preset",
+	Long: `Save a host preset, replacing any existing preset with the same name.
+
+Ex: save and use a preset for a self-hosted deployment:
+doppler configure hosts add internal --api https://doppler-api.internal --dashboard https://doppler.internal
+doppler secrets --host-preset internal`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		preset := models.HostPreset{
+			APIHost:       strings.TrimSuffix(cmd.Flag("api").Value.String(), "/"),
+			DashboardHost: strings.TrimSuffix(cmd.Flag("dashboard").Value.String(), "/"),
+		}
+
+		for flag, host := range map[string]string{"api": preset.APIHost, "dashboard": preset.DashboardHost} {
+			if host == "" {
+				continue
+			}
+			if parsed, err := url.Parse(host); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				utils.HandleError(fmt.Errorf("invalid --%s host %q. Hosts must be URLs like https://api.example.com", flag, host))
+			}
+		}
+
+		configuration.SetHostPreset(name, preset)
+
+		if !utils.Silent {
+			printer.HostPresets(map[string]models.HostPreset{name: preset}, utils.OutputJSON)
+		}
+	},
+}
+
+var configureHostsRemoveCmd = &cobra.Command{
+	Use:               "remove [name]",
+	Short:             "Delete a host preset",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: hostPresetsValidArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !configuration.RemoveHostPreset(args[0]) {
+			utils.HandleError(fmt.Errorf("host preset %q does not exist", args[0]))
+		}
+
+		if !utils.Silent {
+			utils.Log(fmt.Sprintf("Removed host preset %s", args[0]))
+		}
+	},
+}
+
+// hostPresetsValidArgs the names of the saved host presets
+func hostPresetsValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	persistentValidArgsFunction(cmd)
+
+	var names []string
+	for name := range configuration.HostPresets() {
+		names = append(names, name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
 // configOptionsValidArgs all possible config options
 func configOptionsValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	persistentValidArgsFunction(cmd)
@@ -285,6 +381,7 @@ func currentConfigOptionsValidArgs(cmd *cobra.Command, args []string, toComplete
 }
 
 func init() {
+	configureDebugCmd.Flags().Bool("sources", false, "with --json, print each option's value, scope, and source rather than grouping the values by scope")
 	configureCmd.AddCommand(configureDebugCmd)
 
 	configureCmd.AddCommand(configureOptionsCmd)
@@ -297,6 +394,16 @@ func init() {
 
 	configureCmd.AddCommand(configureUnsetCmd)
 
+	configureHostsAddCmd.Flags().String("api", "", "the API host (e.g. https://api.doppler.com)")
+	configureHostsAddCmd.Flags().String("dashboard", "", "the dashboard host (e.g. https://dashboard.doppler.com)")
+	if err := configureHostsAddCmd.MarkFlagRequired("api"); err != nil {
+		utils.HandleError(err)
+	}
+	configureHostsCmd.AddCommand(configureHostsAddCmd)
+	configureHostsCmd.AddCommand(configureHostsListCmd)
+	configureHostsCmd.AddCommand(configureHostsRemoveCmd)
+	configureCmd.AddCommand(configureHostsCmd)
+
 	configureResetCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	configureCmd.AddCommand(configureResetCmd)
 
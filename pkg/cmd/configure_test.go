@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureDebugJSON(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	output := captureStdout(t, func() {
+		executeCommand(t, handler, "configure", "debug", "--json")
+	})
+	// values are grouped by scope
+	var byScope map[string]map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(output), &byScope), output)
+	// the test server's address varies
+	delete(byScope["/"], "api-host")
+	assert.Equal(t, map[string]map[string]string{"/": {"dashboard-host": "https://dashboard.doppler.com", "token": "[REDACTED]", "verify-tls": "true"}}, byScope)
+
+	output = captureStdout(t, func() {
+		executeCommand(t, handler, "configure", "debug", "--json", "--sources")
+	})
+	var sources map[string]models.ScopedOption
+	assert.NoError(t, json.Unmarshal([]byte(output), &sources), output)
+	assert.Equal(t, models.ScopedOption{Value: "[REDACTED]", Scope: "/", Source: models.FlagSource.String()}, sources["token"])
+	assert.Equal(t, models.ScopedOption{Value: "true", Scope: "/", Source: models.DefaultValueSource.String()}, sources["verify-tls"])
+}
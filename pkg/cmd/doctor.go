@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/controllers"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/printer"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose problems with the CLI's environment",
+	Long: `Check that the config file is readable and writable, the token is valid, and the API is reachable,
+along with the TLS, proxy, and clock settings that commonly cause requests to fail.
+
+Exits with code 1 if any check fails. Warnings don't affect the exit code.`,
+	Args: cobra.NoArgs,
+	Run:  doctor,
+}
+
+func doctor(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	localConfig := configuration.LocalConfig(cmd)
+
+	checks := controllers.RunDoctorChecks(localConfig, configuration.UserConfigFile)
+	printer.DoctorChecks(checks, jsonFlag)
+
+	failed := 0
+	for _, check := range checks {
+		if check.Status == models.DoctorCheckFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		utils.HandleError(fmt.Errorf("%d of %d checks failed", failed, len(checks)))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -109,6 +109,7 @@ func init() {
 	enclaveConfigsCreateCmd.Flags().String("name", "", "config name")
 	enclaveConfigsCreateCmd.Flags().StringP("environment", "e", "", "config environment")
 	enclaveConfigsCreateCmd.RegisterFlagCompletionFunc("environment", configEnvironmentIDsValidArgs)
+	enclaveConfigsCreateCmd.Flags().Bool("only-name", false, "print only the created config's name, even when --silent is specified")
 	enclaveConfigsCmd.AddCommand(enclaveConfigsCreateCmd)
 
 	enclaveConfigsUpdateCmd.Flags().StringP("project", "p", "", "enclave project (e.g. backend)")
@@ -132,14 +133,14 @@ func init() {
 	enclaveConfigsLockCmd.Flags().StringP("project", "p", "", "enclave project (e.g. backend)")
 	enclaveConfigsLockCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	enclaveConfigsLockCmd.Flags().StringP("config", "c", "", "enclave config (e.g. dev)")
-	enclaveConfigsLockCmd.RegisterFlagCompletionFunc("config", lockedConfigNamesValidArgs)
+	enclaveConfigsLockCmd.RegisterFlagCompletionFunc("config", unlockedConfigNamesValidArgs)
 	enclaveConfigsLockCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	enclaveConfigsCmd.AddCommand(enclaveConfigsLockCmd)
 
 	enclaveConfigsUnlockCmd.Flags().StringP("project", "p", "", "enclave project (e.g. backend)")
 	enclaveConfigsUnlockCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	enclaveConfigsUnlockCmd.Flags().StringP("config", "c", "", "enclave config (e.g. dev)")
-	enclaveConfigsUnlockCmd.RegisterFlagCompletionFunc("config", unlockedConfigNamesValidArgs)
+	enclaveConfigsUnlockCmd.RegisterFlagCompletionFunc("config", lockedConfigNamesValidArgs)
 	enclaveConfigsUnlockCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	enclaveConfigsCmd.AddCommand(enclaveConfigsUnlockCmd)
 
@@ -138,6 +138,8 @@ func init() {
 	enclaveSecretsDownloadCmd.Flags().Bool("no-file", false, "print the response to stdout")
 	enclaveSecretsDownloadCmd.Flags().String("name-transformer", "", fmt.Sprintf("output name transformer. one of %v", validNameTransformersList))
 	enclaveSecretsDownloadCmd.Flags().Duration("dynamic-ttl", 0, "(BETA) dynamic secrets will expire after specified duration, (e.g. '3h', '15m')")
+	enclaveSecretsDownloadCmd.Flags().Bool("fail-if-committable", false, "exit with an error instead of a warning if the file is in a git repo and isn't ignored by git")
+	enclaveSecretsDownloadCmd.Flags().Bool("no-git-check", false, "don't check whether the file is ignored by git before writing it")
 	// fallback flags
 	enclaveSecretsDownloadCmd.Flags().String("fallback", "", "path to the fallback file. encrypted secrets are written to this file after each successful fetch. secrets will be read from this file if subsequent connections are unsuccessful.")
 	enclaveSecretsDownloadCmd.Flags().Bool("no-cache", false, "disable using the fallback file to speed up fetches. the fallback file is only used when the API indicates that it's still current.")
@@ -146,6 +148,7 @@ func init() {
 	enclaveSecretsDownloadCmd.Flags().Bool("fallback-readonly", false, "disable modifying the fallback file. secrets can still be read from the file.")
 	enclaveSecretsDownloadCmd.Flags().Bool("fallback-only", false, "read all secrets directly from the fallback file, without contacting Doppler. secrets will not be updated. (implies --fallback-readonly)")
 	enclaveSecretsDownloadCmd.Flags().Bool("no-exit-on-write-failure", false, "do not exit if unable to write the fallback file")
+	enclaveSecretsDownloadCmd.Flags().Duration("fallback-max-age", 0, "fail rather than read secrets from a fallback file that was last updated longer ago than this duration (e.g. '24h'). the file is updated after each successful fetch. by default there's no limit")
 	enclaveSecretsCmd.AddCommand(enclaveSecretsDownloadCmd)
 
 	enclaveCmd.AddCommand(enclaveSecretsCmd)
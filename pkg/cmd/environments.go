@@ -28,30 +28,34 @@ import (
 )
 
 var environmentsCmd = &cobra.Command{
-	Use:   "environments",
-	Short: "Manage environments",
-	Args:  cobra.NoArgs,
-	Run:   environments,
+	Use:     "environments",
+	Short:   "Manage environments",
+	Example: `doppler environments --project backend`,
+	Args:    cobra.NoArgs,
+	Run:     environments,
 }
 
 var environmentsGetCmd = &cobra.Command{
 	Use:               "get [environment_id]",
 	Short:             "Get info for an environment",
+	Example:           `doppler environments get dev`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: configEnvironmentIDsValidArgs,
 	Run:               getEnvironments,
 }
 
 var environmentsCreateCmd = &cobra.Command{
-	Use:   "create [name] [slug]",
-	Short: "Create an environment",
-	Args:  cobra.ExactArgs(2),
-	Run:   createEnvironment,
+	Use:     "create [name] [slug]",
+	Short:   "Create an environment",
+	Example: `doppler environments create "QA" qa`,
+	Args:    cobra.ExactArgs(2),
+	Run:     createEnvironment,
 }
 
 var environmentsDeleteCmd = &cobra.Command{
 	Use:               "delete [slug]",
 	Short:             "Delete an environment",
+	Example:           `doppler environments delete qa`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: configEnvironmentIDsValidArgs,
 	Run:               deleteEnvironment,
@@ -60,6 +64,7 @@ var environmentsDeleteCmd = &cobra.Command{
 var environmentsRenameCmd = &cobra.Command{
 	Use:               "rename [slug]",
 	Short:             "Rename an environment",
+	Example:           `doppler environments rename qa --name "Quality Assurance"`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: configEnvironmentIDsValidArgs,
 	Run:               renameEnvironment,
@@ -0,0 +1,102 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/printer"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/spf13/cobra"
+	"gopkg.in/gookit/color.v1"
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples [command]",
+	Short: "View example usage of commands",
+	Long: `View example usage of commands.
+
+When a command is specified, only examples for that command and its subcommands are shown.`,
+	Example: `doppler examples
+doppler examples configs
+doppler examples configs create`,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		parent, _, err := rootCmd.Find(args)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, c := range parent.Commands() {
+			if c.IsAvailableCommand() {
+				names = append(names, c.Name())
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: examples,
+}
+
+func examples(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+
+	target := rootCmd
+	if len(args) > 0 {
+		c, remaining, err := rootCmd.Find(args)
+		if err != nil || len(remaining) > 0 || c == rootCmd {
+			utils.HandleError(fmt.Errorf("unknown command %q", strings.Join(args, " ")))
+		}
+		target = c
+	}
+
+	commandExamples := map[string]string{}
+	var paths []string
+	var collect func(c *cobra.Command)
+	collect = func(c *cobra.Command) {
+		if c.Example != "" && !c.Hidden && c.Deprecated == "" {
+			commandExamples[c.CommandPath()] = c.Example
+			paths = append(paths, c.CommandPath())
+		}
+		for _, child := range c.Commands() {
+			collect(child)
+		}
+	}
+	collect(target)
+
+	if len(paths) == 0 {
+		utils.HandleError(errors.New("no examples are available for this command"))
+	}
+
+	if jsonFlag {
+		printer.JSON(commandExamples)
+		return
+	}
+
+	for i, path := range paths {
+		if i != 0 {
+			utils.Print("")
+		}
+		utils.Print(color.Cyan.Sprint(path))
+		for _, line := range strings.Split(commandExamples[path], "\n") {
+			utils.Print("  " + line)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+}
@@ -28,30 +28,34 @@ import (
 )
 
 var projectsCmd = &cobra.Command{
-	Use:   "projects",
-	Short: "Manage projects",
-	Args:  cobra.NoArgs,
-	Run:   projects,
+	Use:     "projects",
+	Short:   "Manage projects",
+	Example: `doppler projects`,
+	Args:    cobra.NoArgs,
+	Run:     projects,
 }
 
 var projectsGetCmd = &cobra.Command{
 	Use:               "get [project_id]",
 	Short:             "Get info for a project",
+	Example:           `doppler projects get backend`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: projectIDsValidArgs,
 	Run:               getProjects,
 }
 
 var projectsCreateCmd = &cobra.Command{
-	Use:   "create [name]",
-	Short: "Create a project",
-	Args:  cobra.MaximumNArgs(1),
-	Run:   createProjects,
+	Use:     "create [name]",
+	Short:   "Create a project",
+	Example: `doppler projects create backend --description "API server"`,
+	Args:    cobra.MaximumNArgs(1),
+	Run:     createProjects,
 }
 
 var projectsDeleteCmd = &cobra.Command{
 	Use:               "delete [project_id]",
 	Short:             "Delete a project",
+	Example:           `doppler projects delete backend`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: projectIDsValidArgs,
 	Run:               deleteProjects,
@@ -60,6 +64,7 @@ var projectsDeleteCmd = &cobra.Command{
 var projectsUpdateCmd = &cobra.Command{
 	Use:               "update [project_id]",
 	Short:             "Update a project",
+	Example:           `doppler projects update backend --name api`,
 	Args:              cobra.MaximumNArgs(1),
 	ValidArgsFunction: projectIDsValidArgs,
 	Run:               updateProjects,
@@ -16,19 +16,24 @@ limitations under the License.
 package cmd
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
 	"github.com/DopplerHQ/cli/pkg/controllers"
 	"github.com/DopplerHQ/cli/pkg/global"
 	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/DopplerHQ/cli/pkg/version"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gopkg.in/gookit/color.v1"
 )
 
@@ -94,6 +99,29 @@ func loadFlags(cmd *cobra.Command) {
 	configuration.Scope = normalizedScope
 
 	configuration.CanReadEnv = !utils.GetBoolFlag(cmd, "no-read-env")
+	if configuration.CanReadEnv {
+		loadFlagsFromEnvironment(cmd)
+	}
+
+	if !utils.Contains(utils.ErrorFormats, utils.ErrorFormat) {
+		format := utils.ErrorFormat
+		utils.ErrorFormat = utils.TextErrorFormat
+		utils.HandleError(fmt.Errorf("invalid error format %q. Valid formats are %v", format, utils.ErrorFormats))
+	}
+
+	// the envelope implies json output
+	if utils.JSONEnvelope {
+		utils.OutputJSON = true
+	}
+
+	// NO_COLOR is a cross-tool convention (https://no-color.org), so it's honored even with --no-read-env
+	if utils.GetBoolFlag(cmd, "no-color") || os.Getenv("NO_COLOR") != "" {
+		color.Enable = false
+	}
+
+	if utils.GetBoolFlag(cmd, "token-stdin") {
+		loadTokenFromStdin(cmd)
+	}
 
 	// User Config Dir
 	if configuration.CanReadEnv {
@@ -105,7 +133,44 @@ func loadFlags(cmd *cobra.Command) {
 	}
 	configuration.SetConfigDir(utils.GetPathFlagIfChanged(cmd, "config-dir", configuration.UserConfigDir))
 	configuration.UserConfigFile = utils.GetPathFlagIfChanged(cmd, "configuration", configuration.UserConfigFile)
+	if configFormat := cmd.Flag("config-format").Value.String(); configFormat != "" {
+		if !utils.Contains(configuration.ConfigFormats, configFormat) {
+			utils.HandleError(fmt.Errorf("invalid config format %q. Valid options are %v", configFormat, configuration.ConfigFormats))
+		}
+		configuration.ConfigFormat = configFormat
+	}
 	http.UseTimeout = !utils.GetBoolFlag(cmd, "no-timeout")
+	if utils.Concurrency < 1 {
+		utils.HandleError(fmt.Errorf("invalid concurrency %d. Must be at least 1", utils.Concurrency))
+	}
+	if utils.PageSize < 1 {
+		utils.HandleError(fmt.Errorf("invalid page size %d. Must be at least 1", utils.PageSize))
+	}
+	if utils.PageSize > utils.MaxPageSize {
+		utils.LogWarning(fmt.Sprintf("--page-size %d exceeds the max of %d. Using %d", utils.PageSize, utils.MaxPageSize, utils.MaxPageSize))
+		utils.PageSize = utils.MaxPageSize
+	}
+
+	minTLSVersion, err := http.ParseTLSVersion(cmd.Flag("min-tls-version").Value.String())
+	if err != nil {
+		utils.HandleError(err)
+	}
+	http.MinTLSVersion = minTLSVersion
+	if ciphers, _ := cmd.Flags().GetStringSlice("tls-ciphers"); len(ciphers) > 0 {
+		http.TLSCipherSuites, err = http.ParseTLSCipherSuites(ciphers)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if minTLSVersion == tls.VersionTLS13 {
+			utils.LogWarning("--tls-ciphers has no effect when the minimum TLS version is 1.3")
+		}
+	}
+
+	timeZone, err := utils.ParseTimeZone(cmd.Flag("timezone").Value.String())
+	if err != nil {
+		utils.HandleError(err)
+	}
+	utils.TimeZone = timeZone
 
 	// DNS resolver
 	if configuration.CanReadEnv {
@@ -133,6 +198,126 @@ func loadFlags(cmd *cobra.Command) {
 	version.PerformVersionCheck = !utils.GetBoolFlagIfChanged(cmd, "no-check-version", !version.PerformVersionCheck)
 }
 
+// loadTokenFromStdin sets --token to the first line of stdin, which keeps the token out of the process list and environment
+func loadTokenFromStdin(cmd *cobra.Command) {
+	if cmd.Flags().Changed("token") {
+		utils.HandleError(errors.New("--token-stdin and --token can't be used together"))
+	}
+
+	token, err := utils.ReadStdInLine()
+	if err != nil {
+		utils.HandleError(err, "Unable to read token from stdin")
+	}
+	if token == nil {
+		utils.HandleError(errors.New("--token-stdin requires the token to be piped to stdin"))
+	}
+	if strings.TrimSpace(*token) == "" {
+		utils.HandleError(errors.New("the token read from stdin is empty"))
+	}
+
+	if err := cmd.Flags().Set("token", strings.TrimSpace(*token)); err != nil {
+		utils.HandleError(err, "Unable to set token")
+	}
+}
+
+// flags that may be set from their DOPPLER_<FLAG> environment variable. these only affect how the CLI connects and
+// prints its output; flags that change what a command does (e.g. --force or --replace) or that disable a safety check
+// (e.g. --insecure-allow-plaintext-http) must be specified explicitly, so a stray exported variable can't enable them
+var flagsWithEnvDefaults = []string{
+	// output
+	"json", "json-envelope", "silent", "debug", "print-config", "no-color", "plain-errors", "error-format", "no-pager", "timezone",
+	// requests
+	"timeout", "no-timeout", "attempts", "concurrency", "page-size", "user-agent",
+	"dns-resolver-address", "dns-resolver-proto", "dns-resolver-timeout", "host-preset", "config-format",
+}
+
+// loadFlagsFromEnvironment sets each of flagsWithEnvDefaults that wasn't specified on the command line from its
+// DOPPLER_<FLAG> environment variable (e.g. DOPPLER_JSON=true for --json). Precedence is flag > environment > config file > default.
+func loadFlagsFromEnvironment(cmd *cobra.Command) {
+	envOptions := models.EnvOptions(&models.ScopedOptions{})
+
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || !utils.Contains(flagsWithEnvDefaults, flag.Name) {
+			return
+		}
+
+		envVar := "DOPPLER_" + strings.ToUpper(strings.ReplaceAll(flag.Name, "-", "_"))
+		// options like DOPPLER_TOKEN are read by the configuration package, which tracks their source
+		if _, ok := envOptions[envVar]; ok {
+			return
+		}
+
+		value, ok := os.LookupEnv(envVar)
+		if !ok || value == "" {
+			return
+		}
+
+		utils.LogDebug(fmt.Sprintf("Using --%s from %s", flag.Name, envVar))
+		if err := cmd.Flags().Set(flag.Name, value); err != nil {
+			utils.HandleError(err, fmt.Sprintf("Invalid value for %s", envVar))
+		}
+	})
+}
+
+// noChangeExitCode the exit code used by --fail-if-no-change when the requested change is a no-op
+const noChangeExitCode = 3
+
+// handleNoChange reports that the requested change is a no-op, exiting when failIfNoChange is set
+func handleNoChange(failIfNoChange bool, message string) {
+	if failIfNoChange {
+		utils.ErrExit(errors.New(message), noChangeExitCode, "No changes were made")
+	}
+	if !utils.Silent && !utils.OutputJSON {
+		utils.Log(fmt.Sprintf("No changes: %s", message))
+	}
+}
+
+// emptyResultExitCode the exit code used by --fail-empty when a listing has no results
+const emptyResultExitCode = 4
+
+// handleEmptyResult exits when a listing has no results and --fail-empty is set. Deprecated commands that share
+// the listing's Run function don't define the flag, so they're unaffected.
+func handleEmptyResult(cmd *cobra.Command, count int, noun string) {
+	if count == 0 && utils.GetBoolFlagIfChanged(cmd, "fail-empty", false) {
+		utils.ErrExit(fmt.Errorf("no %s found", noun), emptyResultExitCode)
+	}
+}
+
+// noFilterMatchExitCode the exit code used when a listing's --filter pattern doesn't match anything
+const noFilterMatchExitCode = 6
+
+// handleNoFilterMatches exits when a listing's --filter pattern doesn't match anything. Unlike --fail-empty, this is
+// always an error, since a pattern that matches nothing usually indicates a typo.
+func handleNoFilterMatches(count int, pattern string, noun string) {
+	if count == 0 {
+		utils.ErrExit(fmt.Errorf("no %s match %q", noun, pattern), noFilterMatchExitCode)
+	}
+}
+
+// partialFailureExitCode the exit code used by --output-on-error when some of the items couldn't be processed
+const partialFailureExitCode = 5
+
+// logFailures logs the items that couldn't be processed to stderr, keeping stdout clean for the results
+func logFailures(errs []controllers.Error) {
+	for _, err := range errs {
+		utils.LogWarning(fmt.Sprintf("%s: %s", err.Message, err.Unwrap()))
+	}
+}
+
+// handlePartialFailure exits once the successful results have been printed, when --output-on-error is set and some of
+// the items couldn't be processed. Deprecated commands that share the Run function don't define the flag.
+func handlePartialFailure(cmd *cobra.Command, failed int, total int, noun string) {
+	if failed > 0 && utils.GetBoolFlagIfChanged(cmd, "output-on-error", false) {
+		utils.ErrExit(fmt.Errorf("unable to fetch %d of %d %s", failed, total, noun), partialFailureExitCode)
+	}
+}
+
+// runSuccessHook runs the --on-success hook, if any, after a mutation succeeds
+func runSuccessHook(cmd *cobra.Command, event string, project string, config string, extra map[string]string) {
+	hook := cmd.Flag("on-success").Value.String()
+	controllers.RunHook(hook, controllers.HookEvent{Name: event, Project: project, Config: config, Extra: extra})
+}
+
 func deprecatedCommand(newCommand string) {
 	if newCommand == "" {
 		utils.LogWarning("This command is deprecated")
@@ -157,6 +342,8 @@ func Execute() {
 	// initialize the wait group before executing the command
 	global.WaitGroup = new(sync.WaitGroup)
 
+	utils.HandleInterrupts()
+
 	err := rootCmd.Execute()
 
 	// wait for group before checking error
@@ -173,13 +360,20 @@ func init() {
 	rootCmd.Flags().BoolP("version", "v", false, "Get the version of the Doppler CLI")
 
 	rootCmd.PersistentFlags().StringP("token", "t", "", "doppler token")
+	rootCmd.PersistentFlags().Bool("token-stdin", false, "read the doppler token from the first line of stdin (e.g. echo \"$TOKEN\" | doppler configs --token-stdin). keeps the token out of the process list and environment")
 	rootCmd.PersistentFlags().String("api-host", "https://api.doppler.com", "The host address for the Doppler API")
 	rootCmd.PersistentFlags().String("dashboard-host", "https://dashboard.doppler.com", "The host address for the Doppler Dashboard")
+	rootCmd.PersistentFlags().String("host-preset", "", "use the API and dashboard hosts saved in the named preset. see 'doppler configure hosts'")
+	rootCmd.RegisterFlagCompletionFunc("host-preset", hostPresetsValidArgs)
 	rootCmd.PersistentFlags().Bool("no-check-version", !version.PerformVersionCheck, "disable checking for Doppler CLI updates")
 	rootCmd.PersistentFlags().Bool("no-verify-tls", false, "do not verify the validity of TLS certificates on HTTP requests (not recommended)")
+	rootCmd.PersistentFlags().BoolVar(&http.AllowPlaintextHTTP, "insecure-allow-plaintext-http", http.AllowPlaintextHTTP, "allow requests to http:// API hosts, sending your token unencrypted (not recommended)")
 	rootCmd.PersistentFlags().Bool("no-timeout", !http.UseTimeout, "disable http timeout")
-	rootCmd.PersistentFlags().DurationVar(&http.TimeoutDuration, "timeout", http.TimeoutDuration, "max http request duration")
-	rootCmd.PersistentFlags().IntVar(&http.RequestAttempts, "attempts", http.RequestAttempts, "number of http request attempts made before failing")
+	rootCmd.PersistentFlags().DurationVar(&http.TimeoutDuration, "timeout", http.TimeoutDuration, "max http request duration. streamed responses (e.g. 'secrets download --stream') are only limited while connecting and waiting for the response to start")
+	rootCmd.PersistentFlags().IntVar(&http.RequestAttempts, "attempts", http.RequestAttempts, "number of http request attempts made before failing. reads are retried on timeouts and server errors, while writes are only retried if they couldn't be sent or were rate limited. retries back off exponentially, or wait as long as the server's Retry-After header requests")
+	rootCmd.PersistentFlags().String("min-tls-version", "1.2", "minimum TLS version to use for http requests. one of [1.2, 1.3]")
+	rootCmd.PersistentFlags().StringSlice("tls-ciphers", []string{}, "comma separated list of cipher suites allowed for TLS 1.2 connections (e.g. TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384). TLS 1.3 cipher suites aren't configurable")
+	rootCmd.PersistentFlags().StringVar(&http.UserAgent, "user-agent", http.UserAgent, "User-Agent header to send with http requests")
 	// DNS resolver
 	rootCmd.PersistentFlags().Bool("no-dns-resolver", !http.UseCustomDNSResolver, "use the OS's default DNS resolver")
 	if err := rootCmd.PersistentFlags().MarkDeprecated("no-dns-resolver", "the DNS resolver is disabled by default"); err != nil {
@@ -193,9 +387,10 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&http.DNSResolverProto, "dns-resolver-proto", http.DNSResolverProto, "protocol to use for DNS resolution")
 	rootCmd.PersistentFlags().DurationVar(&http.DNSResolverTimeout, "dns-resolver-timeout", http.DNSResolverTimeout, "max dns lookup duration")
 
-	rootCmd.PersistentFlags().Bool("no-read-env", false, "do not read config from the environment")
+	rootCmd.PersistentFlags().Bool("no-read-env", false, "do not read config from the environment. by default, unspecified output and request flags are read from DOPPLER_<FLAG> environment variables (e.g. DOPPLER_JSON=true)")
 	rootCmd.PersistentFlags().String("scope", configuration.Scope, "the directory to scope your config to")
 	rootCmd.PersistentFlags().String("config-dir", configuration.UserConfigDir, "config directory")
+	rootCmd.PersistentFlags().String("config-format", "", fmt.Sprintf("format of the config file. one of %v. by default the format is detected from the file's extension, falling back to yaml", configuration.ConfigFormats))
 	rootCmd.PersistentFlags().String("configuration", configuration.UserConfigFile, "config file")
 	if err := rootCmd.PersistentFlags().MarkDeprecated("configuration", "please use --config-dir instead"); err != nil {
 		utils.HandleError(err)
@@ -204,7 +399,16 @@ func init() {
 		utils.HandleError(err)
 	}
 	rootCmd.PersistentFlags().BoolVar(&utils.OutputJSON, "json", utils.OutputJSON, "output json")
+	rootCmd.PersistentFlags().BoolVar(&utils.JSONEnvelope, "json-envelope", utils.JSONEnvelope, "wrap json list output in an object containing the results (\"data\") and pagination info (\"meta\"). implies --json")
 	rootCmd.PersistentFlags().BoolVar(&utils.Debug, "debug", utils.Debug, "output additional information")
 	rootCmd.PersistentFlags().BoolVar(&printConfig, "print-config", printConfig, "output active configuration")
 	rootCmd.PersistentFlags().BoolVar(&utils.Silent, "silent", utils.Silent, "disable output of info messages")
+	rootCmd.PersistentFlags().Bool("no-color", false, "disable colored output. also enabled by setting the NO_COLOR environment variable")
+	rootCmd.PersistentFlags().BoolVar(&utils.PlainErrors, "plain-errors", utils.PlainErrors, "disable colored error, warning, and debug messages, even when stderr is a terminal. color is always omitted when stderr isn't a terminal")
+	rootCmd.PersistentFlags().StringVar(&utils.ErrorFormat, "error-format", utils.ErrorFormat, fmt.Sprintf("format in which errors are printed to stderr, independent of --json. json prints a single line object with the code, message, underlying error, requestId, and exitCode. one of %v", utils.ErrorFormats))
+	rootCmd.PersistentFlags().String("on-success", "", "command to run after a create, update, delete, or rollback succeeds. details are passed via the DOPPLER_EVENT, DOPPLER_PROJECT, and DOPPLER_CONFIG environment variables")
+	rootCmd.PersistentFlags().IntVar(&utils.Concurrency, "concurrency", utils.Concurrency, "max number of requests made in parallel by commands that operate on multiple projects or configs. use 1 to run sequentially with deterministic ordering, which is recommended for reproducible CI logs")
+	rootCmd.PersistentFlags().IntVar(&utils.PageSize, "page-size", utils.PageSize, fmt.Sprintf("number of items fetched per request by commands that page through listings (e.g. 'configs logs export' and 'secrets history'). larger pages make fewer requests but use more memory. max %d", utils.MaxPageSize))
+	rootCmd.PersistentFlags().String("timezone", "local", "time zone in which to display timestamps in tables, e.g. UTC or America/New_York. json output always contains the original values")
+	rootCmd.PersistentFlags().BoolVar(&utils.NoPager, "no-pager", utils.NoPager, "do not pipe long output through a pager. the pager can be set via DOPPLER_PAGER or PAGER")
 }
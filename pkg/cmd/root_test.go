@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFlagsFromEnvironment(t *testing.T) {
+	t.Setenv("DOPPLER_TIMEZONE", "UTC")
+	t.Setenv("DOPPLER_PAGE_SIZE", "50")
+	t.Setenv("DOPPLER_FORCE", "true")
+	t.Setenv("DOPPLER_REPLACE", "true")
+	t.Setenv("DOPPLER_INSECURE_ALLOW_PLAINTEXT_HTTP", "true")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("timezone", "local", "")
+	cmd.Flags().Int("page-size", 25, "")
+	cmd.Flags().Bool("force", false, "")
+	cmd.Flags().Bool("replace", false, "")
+	cmd.Flags().Bool("insecure-allow-plaintext-http", false, "")
+	assert.NoError(t, cmd.Flags().Set("page-size", "10"))
+
+	loadFlagsFromEnvironment(cmd)
+	assert.Equal(t, "UTC", cmd.Flag("timezone").Value.String())
+	// flags specified on the command line take precedence
+	assert.Equal(t, "10", cmd.Flag("page-size").Value.String())
+	// flags that change what a command does or disable a safety check are never read from the environment
+	assert.False(t, cmd.Flag("force").Changed)
+	assert.False(t, cmd.Flag("replace").Changed)
+	assert.False(t, cmd.Flag("insecure-allow-plaintext-http").Changed)
+}
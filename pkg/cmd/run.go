@@ -18,6 +18,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -50,10 +51,18 @@ var runCmd = &cobra.Command{
 	Long: `Run a command with secrets injected into the environment.
 Secrets can also be mounted to an ephemeral file using the --mount flag.
 
+Commands can be specified either as args after "--" or as a single string via --command, which is run by the
+platform shell ($SHELL -c or sh -c, and cmd /C on Windows) so that pipes, redirects, and operators like "&&" work.
+The two forms are mutually exclusive. Because --command is interpreted by a shell, never build it from untrusted
+input (including secret values), as doing so allows arbitrary commands to be injected.
+
+In either form, signals are forwarded to the command and the CLI exits with the command's exit code.
+
 To view the CLI's active configuration, run ` + "`doppler configure debug`",
 	Example: `doppler run -- YOUR_COMMAND --YOUR-FLAG
 doppler run --command "YOUR_COMMAND && YOUR_OTHER_COMMAND"
-doppler run --mount secrets.json -- cat secrets.json`,
+doppler run --mount secrets.json -- cat secrets.json
+doppler run --mask -- YOUR_COMMAND`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		// The --command flag and args are mututally exclusive
 		usingCommandFlag := cmd.Flags().Changed("command")
@@ -78,11 +87,14 @@ doppler run --mount secrets.json -- cat secrets.json`,
 		fallbackReadonly := utils.GetBoolFlag(cmd, "fallback-readonly")
 		fallbackOnly := utils.GetBoolFlag(cmd, "fallback-only")
 		exitOnWriteFailure := !utils.GetBoolFlag(cmd, "no-exit-on-write-failure")
+		fallbackMaxAge := utils.GetDurationFlag(cmd, "fallback-max-age")
 		preserveEnv := cmd.Flag("preserve-env").Value.String()
+		includeEmpty := utils.GetBoolFlag(cmd, "include-empty")
 		forwardSignals := utils.GetBoolFlag(cmd, "forward-signals")
 		localConfig := configuration.LocalConfig(cmd)
 		dynamicSecretsTTL := utils.GetDurationFlag(cmd, "dynamic-ttl")
 		exitOnMissingIncludedSecrets := !cmd.Flags().Changed("no-exit-on-missing-only-secrets")
+		mask := utils.GetBoolFlag(cmd, "mask")
 
 		utils.RequireValue("token", localConfig.Token.Value)
 
@@ -110,13 +122,17 @@ doppler run --mount secrets.json -- cat secrets.json`,
 			metadataPath = controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, format, nameTransformer, secretsToInclude)
 		}
 
-		passphrase := getPassphrase(cmd, "passphrase", localConfig)
+		passphraseFlag := "passphrase"
+		if cmd.Flags().Changed("fallback-passphrase") {
+			passphraseFlag = "fallback-passphrase"
+		}
+		passphrase := getPassphrase(cmd, passphraseFlag, localConfig)
 		if passphrase == "" {
 			utils.HandleError(errors.New("invalid passphrase"))
 		}
 
 		if !enableFallback {
-			flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "passphrase"}
+			flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "passphrase", "fallback-passphrase", "fallback-max-age"}
 			for _, flag := range flags {
 				if cmd.Flags().Changed(flag) {
 					utils.LogWarning(fmt.Sprintf("--%s has no effect when the fallback file is disabled", flag))
@@ -125,13 +141,15 @@ doppler run --mount secrets.json -- cat secrets.json`,
 		}
 
 		fallbackOpts := controllers.FallbackOptions{
-			Enable:             enableFallback,
-			Path:               fallbackPath,
-			LegacyPath:         legacyFallbackPath,
-			Readonly:           fallbackReadonly,
-			Exclusive:          fallbackOnly,
-			ExitOnWriteFailure: exitOnWriteFailure,
-			Passphrase:         passphrase,
+			Enable:              enableFallback,
+			Path:                fallbackPath,
+			LegacyPath:          legacyFallbackPath,
+			Readonly:            fallbackReadonly,
+			Exclusive:           fallbackOnly,
+			ExitOnWriteFailure:  exitOnWriteFailure,
+			Passphrase:          passphrase,
+			PromptForPassphrase: canPromptForPassphrase(cmd, passphraseFlag),
+			MaxAge:              fallbackMaxAge,
 		}
 
 		mountPath := cmd.Flag("mount").Value.String()
@@ -232,6 +250,9 @@ doppler run --mount secrets.json -- cat secrets.json`,
 			}
 
 			controllers.ValidateSecrets(secrets, secretsToInclude, exitOnMissingIncludedSecrets, mountOptions)
+			if !includeEmpty {
+				secrets = controllers.OmitEmptySecrets(secrets)
+			}
 
 			isRestart := c != nil
 			// terminate the old process
@@ -286,8 +307,27 @@ doppler run --mount secrets.json -- cat secrets.json`,
 				utils.Log("Restarting process")
 			}
 
+			var stdout io.Writer = os.Stdout
+			var stderr io.Writer = os.Stderr
+			var flushOutput func()
+			if mask {
+				values := controllers.SecretValuesToMask(secrets)
+				stdoutRedactor := utils.NewRedactWriter(os.Stdout, values)
+				stderrRedactor := utils.NewRedactWriter(os.Stderr, values)
+				stdout = stdoutRedactor
+				stderr = stderrRedactor
+				flushOutput = func() {
+					if e := stdoutRedactor.Flush(); e != nil {
+						utils.LogDebugError(e)
+					}
+					if e := stderrRedactor.Flush(); e != nil {
+						utils.LogDebugError(e)
+					}
+				}
+			}
+
 			// start the process
-			c, err = controllers.Run(cmd, args, env, forwardSignals)
+			c, err = controllers.Run(cmd, args, env, stdout, stderr, forwardSignals)
 			if err != nil {
 				defer global.WaitGroup.Done()
 				if cleanupMount != nil {
@@ -302,6 +342,10 @@ doppler run --mount secrets.json -- cat secrets.json`,
 
 				exitCode, err := utils.WaitCommand(c)
 
+				if flushOutput != nil {
+					flushOutput()
+				}
+
 				if cleanupMount != nil {
 					cleanupMount()
 
@@ -493,6 +537,15 @@ func legacyFallbackFile(project string, config string) string {
 	return filepath.Join(defaultFallbackDir, fileName)
 }
 
+// canPromptForPassphrase returns whether the user can be prompted for the fallback file's passphrase when the computed one
+// fails to decrypt it. an explicitly specified passphrase is never replaced by a prompt.
+func canPromptForPassphrase(cmd *cobra.Command, flag string) bool {
+	if cmd.Flags().Changed(flag) || (configuration.CanReadEnv && os.Getenv("DOPPLER_PASSPHRASE") != "") {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stderr.Fd())
+}
+
 // generate the passphrase used for encrypting a secrets file
 func getPassphrase(cmd *cobra.Command, flag string, config models.ScopedOptions) string {
 	if cmd.Flags().Changed(flag) {
@@ -566,7 +619,7 @@ func init() {
 	runCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	runCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	runCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
-	runCmd.Flags().String("command", "", "command to execute (e.g. \"echo hi\")")
+	runCmd.Flags().String("command", "", "command string to execute via the shell (e.g. \"echo hi && echo bye\"). the string is interpreted by the shell, so never include untrusted input")
 	// note: requires using "--preserve-env=VALUE", doesn't work with "--preserve-env VALUE"
 	runCmd.Flags().String("preserve-env", "false", "a comma separated list of secrets for which the existing value from the environment, if any, should take precedence of the Doppler secret value. value must be specified with an equals sign (e.g. --preserve-env=\"FOO,BAR\"). specify \"true\" to give precedence to all existing environment values, however this has potential security implications and should be used at your own risk.")
 	// we must specify a default when no value is passed as this flag used to be a boolean
@@ -581,11 +634,14 @@ func init() {
 	runCmd.Flags().String("fallback", "", "path to the fallback file. encrypted secrets are written to this file after each successful fetch. secrets will be read from this file if subsequent connections are unsuccessful.")
 	// TODO rename this to 'fallback-passphrase' in CLI v4 (DPLR-435)
 	runCmd.Flags().String("passphrase", "", "passphrase to use for encrypting the fallback file. the default passphrase is computed using your current configuration.")
+	runCmd.Flags().String("fallback-passphrase", "", "alias for --passphrase")
+	runCmd.MarkFlagsMutuallyExclusive("passphrase", "fallback-passphrase")
 	runCmd.Flags().Bool("no-cache", false, "disable using the fallback file to speed up fetches. the fallback file is only used when the API indicates that it's still current.")
 	runCmd.Flags().Bool("no-fallback", false, "disable reading and writing the fallback file (implies --no-cache)")
 	runCmd.Flags().Bool("fallback-readonly", false, "disable modifying the fallback file. secrets can still be read from the file.")
 	runCmd.Flags().Bool("fallback-only", false, "read all secrets directly from the fallback file, without contacting Doppler. secrets will not be updated. (implies --fallback-readonly)")
 	runCmd.Flags().Bool("no-exit-on-write-failure", false, "do not exit if unable to write the fallback file")
+	runCmd.Flags().Duration("fallback-max-age", 0, "fail rather than read secrets from a fallback file that was last updated longer ago than this duration (e.g. '24h'). the file is updated after each successful fetch. by default there's no limit")
 	runCmd.Flags().Bool("forward-signals", forwardSignals, "forward signals to the child process (defaults to false when STDOUT is a TTY)")
 	// secrets mount flags
 	runCmd.Flags().String("mount", "", "write secrets to an ephemeral file, accessible at DOPPLER_CLI_SECRETS_PATH. when enabled, secrets are NOT injected into the environment")
@@ -596,8 +652,10 @@ func init() {
 	runCmd.Flags().String("mount-template", "", "template file to use. secrets will be rendered into this template before mount. see 'doppler secrets substitute' for more info.")
 	runCmd.Flags().Int("mount-max-reads", 0, "maximum number of times the mounted secrets file can be read (0 for unlimited)")
 	runCmd.Flags().StringSliceVar(&secretsToInclude, "only-secrets", []string{}, "only include the specified secrets")
+	runCmd.Flags().Bool("include-empty", true, "include secrets with empty values, setting their environment variables to an empty string. use --include-empty=false to omit them instead, leaving any value inherited from the environment in place (or omitting them from the mounted file)")
 	runCmd.Flags().Bool("no-exit-on-missing-only-secrets", false, "do not exit on missing secrets via --only-secrets")
 	// we only restart the process if it hasn't already exited
+	runCmd.Flags().Bool("mask", false, fmt.Sprintf("replace secret values in the command's stdout and stderr with \"***\". the command's output is no longer written directly to the terminal, which adds overhead and may change how the command buffers or colors its output. output that may be the start of a secret is held back for up to %s to check for the rest, so a secret printed in pieces further apart than that may not be masked", utils.RedactFlushDelay))
 	runCmd.Flags().Bool("watch", false, "(BETA) automatically restart the process when secrets change")
 
 	// deprecated
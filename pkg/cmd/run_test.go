@@ -0,0 +1,45 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunIncludeEmpty(t *testing.T) {
+	t.Setenv("INHERITED", "kept")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"FULL":"value","EMPTY":"","INHERITED":""}`)) // #nosec G104
+	}
+	// print each variable to stderr, which is captured, distinguishing unset variables from empty ones
+	command := `echo "FULL=${FULL-unset} EMPTY=${EMPTY-unset} INHERITED=${INHERITED-unset}" >&2`
+
+	t.Run("default", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler, "run", "--command", command, "--no-fallback", "-p", "backend", "-c", "dev")
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stderr, "FULL=value EMPTY= INHERITED=\n")
+	})
+
+	t.Run("omit empty", func(t *testing.T) {
+		// empty secrets are omitted from the environment, leaving inherited values in place
+		code, stderr := executeCommandExit(t, handler, "run", "--command", command, "--include-empty=false", "--no-fallback", "-p", "backend", "-c", "dev")
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stderr, "FULL=value EMPTY=unset INHERITED=kept\n")
+	})
+}
@@ -20,9 +20,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/DopplerHQ/cli/pkg/configuration"
@@ -32,7 +34,9 @@ import (
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/printer"
 	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"gopkg.in/gookit/color.v1"
 )
 
 type secretsResponse struct {
@@ -43,8 +47,13 @@ type secretsResponse struct {
 var secretsCmd = &cobra.Command{
 	Use:   "secrets",
 	Short: "Manage secrets",
-	Args:  cobra.NoArgs,
-	Run:   secrets,
+	Long: `List the config's secrets. Values are masked unless --reveal is specified, while --json output always
+includes them. Use 'doppler secrets get' to print specific values.`,
+	Example: `doppler secrets
+doppler secrets --reveal
+doppler secrets --only-names`,
+	Args: cobra.NoArgs,
+	Run:  secrets,
 }
 
 var secretsGetCmd = &cobra.Command{
@@ -53,7 +62,22 @@ var secretsGetCmd = &cobra.Command{
 	Long: `Get the value of one or more secrets.
 
 Ex: output the secrets "API_KEY" and "CRYPTO_KEY":
-doppler secrets get API_KEY CRYPTO_KEY`,
+doppler secrets get API_KEY CRYPTO_KEY
+
+Ex: output the values of "API_KEY" and "CRYPTO_KEY", one per line in the order requested:
+doppler secrets get API_KEY CRYPTO_KEY --plain
+
+Ex: output the values of "API_KEY" and "CRYPTO_KEY" separated by null bytes, for use with xargs -0:
+doppler secrets get API_KEY CRYPTO_KEY --plain --output-delimiter '\0' | xargs -0 ./configure
+
+Ex: output an empty line (and log a warning) for secrets that don't exist, rather than failing:
+doppler secrets get API_KEY OPTIONAL_KEY --plain --no-exit-on-missing-secret
+
+Ex: output the "host" field of the JSON secret "DB_CONFIG":
+doppler secrets get DB_CONFIG --json-path .host
+
+Ex: write the value of "TLS_KEY" to a file, without a trailing newline:
+doppler secrets get TLS_KEY --plain --no-newline > tls.key`,
 	Args:              cobra.MinimumNArgs(1),
 	ValidArgsFunction: secretNamesValidArgs,
 	Run:               getSecrets,
@@ -80,22 +104,51 @@ value
 $ doppler secrets set API_KEY '123'
 
 4) multiple secrets
-$ doppler secrets set API_KEY='123' DATABASE_URL='postgres:random@127.0.0.1:5432'`,
+$ doppler secrets set API_KEY='123' DATABASE_URL='postgres:random@127.0.0.1:5432'
+
+5) from files, where a value beginning with @ is read from the specified path
+$ doppler secrets set TLS_CERT=@cert.pem TLS_KEY=@key.pem
+
+The file's contents are stored exactly, including any trailing newline, unless --trim is specified.
+Windows line endings (CRLF) are converted to LF unless --keep-crlf is specified.
+To set a value that begins with @, use method 1 or 3.
+
+With --if-not-exists, secrets that already exist in the config are left unchanged, which is useful for seeding
+default values:
+$ doppler secrets set LOG_LEVEL=info PORT=8080 --if-not-exists
+
+Afterwards, a summary lists the secrets that were added, changed, and left unchanged. Values are only
+included with --reveal.`,
 	Args: cobra.MinimumNArgs(1),
 	Run:  setSecrets,
 }
 
 var secretsUploadCmd = &cobra.Command{
-	Use:   "upload <filepath>",
+	Use:   "upload [filepath]",
 	Short: "Upload a secrets file",
-	Long: `Upload a json or env secrets file.
+	Long: `Upload a json or env secrets file. By default, the file is merged into the existing secrets.
+A file of "-" reads the secrets from stdin. Doppler's metadata secrets (e.g. DOPPLER_CONFIG) are ignored, so the
+output of 'doppler secrets download' can be uploaded to another config.
 
 Ex: upload an env file:
 doppler secrets upload dev.env
 
 Ex: upload a json file:
-doppler secrets upload secrets.json`,
-	Args: cobra.ExactArgs(1),
+doppler secrets upload secrets.json
+
+Ex: replace all secrets with the contents of a yaml file:
+doppler secrets upload secrets.yaml --replace
+
+Ex: upload an env file containing values like TLS_CERT=@cert.pem, reading those values from the referenced files:
+doppler secrets upload dev.env --file-values
+
+Whitespace is stripped from the start and end of env file values, but not from JSON or YAML values or from the
+contents of files read with --file-values. Use --trim to strip it from all values, or --trim=false to upload every
+value exactly as written.
+
+Ex: copy the secrets of one config to another:
+doppler secrets download --config dev --no-file --format json | doppler secrets upload --config dev_jane --file -`,
+	Args: cobra.MaximumNArgs(1),
 	Run:  uploadSecrets,
 }
 
@@ -111,13 +164,30 @@ doppler secrets delete API_KEY CRYPTO_KEY`,
 	ValidArgsFunction: secretNamesValidArgs,
 }
 
+var secretsHistoryCmd = &cobra.Command{
+	Use:   "history [secret]",
+	Short: "List the changes made to a secret",
+	Long: `List the changes made to a secret, as recorded in the config's audit logs.
+
+Values are redacted unless --reveal is specified.
+
+Ex: show the last 5 changes to "API_KEY":
+doppler secrets history API_KEY --number 5`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: secretNamesValidArgs,
+	Run:               secretHistory,
+}
+
 var validFormatList = strings.Join(models.SecretFormats, ", ")
 var validNameTransformersList = strings.Join(models.SecretsNameTransformerTypes, ", ")
 var validEnvCompatNameTransformersList = strings.Join(models.SecretsEnvCompatNameTransformerTypes, ", ")
 var secretsDownloadCmd = &cobra.Command{
 	Use:   "download <filepath>",
 	Short: "Download a config's secrets for later use",
-	Long:  fmt.Sprintf("Download your config's secrets for later use. Supported formats are %s", validFormatList),
+	Long: fmt.Sprintf(`Download your config's secrets for later use. Supported formats are %s
+
+When the file is inside a git repo and isn't ignored by git, a warning is printed since the secrets
+could be committed. Use --fail-if-committable to exit with an error instead, or --no-git-check to skip this check.`, validFormatList),
 	Example: `Save your secrets to /root/ encrypted in JSON format
 $ doppler secrets download /root/secrets.json
 
@@ -125,7 +195,24 @@ Save your secrets to /root/ encrypted in Env format
 $ doppler secrets download --format=env /root/secrets.env
 
 Print your secrets to stdout in env format without writing to the filesystem
-$ doppler secrets download --format=env --no-file`,
+$ doppler secrets download --format=env --no-file
+
+Print your secrets to the terminal in the default JSON format
+$ doppler secrets download --stdout
+
+Generate a Terraform variables file, which Terraform loads automatically. secrets whose names aren't valid
+Terraform variable names are omitted with a warning
+$ doppler secrets download --format=tfvars --no-file > doppler.auto.tfvars
+
+Generate a systemd drop-in that sets your secrets as environment variables
+$ doppler secrets download --format=systemd --no-file > /etc/systemd/system/app.service.d/doppler.conf
+
+Generate nginx env directives (to include in the main context of nginx.conf), along with a shell file that
+exports the values. source the shell file in the environment that starts nginx
+$ doppler secrets download --format=nginx --no-file --shell-file /etc/nginx/doppler.sh > /etc/nginx/doppler-env.conf
+
+Generate a Kubernetes Secret manifest
+$ doppler secrets download --format=k8s --name my-secret --namespace default --no-file > secret.yaml`,
 	Args: cobra.MaximumNArgs(1),
 	Run:  downloadSecrets,
 }
@@ -151,8 +238,14 @@ $ doppler secrets substitute template.yaml
 host: 127.0.0.1
 port: 8080
 Multiline: "Line one\r\nLine two"
-JSON Secret: "{\"logging\": \"info\"}"`,
-	Args: cobra.ExactArgs(1),
+JSON Secret: "{\"logging\": \"info\"}"
+
+Substitute ${SECRET_NAME} placeholders in an arbitrary text file (e.g. nginx or systemd configs)
+$ doppler secrets substitute --input app.conf.tmpl --output app.conf --missing error
+
+Substitute only ${SECRET_NAME} placeholders, leaving text like {{ unchanged
+$ doppler secrets substitute --syntax env --input app.conf.tmpl --output app.conf`,
+	Args: cobra.MaximumNArgs(1),
 	Run:  substituteSecrets,
 }
 
@@ -161,15 +254,22 @@ func secrets(cmd *cobra.Command, args []string) {
 	raw := utils.GetBoolFlag(cmd, "raw")
 	visibility := utils.GetBoolFlag(cmd, "visibility")
 	onlyNames := utils.GetBoolFlag(cmd, "only-names")
+	showSource := utils.GetBoolFlagIfChanged(cmd, "show-source", false)
+	reveal := utils.GetBoolFlagIfChanged(cmd, "reveal", false)
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
 	if onlyNames {
+		if showSource {
+			utils.LogWarning("--show-source has no effect when used with --only-names")
+		}
+
 		secretNames, err := http.GetSecretNames(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, false)
 		if !err.IsNil() {
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
+		handleEmptyResult(cmd, len(secretNames), "secrets")
 
 		printer.SecretsNames(secretNames, jsonFlag)
 	} else {
@@ -181,8 +281,19 @@ func secrets(cmd *cobra.Command, args []string) {
 		if parseErr != nil {
 			utils.HandleError(parseErr, "Unable to parse API response")
 		}
+		handleEmptyResult(cmd, len(secrets), "secrets")
+
+		if showSource {
+			if err := controllers.AddSecretSources(localConfig, secrets); !err.IsNil() {
+				utils.HandleError(err.Unwrap(), err.Message)
+			}
+		}
 
-		printer.Secrets(secrets, []string{}, jsonFlag, false, raw, false, visibility)
+		// values are masked in tables, which are often displayed on screen, while JSON output is meant for scripts
+		if !reveal && !jsonFlag {
+			secrets = redactSecretValues(secrets)
+		}
+		printer.Secrets(secrets, []string{}, printer.SecretsOptions{JSON: jsonFlag, Raw: raw, Visibility: visibility, Source: showSource})
 	}
 }
 
@@ -193,10 +304,25 @@ func getSecrets(cmd *cobra.Command, args []string) {
 	raw := utils.GetBoolFlag(cmd, "raw")
 	visibility := utils.GetBoolFlag(cmd, "visibility")
 	exitOnMissingSecret := !utils.GetBoolFlag(cmd, "no-exit-on-missing-secret")
+	jsonPath := utils.GetFlagIfChanged(cmd, "json-path", "")
+	parseJSON := utils.GetBoolFlagIfChanged(cmd, "parse-json", false) || jsonPath != ""
+	noNewline := utils.GetBoolFlagIfChanged(cmd, "no-newline", false)
+	delimiter := utils.GetFlagIfChanged(cmd, "output-delimiter", "")
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
+	if delimiter != "" && !plain {
+		utils.HandleError(errors.New("--output-delimiter requires --plain"))
+	}
+
+	if parseJSON && len(args) != 1 {
+		utils.HandleError(errors.New("--parse-json and --json-path require exactly one secret"))
+	}
+	if noNewline && !plain && !parseJSON {
+		utils.HandleError(errors.New("--no-newline requires --plain"))
+	}
+
 	var requestedSecrets []string
 	if len(args) > 0 {
 		requestedSecrets = args
@@ -210,31 +336,119 @@ func getSecrets(cmd *cobra.Command, args []string) {
 		utils.HandleError(parseErr, "Unable to parse API response")
 	}
 
-	if exitOnMissingSecret && len(args) > 0 {
-		var missingSecrets []string
+	var missingSecrets []string
+	for _, name := range args {
+		if secrets[name] == (models.ComputedSecret{}) {
+			missingSecrets = append(missingSecrets, name)
+		}
+	}
+	if len(missingSecrets) > 0 {
+		pluralized := "secrets"
+		if len(missingSecrets) == 1 {
+			pluralized = "secret"
+		}
+		missingErr := fmt.Errorf("Could not find requested %s: %s", pluralized, strings.Join(missingSecrets, ", "))
+		if exitOnMissingSecret {
+			utils.HandleError(missingErr)
+		}
+		utils.LogWarning(missingErr.Error())
+	}
 
-		for _, name := range args {
-			if secrets[name] == (models.ComputedSecret{}) {
-				missingSecrets = append(missingSecrets, name)
-			}
+	if parseJSON {
+		printJSONSecret(secrets[args[0]], raw, jsonPath, noNewline)
+		return
+	}
+
+	if delimiter != "" && !jsonFlag {
+		printDelimitedSecrets(secrets, args, raw, copy, utils.UnescapeDelimiter(delimiter), noNewline)
+		return
+	}
+
+	printer.Secrets(secrets, args, printer.SecretsOptions{JSON: jsonFlag, Plain: plain, Raw: raw, Copy: copy, Visibility: visibility, NoNewline: noNewline})
+}
+
+// printDelimitedSecrets prints the values of the secrets separated by the delimiter. the output ends with a newline
+// unless noNewline is true or the delimiter is a null byte, whose output is meant for tools like 'xargs -0'
+func printDelimitedSecrets(secrets map[string]models.ComputedSecret, names []string, raw bool, copy bool, delimiter string, noNewline bool) {
+	output := strings.Join(printer.PlainSecretValues(secrets, names, raw), delimiter)
+	if copy {
+		if err := utils.CopyToClipboard(output); err != nil {
+			utils.HandleError(err, "Unable to copy to clipboard")
 		}
+	}
 
-		if len(missingSecrets) > 0 {
-			pluralized := "secrets"
-			if len(missingSecrets) == 1 {
-				pluralized = "secret"
-			}
-			utils.HandleError(fmt.Errorf("Could not find requested %s: %s", pluralized, strings.Join(missingSecrets, ", ")))
+	if noNewline || delimiter == "\x00" {
+		utils.PrintRaw(output)
+	} else {
+		utils.Print(output)
+	}
+}
+
+// redactSecretValues returns a copy of the secrets with their non-empty values replaced by utils.RedactedValue
+func redactSecretValues(secrets map[string]models.ComputedSecret) map[string]models.ComputedSecret {
+	redact := func(value *string) *string {
+		if value == nil || *value == "" {
+			return value
+		}
+		redacted := utils.RedactedValue
+		return &redacted
+	}
+
+	redacted := map[string]models.ComputedSecret{}
+	for name, secret := range secrets {
+		secret.ComputedValue = redact(secret.ComputedValue)
+		secret.RawValue = redact(secret.RawValue)
+		redacted[name] = secret
+	}
+	return redacted
+}
+
+// printJSONSecret parses the secret's JSON value and prints it (or the field at jsonPath). Strings are printed
+// without quotes and all other values are pretty printed.
+func printJSONSecret(secret models.ComputedSecret, raw bool, jsonPath string, noNewline bool) {
+	if secret == (models.ComputedSecret{}) {
+		utils.HandleError(errors.New("Could not find requested secret"))
+	}
+
+	value := secret.ComputedValue
+	if raw {
+		value = secret.RawValue
+	}
+	if value == nil {
+		utils.HandleError(fmt.Errorf("the value of secret %s is restricted", secret.Name))
+	}
+
+	parsed, err := utils.ParseJSONValue(*value)
+	if err != nil {
+		utils.HandleError(err, fmt.Sprintf("The value of secret %s is not valid JSON", secret.Name))
+	}
+	if jsonPath != "" {
+		parsed, err = utils.ExtractJSONPath(parsed, jsonPath)
+		if err != nil {
+			utils.HandleError(err, fmt.Sprintf("Unable to extract %s from secret %s", jsonPath, secret.Name))
 		}
 	}
 
-	printer.Secrets(secrets, args, jsonFlag, plain, raw, copy, visibility)
+	formatted, err := utils.FormatJSONValue(parsed)
+	if err != nil {
+		utils.HandleError(err, "Unable to format JSON value")
+	}
+	if noNewline {
+		utils.PrintRaw(formatted)
+	} else {
+		utils.Print(formatted)
+	}
 }
 
 func setSecrets(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
-	raw := utils.GetBoolFlag(cmd, "raw")
 	canPromptUser := !utils.GetBoolFlag(cmd, "no-interactive")
+	reveal := utils.GetBoolFlagIfChanged(cmd, "reveal", false)
+	failIfNoChange := utils.GetBoolFlagIfChanged(cmd, "fail-if-no-change", false)
+	trim := utils.GetBoolFlagIfChanged(cmd, "trim", false)
+	keepCRLF := utils.GetBoolFlagIfChanged(cmd, "keep-crlf", false)
+	dotenvExpand := utils.GetBoolFlagIfChanged(cmd, "dotenv-expand", false)
+	ifNotExists := utils.GetBoolFlagIfChanged(cmd, "if-not-exists", false)
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
@@ -305,6 +519,9 @@ func setSecrets(cmd *cobra.Command, args []string) {
 
 		key := args[0]
 		value := strings.Join(input, "\n")
+		if trim {
+			value = strings.TrimSpace(value)
+		}
 
 		keys = append(keys, key)
 		secrets[key] = value
@@ -315,57 +532,365 @@ func setSecrets(cmd *cobra.Command, args []string) {
 		keys = append(keys, key)
 		secrets[key] = value
 	} else {
-		// format: 'doppler secrets set KEY=value'
+		// format: 'doppler secrets set KEY=value' or 'doppler secrets set KEY=@path'
+		// with --dotenv-expand, references in a value resolve to the values of earlier arguments
+		values := map[string]string{}
 		for _, arg := range args {
 			secretArr := strings.SplitN(arg, "=", 2)
 			keys = append(keys, secretArr[0])
 			if len(secretArr) < 2 {
 				secrets[secretArr[0]] = ""
-			} else {
-				secrets[secretArr[0]] = secretArr[1]
+				values[secretArr[0]] = ""
+				continue
+			}
+
+			value := secretArr[1]
+			if dotenvExpand && !strings.HasPrefix(value, controllers.SecretValueFilePrefix) {
+				expanded, err := utils.ExpandDotEnvReferences(value, values)
+				if err != nil {
+					utils.HandleError(err, fmt.Sprintf("Unable to expand the value of %s", secretArr[0]))
+				}
+				value = expanded
+			}
+			value, err := controllers.ResolveSecretValueFile(value, trim, keepCRLF)
+			if err != nil {
+				utils.HandleError(err, fmt.Sprintf("Unable to read the value of %s from file", secretArr[0]))
 			}
+			secrets[secretArr[0]] = value
+			values[secretArr[0]] = value
 		}
 	}
 
-	response, err := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets, nil)
+	validateSecretNames(cmd, keys)
+
+	// compare against the current values so a no-op can be reported
+	currentResponse, err := http.GetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, keys, false, 0)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+	current, parseErr := models.ParseSecrets(currentResponse)
+	if parseErr != nil {
+		utils.HandleError(parseErr, "Unable to parse API response")
+	}
+
+	if ifNotExists {
+		for _, name := range keys {
+			if _, ok := current[name]; ok {
+				if !utils.Silent && !utils.OutputJSON {
+					utils.Log(fmt.Sprintf("%s is already set", name))
+				}
+				delete(secrets, name)
+			}
+		}
+	}
+
+	values := map[string]string{}
+	for name, value := range secrets {
+		values[name] = value.(string)
+	}
+	changes := controllers.SecretChanges(current, keys, values)
+
+	unchanged := true
+	for _, change := range changes {
+		if change.Change != models.SecretUnchanged {
+			unchanged = false
+			break
+		}
+	}
+	if unchanged {
+		if ifNotExists {
+			handleNoChange(failIfNoChange, "Secrets are already set")
+		} else {
+			handleNoChange(failIfNoChange, "Secrets already have the specified values")
+		}
+		if !utils.Silent {
+			printer.SecretChanges(changes, reveal, jsonFlag)
+		}
+		return
+	}
+
+	_, err = http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets, nil)
 	if !err.IsNil() {
 		utils.HandleError(err.Unwrap(), err.Message)
 	}
 
 	if !utils.Silent {
-		printer.Secrets(response, keys, jsonFlag, false, raw, false, false)
+		printer.SecretChanges(changes, reveal, jsonFlag)
 	}
 }
 
 func uploadSecrets(cmd *cobra.Command, args []string) {
 	jsonFlag := utils.OutputJSON
 	raw := utils.GetBoolFlag(cmd, "raw")
+	replace := utils.GetBoolFlag(cmd, "replace")
+	yes := utils.GetBoolFlag(cmd, "yes")
+	dotenvExpand := utils.GetBoolFlag(cmd, "dotenv-expand")
+	trim := utils.GetBoolFlag(cmd, "trim")
+	// env file values are trimmed unless --trim=false is specified, while other values are only trimmed with --trim
+	trimEnv := trim || !cmd.Flags().Changed("trim")
+	fileValues := utils.GetBoolFlag(cmd, "file-values")
+	keepCRLF := utils.GetBoolFlag(cmd, "keep-crlf")
+	allowDuplicates := utils.GetBoolFlag(cmd, "allow-duplicates")
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
-	filePath, err := utils.GetFilePath(args[0])
+	path := cmd.Flag("file").Value.String()
+	if len(args) > 0 {
+		if path != "" {
+			utils.HandleError(errors.New("specify the upload file as an argument or with --file, but not both"))
+		}
+		path = args[0]
+	}
+	utils.RequireValue("file", path)
+
+	var file []byte
+	var err error
+	if path == "-" {
+		file, err = readUploadStdin()
+	} else {
+		file, err = readUploadFile(path)
+	}
 	if err != nil {
-		utils.HandleError(err, "Unable to parse upload file path")
+		utils.HandleError(err, "Unable to read upload file")
+	}
+	if !keepCRLF {
+		file = []byte(utils.NormalizeLineEndings(string(file)))
+	}
+
+	var expanded map[string]string
+	if dotenvExpand {
+		expanded, err = utils.ParseDotEnv(string(file), true, allowDuplicates)
+		if err != nil {
+			handleEnvFileError(err)
+		}
+		validateSecretNames(cmd, secretNames(expanded))
+		if trimEnv {
+			trimSecretValues(expanded)
+		}
+		if fileValues {
+			resolveSecretValueFiles(expanded, trim, keepCRLF)
+		}
+	}
+
+	if replace {
+		desired := expanded
+		if !dotenvExpand {
+			desired, err = controllers.ParseSecretsFile(file)
+			if err != nil {
+				utils.HandleError(err, "Unable to parse upload file. --replace requires a JSON or YAML file")
+			}
+			validateSecretNames(cmd, secretNames(desired))
+			controllers.RemoveMetadataSecrets(desired)
+			if trim {
+				trimSecretValues(desired)
+			}
+			if fileValues {
+				resolveSecretValueFiles(desired, trim, keepCRLF)
+			}
+		}
+
+		replaceSecrets(localConfig, desired, yes, raw)
+		return
+	}
+
+	if dotenvExpand {
+		setSecretValues(localConfig, expanded, jsonFlag, raw)
+		return
+	}
+
+	// trimming and reading file values requires parsing the file locally rather than letting the API parse it
+	if structured, parseErr := controllers.ParseSecretsFile(file); parseErr == nil {
+		validateSecretNames(cmd, secretNames(structured))
+		// Doppler's metadata secrets (e.g. in a file from 'secrets download') are read-only, so they're removed locally
+		hasMetadata := controllers.RemoveMetadataSecrets(structured)
+		if trim || fileValues || hasMetadata {
+			if trim {
+				trimSecretValues(structured)
+			}
+			if fileValues {
+				resolveSecretValueFiles(structured, trim, keepCRLF)
+			}
+			setSecretValues(localConfig, structured, jsonFlag, raw)
+			return
+		}
+	} else {
+		// env files are always parsed locally to detect duplicate keys, which the API would silently resolve
+		values, parseErr := utils.ParseDotEnv(string(file), false, allowDuplicates)
+		var duplicateErr *utils.DuplicateKeyError
+		if errors.As(parseErr, &duplicateErr) {
+			handleEnvFileError(parseErr)
+		}
+		hasMetadata := false
+		if parseErr == nil {
+			validateSecretNames(cmd, secretNames(values))
+			hasMetadata = controllers.RemoveMetadataSecrets(values)
+		}
+		if parseErr == nil && (trimEnv || fileValues || allowDuplicates || hasMetadata) {
+			if trimEnv {
+				trimSecretValues(values)
+			}
+			if fileValues {
+				resolveSecretValueFiles(values, trim, keepCRLF)
+			}
+			setSecretValues(localConfig, values, jsonFlag, raw)
+			return
+		}
+		if parseErr != nil {
+			if fileValues {
+				utils.HandleError(parseErr, "Unable to parse upload file. --file-values requires a JSON, YAML, or env file")
+			}
+			utils.LogDebug("Unable to parse upload file locally; uploading without trimming values")
+			utils.LogDebugError(parseErr)
+		}
+	}
+
+	response, httpErr := http.UploadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, string(file))
+	if !httpErr.IsNil() {
+		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
 	}
 
+	if !utils.Silent {
+		printer.Secrets(response, []string{}, printer.SecretsOptions{JSON: jsonFlag, Raw: raw})
+	}
+}
+
+// validateSecretNames exits if any of the names are reserved or can't be used as environment variables, unless --force is specified
+func validateSecretNames(cmd *cobra.Command, names []string) {
+	if utils.GetBoolFlagIfChanged(cmd, "force", false) {
+		return
+	}
+	if err := controllers.ValidateSecretNames(names); err != nil {
+		utils.HandleError(err, "", "Use --force to save these secrets anyway")
+	}
+}
+
+// secretNames returns the names of the secrets, sorted
+func secretNames(values map[string]string) []string {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readUploadFile reads the file to upload
+func readUploadFile(path string) ([]byte, error) {
+	filePath, err := utils.GetFilePath(path)
+	if err != nil {
+		return nil, err
+	}
 	if !utils.Exists(filePath) {
-		utils.HandleError(errors.New("Upload file does not exist"))
+		return nil, errors.New("Upload file does not exist")
 	}
+	return ioutil.ReadFile(filePath) // #nosec G304
+}
 
-	var file []byte
-	file, err = ioutil.ReadFile(filePath) // #nosec G304
+// readUploadStdin reads the secrets to upload from stdin (e.g. piped from 'doppler secrets download --no-file')
+func readUploadStdin() ([]byte, error) {
+	hasData, err := utils.HasDataOnStdIn()
 	if err != nil {
-		utils.HandleError(err, "Unable to read upload file")
+		return nil, err
+	}
+	if !hasData {
+		return nil, errors.New("a file of '-' reads the secrets from stdin, but no input was piped to stdin")
 	}
+	return io.ReadAll(os.Stdin)
+}
 
-	response, httpErr := http.UploadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, string(file))
+// trimSecretValues strips leading and trailing whitespace from each value
+func trimSecretValues(values map[string]string) {
+	for name, value := range values {
+		values[name] = strings.TrimSpace(value)
+	}
+}
+
+// handleEnvFileError exits due to an error parsing an env file, suggesting how to resolve duplicate keys
+func handleEnvFileError(err error) {
+	var duplicateErr *utils.DuplicateKeyError
+	if errors.As(err, &duplicateErr) {
+		utils.HandleError(err, "Unable to parse env file", "Remove the duplicate, or use --allow-duplicates to use the last value")
+	}
+	utils.HandleError(err, "Unable to parse env file")
+}
+
+// resolveSecretValueFiles replaces values of the form @path with the contents of the referenced file
+func resolveSecretValueFiles(values map[string]string, trim bool, keepCRLF bool) {
+	for name, value := range values {
+		resolved, err := controllers.ResolveSecretValueFile(value, trim, keepCRLF)
+		if err != nil {
+			utils.HandleError(err, fmt.Sprintf("Unable to read the value of %s from file", name))
+		}
+		values[name] = resolved
+	}
+}
+
+// setSecretValues sets the specified secrets, leaving all other secrets unchanged
+func setSecretValues(localConfig models.ScopedOptions, values map[string]string, jsonFlag bool, raw bool) {
+	secrets := map[string]interface{}{}
+	for name, value := range values {
+		secrets[name] = value
+	}
+
+	response, err := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets, nil)
+	if !err.IsNil() {
+		utils.HandleError(err.Unwrap(), err.Message)
+	}
+
+	if !utils.Silent {
+		printer.Secrets(response, []string{}, printer.SecretsOptions{JSON: jsonFlag, Raw: raw})
+	}
+}
+
+// replaceSecrets makes the config's secrets exactly match the desired secrets
+func replaceSecrets(localConfig models.ScopedOptions, desired map[string]string, yes bool, raw bool) {
+	current, unreadable, controllerErr := controllers.GetRawSecretValues(localConfig)
+	if !controllerErr.IsNil() {
+		utils.HandleError(controllerErr.Unwrap(), controllerErr.Message)
+	}
+
+	diff := controllers.DiffSecrets(current, unreadable, desired)
+	if diff.IsEmpty() {
+		if !utils.Silent && !utils.OutputJSON {
+			utils.Log("Secrets are already up to date")
+		}
+		return
+	}
+
+	// the preview is a status message, so it's kept out of the command's output
+	if !utils.Silent && !utils.OutputJSON {
+		for _, name := range diff.Added {
+			utils.Log(color.Green.Render("+ " + name))
+		}
+		for _, name := range diff.Changed {
+			utils.Log(color.Yellow.Render("~ " + name))
+		}
+		for _, name := range diff.Removed {
+			utils.Log(color.Red.Render("- " + name))
+		}
+	}
+
+	prompt := fmt.Sprintf("Add %d, update %d, and delete %d secret(s)", len(diff.Added), len(diff.Changed), len(diff.Removed))
+	if !yes && !utils.ConfirmationPrompt(prompt, false) {
+		return
+	}
+
+	secrets := map[string]interface{}{}
+	for _, name := range append(diff.Added, diff.Changed...) {
+		secrets[name] = desired[name]
+	}
+	for _, name := range diff.Removed {
+		secrets[name] = nil
+	}
+
+	response, httpErr := http.SetSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, secrets, nil)
 	if !httpErr.IsNil() {
 		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
 	}
 
 	if !utils.Silent {
-		printer.Secrets(response, []string{}, jsonFlag, false, raw, false, false)
+		printer.Secrets(response, []string{}, printer.SecretsOptions{JSON: utils.OutputJSON, Raw: raw})
 	}
 }
 
@@ -389,13 +914,15 @@ func deleteSecrets(cmd *cobra.Command, args []string) {
 		}
 
 		if !utils.Silent {
-			printer.Secrets(response, []string{}, jsonFlag, false, raw, false, false)
+			printer.Secrets(response, []string{}, printer.SecretsOptions{JSON: jsonFlag, Raw: raw})
 		}
 	}
 }
 
 func downloadSecrets(cmd *cobra.Command, args []string) {
-	saveFile := !utils.GetBoolFlag(cmd, "no-file")
+	printToStdout := utils.GetBoolFlagIfChanged(cmd, "stdout", false)
+	saveFile := !utils.GetBoolFlag(cmd, "no-file") && !printToStdout
+	stream := utils.GetBoolFlagIfChanged(cmd, "stream", false)
 	jsonFlag := utils.OutputJSON
 	localConfig := configuration.LocalConfig(cmd)
 
@@ -404,16 +931,27 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 	fallbackReadonly := utils.GetBoolFlag(cmd, "fallback-readonly")
 	fallbackOnly := utils.GetBoolFlag(cmd, "fallback-only")
 	exitOnWriteFailure := !utils.GetBoolFlag(cmd, "no-exit-on-write-failure")
+	fallbackMaxAge := utils.GetDurationFlag(cmd, "fallback-max-age")
 	dynamicSecretsTTL := utils.GetDurationFlag(cmd, "dynamic-ttl")
+	includeEmpty := utils.GetBoolFlagIfChanged(cmd, "include-empty", true)
+	sortKeys := !utils.GetBoolFlagIfChanged(cmd, "no-sort", false)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
+	// avoid accidentally dumping secrets into a (possibly shared) terminal. piped and redirected output is unaffected
+	if !saveFile && !printToStdout && !jsonFlag && !cmd.Flags().Changed("format") && isatty.IsTerminal(os.Stdout.Fd()) {
+		utils.HandleError(errors.New("refusing to print secrets to the terminal. Specify an explicit --format, redirect the output to a file, or use --stdout to print them anyway"))
+	}
+
 	formatString := cmd.Flag("format").Value.String()
 	var format models.SecretsFormat
 	if jsonFlag {
 		format = models.JSON
 	}
 
+	if formatString == "kubernetes" {
+		formatString = models.KUBERNETES.String()
+	}
 	if formatString != "" {
 		isValid := false
 
@@ -439,54 +977,173 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// read with GetFlagIfChanged since `enclave secrets download` doesn't define these flags
+	kubernetesOpts := controllers.KubernetesSecretOptions{
+		Name:      utils.GetFlagIfChanged(cmd, "name", ""),
+		Namespace: utils.GetFlagIfChanged(cmd, "namespace", ""),
+		Type:      utils.GetFlagIfChanged(cmd, "type", controllers.DefaultKubernetesSecretType),
+	}
+	if format == models.KUBERNETES {
+		if kubernetesOpts.Name == "" {
+			utils.HandleError(fmt.Errorf("--name is required when format is %s", format))
+		}
+	} else {
+		for _, flag := range []string{"name", "namespace", "type"} {
+			if cmd.Flags().Changed(flag) {
+				utils.LogWarning(fmt.Sprintf("--%s has no effect unless format is %s", flag, models.KUBERNETES))
+			}
+		}
+	}
+
+	// nginx's env directives only pass through variables from nginx's own environment, so the values are written to
+	// a companion shell file. read with GetFlagIfChanged since `enclave secrets download` doesn't define the flag
+	shellFilePath := utils.GetFlagIfChanged(cmd, "shell-file", "")
+	if format == models.NGINX && shellFilePath == "" {
+		utils.HandleError(fmt.Errorf("--shell-file is required when format is %s", format))
+	}
+	if format != models.NGINX && shellFilePath != "" {
+		utils.HandleError(fmt.Errorf("--shell-file can only be used when format is %s", models.NGINX))
+	}
+	if shellFilePath != "" {
+		var err error
+		shellFilePath, err = utils.GetFilePath(shellFilePath)
+		if err != nil {
+			utils.HandleError(err, "Unable to parse shell file path")
+		}
+	}
+
+	if stream {
+		if saveFile || format != models.JSON {
+			utils.HandleError(errors.New("--stream can only be used with --no-file and the json format"))
+		}
+		if !includeEmpty {
+			utils.HandleError(errors.New("--include-empty=false can't be used with --stream"))
+		}
+
+		// the fallback file requires the full set of secrets, so it's not supported when streaming
+		flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "fallback-passphrase", "fallback-max-age"}
+		for _, flag := range flags {
+			if cmd.Flags().Changed(flag) {
+				utils.LogWarning(fmt.Sprintf("--%s has no effect when used with --stream", flag))
+			}
+		}
+
+		apiError := http.StreamSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, nameTransformer, dynamicSecretsTTL, func(r io.Reader) error {
+			return controllers.StreamSecretsJSON(r, os.Stdout)
+		})
+		if !apiError.IsNil() {
+			utils.HandleError(apiError.Unwrap(), apiError.Message)
+		}
+		return
+	}
+
 	fallbackPassphrase := getPassphrase(cmd, "fallback-passphrase", localConfig)
 	if fallbackPassphrase == "" {
 		utils.HandleError(errors.New("invalid fallback file passphrase"))
 	}
 
+	// formats rendered by the CLI are generated from the JSON secrets
+	fetchFormat := format
+	if format.ClientRendered() {
+		fetchFormat = models.JSON
+	}
+
 	var body []byte
-	if format == models.JSON {
+	if fetchFormat == models.JSON {
 		fallbackPath := ""
 		legacyFallbackPath := ""
 		metadataPath := ""
 		if enableFallback {
-			fallbackPath, legacyFallbackPath = initFallbackDir(cmd, localConfig, format, nameTransformer, nil, exitOnWriteFailure)
+			fallbackPath, legacyFallbackPath = initFallbackDir(cmd, localConfig, fetchFormat, nameTransformer, nil, exitOnWriteFailure)
 		}
 		if enableCache {
-			metadataPath = controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, format, nameTransformer, nil)
+			metadataPath = controllers.MetadataFilePath(localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, fetchFormat, nameTransformer, nil)
 		}
 
 		fallbackOpts := controllers.FallbackOptions{
-			Enable:             enableFallback,
-			Path:               fallbackPath,
-			LegacyPath:         legacyFallbackPath,
-			Readonly:           fallbackReadonly,
-			Exclusive:          fallbackOnly,
-			ExitOnWriteFailure: exitOnWriteFailure,
-			Passphrase:         fallbackPassphrase,
+			Enable:              enableFallback,
+			Path:                fallbackPath,
+			LegacyPath:          legacyFallbackPath,
+			Readonly:            fallbackReadonly,
+			Exclusive:           fallbackOnly,
+			ExitOnWriteFailure:  exitOnWriteFailure,
+			Passphrase:          fallbackPassphrase,
+			PromptForPassphrase: canPromptForPassphrase(cmd, "fallback-passphrase"),
+			MaxAge:              fallbackMaxAge,
+		}
+		secrets := controllers.FetchSecrets(localConfig, enableCache, fallbackOpts, metadataPath, nameTransformer, dynamicSecretsTTL, fetchFormat, nil)
+		if !includeEmpty {
+			secrets = controllers.OmitEmptySecrets(secrets)
 		}
-		secrets := controllers.FetchSecrets(localConfig, enableCache, fallbackOpts, metadataPath, nameTransformer, dynamicSecretsTTL, format, nil)
 
 		var err error
-		body, err = json.Marshal(secrets)
-		if err != nil {
-			utils.HandleError(err, "Unable to parse JSON secrets")
+		if format == models.KUBERNETES {
+			body, err = controllers.FormatKubernetesSecret(secrets, kubernetesOpts)
+			if err != nil {
+				utils.HandleError(err, "Unable to format secrets as a Kubernetes Secret")
+			}
+		} else if format.ClientRendered() {
+			body, err = controllers.FormatSecrets(secrets, format)
+			if err != nil {
+				utils.HandleError(err, fmt.Sprintf("Unable to format secrets as %s", format))
+			}
+			if format == models.NGINX {
+				writeNginxShellFile(cmd, shellFilePath, secrets)
+			}
+		} else {
+			body, err = json.Marshal(secrets)
+			if err != nil {
+				utils.HandleError(err, "Unable to parse JSON secrets")
+			}
 		}
 	} else {
-		// fallback file is not supported when fetching env/yaml format
+		// fallback file is not supported when fetching a format rendered by the API
 		enableFallback = false
 		enableCache = false
-		flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure"}
+		flags := []string{"fallback", "fallback-only", "fallback-readonly", "no-exit-on-write-failure", "fallback-max-age"}
 		for _, flag := range flags {
 			if cmd.Flags().Changed(flag) {
 				utils.LogWarning(fmt.Sprintf("--%s has no effect when format is %s", flag, format))
 			}
 		}
 
-		var apiError http.Error
-		_, _, body, apiError = http.DownloadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, format, nameTransformer, "", dynamicSecretsTTL, nil)
-		if !apiError.IsNil() {
-			utils.HandleError(apiError.Unwrap(), apiError.Message)
+		// these formats are rendered by the API, so empty secrets are excluded by only requesting the non-empty ones
+		var secretNames []string
+		if !includeEmpty {
+			_, _, jsonBody, apiError := http.DownloadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, models.JSON, nil, "", dynamicSecretsTTL, nil)
+			if !apiError.IsNil() {
+				utils.HandleError(apiError.Unwrap(), apiError.Message)
+			}
+			var secrets map[string]string
+			if err := json.Unmarshal(jsonBody, &secrets); err != nil {
+				utils.HandleError(err, "Unable to parse API response")
+			}
+			for name := range controllers.OmitEmptySecrets(secrets) {
+				secretNames = append(secretNames, name)
+			}
+			sort.Strings(secretNames)
+		}
+
+		if !includeEmpty && len(secretNames) == 0 {
+			// requesting no names would return every secret
+			if format == models.YAML || format == models.DOTNET_JSON {
+				body = []byte("{}")
+			}
+		} else {
+			var apiError http.Error
+			_, _, body, apiError = http.DownloadSecrets(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, format, nameTransformer, "", dynamicSecretsTTL, secretNames)
+			if !apiError.IsNil() {
+				utils.HandleError(apiError.Unwrap(), apiError.Message)
+			}
+		}
+
+		// formats rendered by the CLI are always sorted, while these preserve the API's order unless sorted here
+		if sortKeys {
+			var err error
+			body, err = controllers.SortSecretsOutput(body, format)
+			if err != nil {
+				utils.HandleError(err, fmt.Sprintf("Unable to sort secrets in %s format", format))
+			}
 		}
 	}
 
@@ -506,6 +1163,10 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 		filePath = filepath.Join(".", format.OutputFile())
 	}
 
+	if !utils.GetBoolFlagIfChanged(cmd, "no-git-check", false) {
+		guardGitCommittable(filePath, utils.GetBoolFlagIfChanged(cmd, "fail-if-committable", false))
+	}
+
 	utils.LogDebug("Encrypting secrets")
 
 	passphrase := getPassphrase(cmd, "passphrase", localConfig)
@@ -518,20 +1179,97 @@ func downloadSecrets(cmd *cobra.Command, args []string) {
 		utils.HandleError(err, "Unable to encrypt your secrets. No file has been written.")
 	}
 
-	if err := utils.WriteFile(filePath, []byte(encryptedBody), utils.RestrictedFilePerms()); err != nil {
+	// the file is replaced atomically, so readers see either the previous file or the complete new one.
+	// preserve the permissions of an existing file
+	if err := utils.WriteFile(filePath, []byte(encryptedBody), utils.ExistingFilePerms(filePath, 0600)); err != nil {
 		utils.HandleError(err, "Unable to write the secrets file")
 	}
 
 	utils.Print(fmt.Sprintf("Downloaded secrets to %s", filePath))
 }
 
+// writeNginxShellFile writes a shell file that exports the secrets, to be sourced by the script that starts nginx so
+// that its env directives can pass the values through. like output printed with --no-file, the file isn't encrypted
+func writeNginxShellFile(cmd *cobra.Command, filePath string, secrets map[string]string) {
+	body, err := controllers.FormatSecrets(secrets, models.SHELL)
+	if err != nil {
+		utils.HandleError(err, "Unable to format the nginx shell file")
+	}
+
+	if !utils.GetBoolFlagIfChanged(cmd, "no-git-check", false) {
+		guardGitCommittable(filePath, utils.GetBoolFlagIfChanged(cmd, "fail-if-committable", false))
+	}
+	// preserve the permissions of an existing file
+	if err := utils.WriteFile(filePath, append(body, '\n'), utils.ExistingFilePerms(filePath, 0600)); err != nil {
+		utils.HandleError(err, "Unable to write the nginx shell file")
+	}
+	utils.Log(fmt.Sprintf("Saved the nginx shell file to %s", filePath))
+}
+
+// guardGitCommittable warns if the secrets file would be written to a path that git could commit. when fail is true,
+// it exits instead, though interactive users are offered to add an untracked path to .gitignore.
+func guardGitCommittable(filePath string, fail bool) {
+	switch utils.GitStatusOfPath(filePath) {
+	case utils.GitPathTracked:
+		message := fmt.Sprintf("%s is tracked by git, so secrets written to it may be committed", filePath)
+		if !fail {
+			utils.LogWarning(message)
+			return
+		}
+		utils.HandleError(errors.New(message), "", fmt.Sprintf("Stop tracking the file with 'git rm --cached %s', or omit --fail-if-committable to write it anyway", filePath))
+	case utils.GitPathUntracked:
+		message := fmt.Sprintf("%s is in a git repo but isn't ignored, so secrets written to it may be committed", filePath)
+		if !fail {
+			utils.LogWarning(message)
+			return
+		}
+		if isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stderr.Fd()) {
+			utils.LogWarning(message)
+			if utils.ConfirmationPrompt("Add it to .gitignore?", true) {
+				gitignore, err := utils.AddToGitIgnore(filePath)
+				if err != nil {
+					utils.HandleError(err, "Unable to update .gitignore")
+				}
+				utils.Log(fmt.Sprintf("Added %s to %s", filePath, gitignore))
+				return
+			}
+			utils.HandleError(errors.New("no file has been written"), "", "Omit --fail-if-committable to write the file anyway")
+		}
+		utils.HandleError(errors.New(message), "", "Add the path to .gitignore, or omit --fail-if-committable to write the file anyway")
+	}
+}
+
 func substituteSecrets(cmd *cobra.Command, args []string) {
 	localConfig := configuration.LocalConfig(cmd)
 
 	utils.RequireValue("token", localConfig.Token.Value)
 
+	input := cmd.Flag("input").Value.String()
+	if len(args) > 0 {
+		if input != "" {
+			utils.HandleError(errors.New("--input may not be used when a template file arg is specified"))
+		}
+		input = args[0]
+	}
+	if input == "" {
+		utils.HandleError(errors.New("you must specify a template file"))
+	}
+
+	syntax := cmd.Flag("syntax").Value.String()
+	if syntax != "template" && syntax != "env" {
+		utils.HandleError(errors.New("invalid syntax. Valid syntaxes are template, env"))
+	}
+	missing := cmd.Flag("missing").Value.String()
+	if !utils.Contains(controllers.MissingPlaceholderPolicies, missing) {
+		utils.HandleError(fmt.Errorf("invalid missing policy. Valid policies are %s", strings.Join(controllers.MissingPlaceholderPolicies, ", ")))
+	}
+
+	inputFilePath, err := utils.GetFilePath(input)
+	if err != nil {
+		utils.HandleError(err, "Unable to parse template file path")
+	}
+
 	var outputFilePath string
-	var err error
 	output := cmd.Flag("output").Value.String()
 	if len(output) != 0 {
 		outputFilePath, err = utils.GetFilePath(output)
@@ -560,11 +1298,24 @@ func substituteSecrets(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	templateBody := controllers.ReadTemplateFile(args[0])
-	outputString := controllers.RenderSecretsTemplate(templateBody, secretsMap)
+	templateBody := controllers.ReadTemplateFile(input)
+	var outputString string
+	if syntax == "env" {
+		outputString, err = controllers.RenderSecretsPlaceholders(templateBody, secretsMap, missing)
+		if err != nil {
+			utils.HandleError(err, "Unable to render template")
+		}
+	} else {
+		templateBody, err = controllers.TemplatePlaceholderActions(templateBody, secretsMap, missing)
+		if err != nil {
+			utils.HandleError(err, "Unable to render template")
+		}
+		outputString = controllers.RenderSecretsTemplate(templateBody, secretsMap)
+	}
 
 	if outputFilePath != "" {
-		err = utils.WriteFile(outputFilePath, []byte(outputString), 0600)
+		// the output file has the same permissions as the template file
+		err = utils.WriteFile(outputFilePath, []byte(outputString), utils.ExistingFilePerms(inputFilePath, 0600))
 		if err != nil {
 			utils.HandleError(err, "Unable to save rendered data to file")
 		}
@@ -577,15 +1328,78 @@ func substituteSecrets(cmd *cobra.Command, args []string) {
 	}
 }
 
+func secretHistory(cmd *cobra.Command, args []string) {
+	jsonFlag := utils.OutputJSON
+	reveal := utils.GetBoolFlag(cmd, "reveal")
+	number := utils.GetIntFlag(cmd, "number", 16)
+	localConfig := configuration.LocalConfig(cmd)
+	name := args[0]
+
+	utils.RequireValue("token", localConfig.Token.Value)
+
+	if number < 1 {
+		utils.HandleError(errors.New("--number must be greater than 0"))
+	}
+
+	// page through the config's logs until enough changes have been found or there are no more logs
+	var changes []models.SecretChange
+	for page := 1; len(changes) < number; page++ {
+		logs, err := http.GetConfigLogs(localConfig.APIHost.Value, utils.GetBool(localConfig.VerifyTLS.Value, true), localConfig.Token.Value, localConfig.EnclaveProject.Value, localConfig.EnclaveConfig.Value, page, utils.PageSize)
+		if !err.IsNil() {
+			utils.HandleError(err.Unwrap(), err.Message)
+		}
+
+		changes = append(changes, controllers.SecretHistory(logs, name)...)
+		if len(logs) < utils.PageSize {
+			break
+		}
+	}
+
+	if len(changes) > number {
+		changes = changes[:number]
+	}
+
+	if !reveal {
+		for i := range changes {
+			if changes[i].Previous != "" {
+				changes[i].Previous = utils.RedactedValue
+			}
+			if changes[i].Current != "" {
+				changes[i].Current = utils.RedactedValue
+			}
+		}
+	}
+
+	if len(changes) == 0 && !jsonFlag {
+		utils.Log(fmt.Sprintf("No changes found for secret %s", name))
+		return
+	}
+
+	printer.SecretHistory(changes, jsonFlag)
+}
+
 func secretNamesValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	persistentValidArgsFunction(cmd)
 
+	// secret names are only fetched for completion when the user has opted in
+	if os.Getenv("DOPPLER_COMPLETE_SECRETS") != "1" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
 	localConfig := configuration.LocalConfig(cmd)
-	names, err := controllers.GetSecretNames(localConfig)
-	if err.IsNil() {
-		return names, cobra.ShellCompDirectiveNoFileComp
+	names, err := controllers.GetSecretNamesForCompletion(localConfig)
+	if !err.IsNil() {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// don't suggest secrets that have already been specified
+	var suggestions []string
+	for _, name := range names {
+		if !utils.Contains(args, name) {
+			suggestions = append(suggestions, name)
+		}
 	}
-	return nil, cobra.ShellCompDirectiveNoFileComp
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
 }
 
 func init() {
@@ -596,6 +1410,9 @@ func init() {
 	secretsCmd.Flags().Bool("raw", false, "print the raw secret value without processing variables")
 	secretsCmd.Flags().Bool("visibility", false, "include secret visibility in table output")
 	secretsCmd.Flags().Bool("only-names", false, "only print the secret names; omit all values")
+	secretsCmd.Flags().Bool("reveal", false, "show secret values in the table rather than masking them. json output always includes the values")
+	secretsCmd.Flags().Bool("fail-empty", false, fmt.Sprintf("exit with code %d if the config has no secrets", emptyResultExitCode))
+	secretsCmd.Flags().Bool("show-source", false, "annotate each secret with whether its value is the environment's default (from the root config), overridden, or custom to this config")
 
 	secretsGetCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	secretsGetCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
@@ -605,7 +1422,11 @@ func init() {
 	secretsGetCmd.Flags().Bool("copy", false, "copy the value(s) to your clipboard")
 	secretsGetCmd.Flags().Bool("raw", false, "print the raw secret value without processing variables")
 	secretsGetCmd.Flags().Bool("visibility", false, "include secret visibility in table output")
-	secretsGetCmd.Flags().Bool("no-exit-on-missing-secret", false, "do not exit if unable to find a requested secret")
+	secretsGetCmd.Flags().Bool("no-exit-on-missing-secret", false, "do not exit if unable to find a requested secret. a warning is logged instead, and with --plain an empty line is printed in place of the missing value")
+	secretsGetCmd.Flags().Bool("parse-json", false, "parse the secret's value as JSON and pretty print it")
+	secretsGetCmd.Flags().String("json-path", "", "print the field at this path of the secret's JSON value (e.g. '.host' or '.replicas[0].host'). strings are printed without quotes. implies --parse-json")
+	secretsGetCmd.Flags().String("output-delimiter", "", "with --plain, separate the values with this string rather than a newline. supports the escapes \\n, \\t, \\0 (null byte, e.g. for 'xargs -0'), and \\\\. null-delimited output has no trailing newline")
+	secretsGetCmd.Flags().Bool("no-newline", false, "do not print a trailing newline after the value(s). requires --plain, --parse-json, or --json-path")
 	secretsCmd.AddCommand(secretsGetCmd)
 
 	secretsSetCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
@@ -613,14 +1434,33 @@ func init() {
 	secretsSetCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	secretsSetCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
 	secretsSetCmd.Flags().Bool("raw", false, "print the raw secret value without processing variables")
+	if err := secretsSetCmd.Flags().MarkDeprecated("raw", "the change summary always shows raw values"); err != nil {
+		utils.HandleError(err)
+	}
+	secretsSetCmd.Flags().Bool("reveal", false, "show the old and new values in the change summary")
 	secretsSetCmd.Flags().Bool("no-interactive", false, "do not allow entering secret value via interactive mode")
+	secretsSetCmd.Flags().Bool("fail-if-no-change", false, fmt.Sprintf("exit with code %d if the secrets already have the specified values", noChangeExitCode))
+	secretsSetCmd.Flags().Bool("if-not-exists", false, "only set secrets that don't already exist in the config. existing secrets are left unchanged, even if their values are empty")
+	secretsSetCmd.Flags().Bool("keep-crlf", false, "preserve Windows line endings (CRLF) in values read from a file (KEY=@path). by default they're converted to LF")
+	secretsSetCmd.Flags().Bool("dotenv-expand", false, "resolve ${NAME} references in KEY=value arguments to values set by earlier arguments, as in an env file. references to names not set earlier are an error")
+	secretsSetCmd.Flags().Bool("force", false, "save secrets whose names are reserved or aren't valid environment variable names")
+	secretsSetCmd.Flags().Bool("trim", false, "strip leading and trailing whitespace from a value read from stdin or from a file (KEY=@path). off by default so values like certificates are stored exactly as provided (a single trailing newline is always removed from stdin)")
 	secretsCmd.AddCommand(secretsSetCmd)
 
 	secretsUploadCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	secretsUploadCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	secretsUploadCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	secretsUploadCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	secretsUploadCmd.Flags().String("file", "", "path to the secrets file, as an alternative to the filepath argument. use '-' to read the secrets from stdin")
 	secretsUploadCmd.Flags().Bool("raw", false, "print the raw secret value without processing variables")
+	secretsUploadCmd.Flags().Bool("replace", false, "treat the file as the full set of secrets, deleting any secrets not in the file. requires a JSON or YAML file, or an env file when using --dotenv-expand")
+	secretsUploadCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
+	secretsUploadCmd.Flags().Bool("dotenv-expand", false, "parse the file as an env file, resolving ${NAME} references to values defined earlier in the file")
+	secretsUploadCmd.Flags().Bool("file-values", false, "read values of the form @path from the referenced file, e.g. TLS_CERT=@cert.pem")
+	secretsUploadCmd.Flags().Bool("allow-duplicates", false, "allow an env file to define a key more than once, using the last value. by default duplicate keys are an error")
+	secretsUploadCmd.Flags().Bool("keep-crlf", false, "preserve Windows line endings (CRLF) in the upload file and any referenced files. by default they're converted to LF")
+	secretsUploadCmd.Flags().Bool("force", false, "upload secrets whose names are reserved or aren't valid environment variable names")
+	secretsUploadCmd.Flags().Bool("trim", false, "strip leading and trailing whitespace from values. by default, env file values (including quoted values) are trimmed, while JSON and YAML values and the contents of files read with --file-values are uploaded exactly as written. --trim trims all of them, and --trim=false none of them")
 	secretsCmd.AddCommand(secretsUploadCmd)
 
 	secretsDeleteCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
@@ -631,6 +1471,14 @@ func init() {
 	secretsDeleteCmd.Flags().BoolP("yes", "y", false, "proceed without confirmation")
 	secretsCmd.AddCommand(secretsDeleteCmd)
 
+	secretsHistoryCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	secretsHistoryCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	secretsHistoryCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	secretsHistoryCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	secretsHistoryCmd.Flags().IntP("number", "n", 10, "max number of changes to display")
+	secretsHistoryCmd.Flags().Bool("reveal", false, "show secret values rather than redacting them")
+	secretsCmd.AddCommand(secretsHistoryCmd)
+
 	secretsDownloadCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	secretsDownloadCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	secretsDownloadCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
@@ -641,8 +1489,18 @@ func init() {
 		return models.SecretsNameTransformerTypes, cobra.ShellCompDirectiveDefault
 	})
 	secretsDownloadCmd.Flags().String("passphrase", "", "passphrase to use for encrypting the secrets file. the default passphrase is computed using your current configuration.")
-	secretsDownloadCmd.Flags().Bool("no-file", false, "print the response to stdout")
+	secretsDownloadCmd.Flags().Bool("no-file", false, "print the response to stdout. when stdout is a terminal, requires an explicit --format or --stdout")
+	secretsDownloadCmd.Flags().Bool("stdout", false, "print the response to stdout, even when stdout is a terminal (implies --no-file)")
+	secretsDownloadCmd.Flags().Bool("stream", false, "print secrets to stdout as they're received rather than buffering the full response, reducing memory usage for large configs. requires --no-file and the json format. secrets are printed in the order they're received, and output may be incomplete if the download fails")
 	secretsDownloadCmd.Flags().Duration("dynamic-ttl", 0, "(BETA) dynamic secrets will expire after specified duration, (e.g. '3h', '15m')")
+	secretsDownloadCmd.Flags().String("name", "", "name of the Kubernetes Secret. required when format is k8s")
+	secretsDownloadCmd.Flags().String("namespace", "", "namespace of the Kubernetes Secret. omitted from the manifest by default")
+	secretsDownloadCmd.Flags().String("type", controllers.DefaultKubernetesSecretType, "type of the Kubernetes Secret")
+	secretsDownloadCmd.Flags().String("shell-file", "", "path of the shell file that exports the secrets' values for nginx's env directives. required when format is nginx. like --no-file output, it isn't encrypted")
+	secretsDownloadCmd.Flags().Bool("no-sort", false, "preserve the order of secrets returned by the API in the env-no-quotes, yaml, and dotnet-json formats. by default secrets are sorted by name so regenerated files are stable. other formats are always sorted")
+	secretsDownloadCmd.Flags().Bool("fail-if-committable", false, "exit with an error instead of a warning if the file is in a git repo and isn't ignored by git")
+	secretsDownloadCmd.Flags().Bool("no-git-check", false, "don't check whether the file is ignored by git before writing it")
+	secretsDownloadCmd.Flags().Bool("include-empty", true, "include secrets with empty values (e.g. KEY=\"\" in env format). use --include-empty=false to omit them")
 	// fallback flags
 	secretsDownloadCmd.Flags().String("fallback", "", "path to the fallback file. encrypted secrets are written to this file after each successful fetch. secrets will be read from this file if subsequent connections are unsuccessful.")
 	secretsDownloadCmd.Flags().Bool("no-cache", false, "disable using the fallback file to speed up fetches. the fallback file is only used when the API indicates that it's still current.")
@@ -651,13 +1509,23 @@ func init() {
 	secretsDownloadCmd.Flags().Bool("fallback-readonly", false, "disable modifying the fallback file. secrets can still be read from the file.")
 	secretsDownloadCmd.Flags().Bool("fallback-only", false, "read all secrets directly from the fallback file, without contacting Doppler. secrets will not be updated. (implies --fallback-readonly)")
 	secretsDownloadCmd.Flags().Bool("no-exit-on-write-failure", false, "do not exit if unable to write the fallback file")
+	secretsDownloadCmd.Flags().Duration("fallback-max-age", 0, "fail rather than read secrets from a fallback file that was last updated longer ago than this duration (e.g. '24h'). the file is updated after each successful fetch. by default there's no limit")
 	secretsCmd.AddCommand(secretsDownloadCmd)
 
 	secretsSubstituteCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
 	secretsSubstituteCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
 	secretsSubstituteCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
 	secretsSubstituteCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
-	secretsSubstituteCmd.Flags().String("output", "", "path to the output file. by default the rendered text will be written to stdout.")
+	secretsSubstituteCmd.Flags().String("input", "", "path to the template file. may be used instead of the template file arg")
+	secretsSubstituteCmd.Flags().String("output", "", "path to the output file. by default the rendered text will be written to stdout. the output file is given the same permissions as the template file")
+	secretsSubstituteCmd.Flags().String("syntax", "template", "template syntax. one of [\"template\", \"env\"]. both replace ${SECRET_NAME} placeholders, and template also supports Go template syntax (e.g. {{.SECRET_NAME}}). use env for files that contain {{ as literal text")
+	secretsSubstituteCmd.RegisterFlagCompletionFunc("syntax", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"template", "env"}, cobra.ShellCompDirectiveDefault
+	})
+	secretsSubstituteCmd.Flags().String("missing", controllers.MissingPlaceholderKeep, fmt.Sprintf("behavior for ${SECRET_NAME} placeholders that don't match a secret. one of %v", controllers.MissingPlaceholderPolicies))
+	secretsSubstituteCmd.RegisterFlagCompletionFunc("missing", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return controllers.MissingPlaceholderPolicies, cobra.ShellCompDirectiveDefault
+	})
 	secretsSubstituteCmd.Flags().Duration("dynamic-ttl", 0, "(BETA) dynamic secrets will expire after specified duration, (e.g. '3h', '15m')")
 	secretsCmd.AddCommand(secretsSubstituteCmd)
 
@@ -0,0 +1,236 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadSecretsTrim(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	assert.NoError(t, os.WriteFile(file, []byte("PADDED=\"  value  \"\nCERT=\"line\\n\"\n"), 0600))
+
+	upload := func(args ...string) (string, map[string]interface{}) {
+		var path string
+		var body struct {
+			Secrets map[string]interface{} `json:"secrets"`
+		}
+		executeCommand(t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			if r.URL.Path == "/v3/configs/config/secrets" {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			}
+			w.Write([]byte(`{"secrets":{}}`)) // #nosec G104
+		}, append([]string{"secrets", "upload", file, "-p", "backend", "-c", "dev"}, args...)...)
+		return path, body.Secrets
+	}
+
+	// by default, env file values are trimmed, including quoted values
+	path, secrets := upload()
+	assert.Equal(t, "/v3/configs/config/secrets", path)
+	assert.Equal(t, map[string]interface{}{"PADDED": "value", "CERT": "line"}, secrets)
+
+	// otherwise the file is uploaded as written and parsed by the API
+	path, _ = upload("--trim=false")
+	assert.Equal(t, "/v3/configs/config/secrets/upload", path)
+
+	// parsing the file locally (e.g. for --allow-duplicates) preserves quoted whitespace
+	_, secrets = upload("--trim=false", "--allow-duplicates")
+	assert.Equal(t, map[string]interface{}{"PADDED": "  value  ", "CERT": "line\n"}, secrets)
+
+	// the contents of files read with --file-values are only trimmed with --trim
+	cert := filepath.Join(t.TempDir(), "cert.pem")
+	assert.NoError(t, os.WriteFile(cert, []byte("cert\n"), 0600))
+	assert.NoError(t, os.WriteFile(file, []byte("TLS_CERT=@"+cert+"\n"), 0600))
+	_, secrets = upload("--file-values")
+	assert.Equal(t, map[string]interface{}{"TLS_CERT": "cert\n"}, secrets)
+	_, secrets = upload("--file-values", "--trim")
+	assert.Equal(t, map[string]interface{}{"TLS_CERT": "cert"}, secrets)
+}
+
+func TestRedactSecretValues(t *testing.T) {
+	value := func(v string) *string { return &v }
+	secrets := map[string]models.ComputedSecret{
+		"HOST":       {Name: "HOST", RawValue: value("${DOMAIN}"), ComputedValue: value("localhost")},
+		"EMPTY":      {Name: "EMPTY", RawValue: value(""), ComputedValue: value("")},
+		"RESTRICTED": {Name: "RESTRICTED"},
+	}
+
+	redacted := redactSecretValues(secrets)
+	assert.Equal(t, utils.RedactedValue, *redacted["HOST"].RawValue)
+	assert.Equal(t, utils.RedactedValue, *redacted["HOST"].ComputedValue)
+	// empty and restricted values are shown as they are
+	assert.Equal(t, "", *redacted["EMPTY"].ComputedValue)
+	assert.Nil(t, redacted["RESTRICTED"].ComputedValue)
+	// the secrets themselves aren't modified
+	assert.Equal(t, "localhost", *secrets["HOST"].ComputedValue)
+}
+
+func TestSecretsReveal(t *testing.T) {
+	list := func(args ...string) string {
+		return captureStdout(t, func() {
+			executeCommand(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"secrets":{"HOST":{"raw":"localhost","computed":"localhost"},"EMPTY":{"raw":"","computed":""}}}`)) // #nosec G104
+			}, append([]string{"secrets", "-p", "backend", "-c", "dev"}, args...)...)
+		})
+	}
+
+	output := list()
+	assert.Contains(t, output, utils.RedactedValue)
+	assert.NotContains(t, output, "localhost")
+
+	output = list("--reveal")
+	assert.Contains(t, output, "localhost")
+	assert.NotContains(t, output, utils.RedactedValue)
+
+	// json output is meant for scripts, so it always includes the values
+	output = list("--json")
+	assert.Contains(t, output, `"localhost"`)
+	assert.NotContains(t, output, utils.RedactedValue)
+}
+
+func TestUploadSecretsReplace(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("KEEP: \"1\"\nKEPT_TOKEN: abc\nADD: 1.10\n"), 0600))
+
+	var body struct {
+		Secrets map[string]interface{} `json:"secrets"`
+	}
+	executeCommand(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Write([]byte(`{"secrets":{}}`)) // #nosec G104
+			return
+		}
+		// restricted secrets have no raw value
+		w.Write([]byte(`{"secrets":{"KEEP":{"raw":"1","computed":"1"},"REMOVE":{"raw":"x","computed":"x"},` + // #nosec G104
+			`"TOKEN":{"raw":null,"computed":null},"KEPT_TOKEN":{"raw":null,"computed":null},"DOPPLER_CONFIG":{"raw":"dev","computed":"dev"}}}`))
+	}, "secrets", "upload", file, "--replace", "--yes", "-p", "backend", "-c", "dev")
+
+	// restricted secrets are removed unless they're in the file, and the file's values are uploaded as written
+	assert.Equal(t, map[string]interface{}{"ADD": "1.10", "KEPT_TOKEN": "abc", "REMOVE": nil, "TOKEN": nil}, body.Secrets)
+}
+
+func TestSetSecretsDotEnvExpand(t *testing.T) {
+	set := func(args ...string) map[string]interface{} {
+		var body struct {
+			Secrets map[string]interface{} `json:"secrets"`
+		}
+		executeCommand(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			}
+			w.Write([]byte(`{"secrets":{}}`)) // #nosec G104
+		}, append([]string{"secrets", "set", "HOST=localhost", "PORT=8080", "URL=http://${HOST}:$PORT", "-p", "backend", "-c", "dev"}, args...)...)
+		return body.Secrets
+	}
+
+	// values are taken literally without the flag
+	assert.Equal(t, map[string]interface{}{"HOST": "localhost", "PORT": "8080", "URL": "http://${HOST}:$PORT"}, set())
+	assert.Equal(t, map[string]interface{}{"HOST": "localhost", "PORT": "8080", "URL": "http://localhost:8080"}, set("--dotenv-expand"))
+
+	t.Run("undefined", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"secrets":{}}`)) // #nosec G104
+		}, "secrets", "set", "URL=http://${HOST}", "HOST=localhost", "--dotenv-expand", "-p", "backend", "-c", "dev")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "undefined variable HOST")
+	})
+}
+
+func TestDownloadSecretsEnvFormats(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// the CLI renders these formats itself from the JSON secrets
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		w.Write([]byte(`{"PRICE":"$5 \"each\"","NAME":"it's","CERT":"line1\nline2"}`)) // #nosec G104
+	}
+
+	output := captureStdout(t, func() {
+		executeCommand(t, handler, "secrets", "download", "--no-file", "--format", "env", "-p", "backend", "-c", "dev")
+	})
+	assert.Equal(t, `CERT="line1\nline2"`+"\n"+`NAME="it's"`+"\n"+`PRICE="\$5 \"each\""`+"\n", output)
+
+	// the output reads back as the original values
+	secrets, err := utils.ParseDotEnv(output, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PRICE": `$5 "each"`, "NAME": "it's", "CERT": "line1\nline2"}, secrets)
+
+	t.Run("docker", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler, "secrets", "download", "--no-file", "--format", "docker", "-p", "backend", "-c", "dev")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "CERT: values containing line breaks can't be represented in the docker format")
+	})
+}
+
+func TestSubstituteSecrets(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "app.conf.tmpl")
+	assert.NoError(t, os.WriteFile(input, []byte("listen ${HOST}:{{.PORT}};\n"), 0640))
+	assert.NoError(t, os.Chmod(input, 0640))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secrets":{"HOST":{"raw":"127.0.0.1","computed":"127.0.0.1"},"PORT":{"raw":"8080","computed":"8080"}}}`)) // #nosec G104
+	}
+
+	// placeholders are substituted with the default syntax, and the output has the template's permissions
+	output := filepath.Join(dir, "app.conf")
+	executeCommand(t, handler, "secrets", "substitute", input, "--output", output, "-p", "backend", "-c", "dev")
+	contents, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "listen 127.0.0.1:8080;\n", string(contents))
+	info, err := os.Stat(output)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// the env syntax leaves Go template syntax unchanged
+	stdout := captureStdout(t, func() {
+		executeCommand(t, handler, "secrets", "substitute", input, "--syntax", "env", "-p", "backend", "-c", "dev")
+	})
+	assert.Equal(t, "listen 127.0.0.1:{{.PORT}};\n", stdout)
+}
+
+func TestDownloadSecretsNginx(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"HOST":"127.0.0.1","NAME":"it's"}`)) // #nosec G104
+	}
+
+	shellFile := filepath.Join(t.TempDir(), "doppler.sh")
+	output := captureStdout(t, func() {
+		executeCommand(t, handler, "secrets", "download", "--no-file", "--format", "nginx", "--shell-file", shellFile, "-p", "backend", "-c", "dev")
+	})
+	assert.Equal(t, "env HOST;\nenv NAME;\n", output)
+
+	// the shell file exports the values passed through by the env directives
+	contents, err := os.ReadFile(shellFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "export HOST='127.0.0.1'\nexport NAME='it'\\''s'\n", string(contents))
+	info, err := os.Stat(shellFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	t.Run("shell file required", func(t *testing.T) {
+		code, stderr := executeCommandExit(t, handler, "secrets", "download", "--no-file", "--format", "nginx", "-p", "backend", "-c", "dev")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "--shell-file is required when format is nginx")
+	})
+}
@@ -0,0 +1,366 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/controllers"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/kballard/go-shellquote"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Start an interactive shell for running Doppler commands",
+	Long: `Start an interactive shell for running Doppler commands against a fixed project and config.
+
+Commands are entered without the "doppler" prefix (e.g. "secrets get API_KEY") and use the shell's project and
+config unless --project or --config is specified. The up and down arrows navigate the session's command history,
+and tab completes command and flag names.
+
+Shell commands:
+  set project <project>   change the project
+  set config <config>     change the config
+  scope                   print the current project and config
+  help                    print this help
+  exit                    exit the shell (or press Ctrl-D)`,
+	Example: `doppler shell --project backend --config dev`,
+	Args:    cobra.NoArgs,
+	Run:     shell,
+}
+
+type shellScope struct {
+	project string
+	config  string
+}
+
+func (s shellScope) String() string {
+	if s.config == "" {
+		return s.project
+	}
+	return fmt.Sprintf("%s/%s", s.project, s.config)
+}
+
+func shell(cmd *cobra.Command, args []string) {
+	localConfig := configuration.LocalConfig(cmd)
+	scope := shellScope{project: localConfig.EnclaveProject.Value, config: localConfig.EnclaveConfig.Value}
+	globalArgs := shellGlobalArgs(cmd)
+	env := shellEnv(os.Environ(), localConfig.Token.Value, configuration.CanReadEnv)
+
+	// commands are run as child processes, which receive SIGINT themselves. the shell keeps running.
+	utils.StopInterruptHandler()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+		}
+	}()
+
+	readLine := shellLineReader(localConfig, &scope)
+	for {
+		line, err := readLine(fmt.Sprintf("doppler [%s]> ", scope))
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			utils.HandleError(err, "Unable to read input")
+		}
+
+		words, err := shellquote.Split(line)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if len(words) > 0 && words[0] == "doppler" {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		switch words[0] {
+		case "exit", "quit":
+			return
+		case "help":
+			utils.Print(cmd.Long)
+		case "scope":
+			utils.Print(fmt.Sprintf("project: %s\nconfig: %s", scope.project, scope.config))
+		case "set":
+			if len(words) != 3 || (words[1] != "project" && words[1] != "config") {
+				utils.LogError(errors.New("usage: set project <project> | set config <config>"))
+				continue
+			}
+			if words[1] == "project" {
+				// the config is specific to the previous project
+				scope = shellScope{project: words[2]}
+			} else {
+				scope.config = words[2]
+			}
+		default:
+			runShellCommand(words, globalArgs, env, scope)
+		}
+	}
+}
+
+// global flags that aren't passed to commands as args. the token is passed via the environment instead, since
+// args are visible to other users (e.g. via ps), and the scope has already been used to resolve the token.
+// --no-read-env is applied by filtering the environment, so that the token can still be passed.
+var shellSkippedGlobalFlags = []string{"token", "token-stdin", "scope", "no-read-env"}
+
+// shellGlobalArgs returns the global flags specified when starting the shell, so they also apply to each command
+func shellGlobalArgs(cmd *cobra.Command) []string {
+	var args []string
+	cmd.InheritedFlags().VisitAll(func(flag *pflag.Flag) {
+		if !flag.Changed || utils.Contains(shellSkippedGlobalFlags, flag.Name) {
+			return
+		}
+		value := flag.Value.String()
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			value = strings.Join(sliceValue.GetSlice(), ",")
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", flag.Name, value))
+	})
+	return args
+}
+
+// shellEnv returns the environment for commands run by the shell, which passes the shell's token via DOPPLER_TOKEN.
+// When the environment can't be read (i.e. --no-read-env), other Doppler variables are removed instead.
+func shellEnv(environ []string, token string, canReadEnv bool) []string {
+	var env []string
+	for _, pair := range environ {
+		name := strings.SplitN(pair, "=", 2)[0]
+		// ENCLAVE_TOKEN takes precedence over DOPPLER_TOKEN
+		if name == "DOPPLER_TOKEN" || name == "ENCLAVE_TOKEN" {
+			continue
+		}
+		if !canReadEnv && (strings.HasPrefix(name, "DOPPLER_") || strings.HasPrefix(name, "ENCLAVE_")) {
+			continue
+		}
+		env = append(env, pair)
+	}
+	if token != "" {
+		env = append(env, "DOPPLER_TOKEN="+token)
+	}
+	return env
+}
+
+// runShellCommand runs the command in a child process, so that its flags start out unset and
+// exiting due to an error doesn't exit the shell
+func runShellCommand(words []string, globalArgs []string, env []string, scope shellScope) {
+	target, _, err := rootCmd.Find(words)
+	if err != nil || target == rootCmd {
+		utils.LogError(fmt.Errorf("unknown command %q. Run 'help' to view shell commands, or '--help' to view all commands", words[0]))
+		return
+	}
+
+	args := append([]string{}, globalArgs...)
+	args = append(args, words...)
+	// apply the shell's scope to commands that accept it
+	if scope.project != "" && target.Flags().Lookup("project") != nil && !shellHasFlag(words, "project", "p") {
+		args = append(args, "--project", scope.project)
+	}
+	if scope.config != "" && target.Flags().Lookup("config") != nil && !shellHasFlag(words, "config", "c") {
+		args = append(args, "--config", scope.config)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		utils.HandleError(err, "Unable to determine the path to the CLI")
+	}
+
+	c := exec.Command(executable, args...) // #nosec G204
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) {
+			utils.LogError(err)
+		}
+	}
+}
+
+// shellHasFlag returns whether the flag was specified, stopping at "--" since the remaining args belong to another program
+func shellHasFlag(words []string, name string, shorthand string) bool {
+	for _, word := range words {
+		if word == "--" {
+			break
+		}
+		if word == "--"+name || strings.HasPrefix(word, "--"+name+"=") || word == "-"+shorthand || strings.HasPrefix(word, "-"+shorthand+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// shellLineReader returns a function that reads the next line of input. On a terminal, lines are read with
+// history and tab completion. Otherwise (e.g. when commands are piped in) lines are read as-is.
+func shellLineReader(localConfig models.ScopedOptions, scope *shellScope) func(prompt string) (string, error) {
+	stdinFd := int(os.Stdin.Fd())
+	if !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+		scanner := bufio.NewScanner(os.Stdin)
+		return func(prompt string) (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' || pos != len(line) {
+			return "", 0, false
+		}
+		return shellComplete(line, func(words []string) []string {
+			return shellCompletionCandidates(words, localConfig, *scope)
+		})
+	}
+
+	return func(prompt string) (string, error) {
+		// the terminal is only in raw mode while reading input, so commands' output is unaffected
+		state, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return "", err
+		}
+		defer term.Restore(stdinFd, state) // #nosec G104
+
+		if width, height, err := term.GetSize(stdinFd); err == nil && width > 0 {
+			terminal.SetSize(width, height) // #nosec G104
+		}
+		terminal.SetPrompt(prompt)
+		return terminal.ReadLine()
+	}
+}
+
+// shellComplete completes the last word of the line with the candidates' longest common prefix
+func shellComplete(line string, candidates func(words []string) []string) (string, int, bool) {
+	words := strings.Fields(line)
+	partial := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var matches []string
+	for _, candidate := range candidates(words) {
+		if strings.HasPrefix(candidate, partial) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := utils.CommonPrefix(matches)
+	if len(matches) == 1 {
+		completion += " "
+	}
+	newLine := line[:len(line)-len(partial)] + completion
+	return newLine, len(newLine), true
+}
+
+// shellCompletionCandidates returns the possible values of the word following the specified words
+func shellCompletionCandidates(words []string, localConfig models.ScopedOptions, scope shellScope) []string {
+	if len(words) > 0 && words[0] == "doppler" {
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		candidates := []string{"set", "scope", "help", "exit"}
+		for _, c := range rootCmd.Commands() {
+			if c.IsAvailableCommand() {
+				candidates = append(candidates, c.Name())
+			}
+		}
+		return candidates
+	}
+
+	if words[0] == "set" {
+		if localConfig.Token.Value == "" {
+			return nil
+		}
+		switch {
+		case len(words) == 1:
+			return []string{"project", "config"}
+		case len(words) == 2 && words[1] == "project":
+			localConfig.EnclaveProject.Value = scope.project
+			ids, err := controllers.GetProjectIDs(localConfig)
+			if !err.IsNil() {
+				return nil
+			}
+			return ids
+		case len(words) == 2 && words[1] == "config":
+			localConfig.EnclaveProject.Value = scope.project
+			names, err := controllers.GetConfigNames(localConfig)
+			if !err.IsNil() {
+				return nil
+			}
+			return names
+		}
+		return nil
+	}
+
+	target, _, err := rootCmd.Find(words)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, c := range target.Commands() {
+		if c.IsAvailableCommand() {
+			candidates = append(candidates, c.Name())
+		}
+	}
+	addFlag := func(flag *pflag.Flag) {
+		if !flag.Hidden {
+			candidates = append(candidates, "--"+flag.Name)
+		}
+	}
+	target.LocalFlags().VisitAll(addFlag)
+	target.InheritedFlags().VisitAll(addFlag)
+	return candidates
+}
+
+func init() {
+	shellCmd.Flags().StringP("project", "p", "", "project (e.g. backend)")
+	shellCmd.RegisterFlagCompletionFunc("project", projectIDsValidArgs)
+	shellCmd.Flags().StringP("config", "c", "", "config (e.g. dev)")
+	shellCmd.RegisterFlagCompletionFunc("config", configNamesValidArgs)
+	rootCmd.AddCommand(shellCmd)
+}
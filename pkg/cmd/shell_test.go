@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellHasFlag(t *testing.T) {
+	testCases := []struct {
+		words    []string
+		expected bool
+	}{
+		{words: []string{"secrets"}, expected: false},
+		{words: []string{"secrets", "--project", "backend"}, expected: true},
+		{words: []string{"secrets", "--project=backend"}, expected: true},
+		{words: []string{"secrets", "-p", "backend"}, expected: true},
+		{words: []string{"secrets", "-p=backend"}, expected: true},
+		{words: []string{"secrets", "--projects", "backend"}, expected: false},
+		{words: []string{"secrets", "-pp"}, expected: false},
+		{words: []string{"run", "--", "node", "--project", "backend"}, expected: false},
+		{words: []string{"run", "--project", "backend", "--", "node"}, expected: true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, shellHasFlag(tc.words, "project", "p"), tc.words)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	var candidateWords [][]string
+	candidates := func(words []string) []string {
+		candidateWords = append(candidateWords, words)
+		return []string{"configs", "configure", "secrets"}
+	}
+
+	testCases := []struct {
+		line     string
+		expected string
+		ok       bool
+		words    []string
+	}{
+		// common prefix of multiple matches
+		{line: "con", expected: "config", ok: true, words: []string{}},
+		// a single match is followed by a space
+		{line: "sec", expected: "secrets ", ok: true, words: []string{}},
+		{line: "configs ", expected: "configs ", ok: true, words: []string{"configs"}},
+		{line: "configs s", expected: "configs secrets ", ok: true, words: []string{"configs"}},
+		{line: "run x", expected: "", ok: false, words: []string{"run"}},
+	}
+	for _, tc := range testCases {
+		candidateWords = nil
+		line, pos, ok := shellComplete(tc.line, candidates)
+		assert.Equal(t, tc.ok, ok, tc.line)
+		assert.Equal(t, tc.expected, line, tc.line)
+		assert.Equal(t, len(tc.expected), pos, tc.line)
+		assert.Equal(t, [][]string{tc.words}, candidateWords, tc.line)
+	}
+}
+
+func TestShellEnv(t *testing.T) {
+	environ := []string{"HOME=/home/user", "DOPPLER_TOKEN=dp.st.env", "ENCLAVE_TOKEN=dp.st.enclave", "DOPPLER_JSON=true"}
+
+	assert.Equal(t, []string{"HOME=/home/user", "DOPPLER_JSON=true", "DOPPLER_TOKEN=dp.st.shell"}, shellEnv(environ, "dp.st.shell", true))
+	// with --no-read-env, only the token is passed
+	assert.Equal(t, []string{"HOME=/home/user", "DOPPLER_TOKEN=dp.st.shell"}, shellEnv(environ, "dp.st.shell", false))
+	assert.Equal(t, []string{"HOME=/home/user", "DOPPLER_JSON=true"}, shellEnv(environ, "", true))
+}
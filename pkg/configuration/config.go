@@ -16,6 +16,8 @@ limitations under the License.
 package configuration
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -24,7 +26,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/BurntSushi/toml"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
@@ -43,11 +47,28 @@ var Scope = "."
 // CanReadEnv whether configuration can be read from the environment
 var CanReadEnv = true
 
+// ConfigFormat the format of the config file. when empty, the format is detected from the file's extension
+var ConfigFormat string
+
+// ConfigFormats the supported config file formats
+var ConfigFormats = []string{"yaml", "json", "toml"}
+
 var configFileName = ".doppler.yaml"
+var tomlConfigFileName = ".doppler.toml"
 var configContents models.ConfigFile
 var configUid = -1
 var configGid = -1
 
+// the config resolved by LocalConfig, so that all reads within a command see a consistent snapshot
+var localConfigCache = map[localConfigCacheKey]models.ScopedOptions{}
+var localConfigCacheMutex sync.Mutex
+
+type localConfigCacheKey struct {
+	cmd        *cobra.Command
+	scope      string
+	canReadEnv bool
+}
+
 func init() {
 	SetConfigDir(filepath.Join(utils.HomeDir(), ".doppler"))
 }
@@ -55,6 +76,11 @@ func init() {
 func SetConfigDir(dir string) {
 	UserConfigDir = dir
 	UserConfigFile = filepath.Join(UserConfigDir, configFileName)
+
+	// use an existing toml config file when there's no yaml config file
+	if tomlConfigFile := filepath.Join(UserConfigDir, tomlConfigFileName); !utils.Exists(UserConfigFile) && utils.Exists(tomlConfigFile) {
+		UserConfigFile = tomlConfigFile
+	}
 }
 
 // Setup the config directory and config file
@@ -79,13 +105,15 @@ func Setup() {
 	if !utils.Exists(UserConfigFile) {
 		v1ConfigA := filepath.Join(utils.ConfigDir(), configFileName)
 		v1ConfigB := filepath.Join(utils.HomeDir(), configFileName)
-		if utils.Exists(v1ConfigA) {
+		// v1 configs are yaml, so they can only be moved into place when using the yaml format
+		isYAML := configFileFormat() == "yaml"
+		if isYAML && utils.Exists(v1ConfigA) {
 			utils.LogDebug("Migrating the config from CLI v1")
 			err := os.Rename(v1ConfigA, UserConfigFile)
 			if err != nil {
 				utils.HandleError(err, "Unable to migrate config from CLI v1")
 			}
-		} else if utils.Exists(v1ConfigB) {
+		} else if isYAML && utils.Exists(v1ConfigB) {
 			utils.LogDebug("Migrating the config from CLI v1")
 			err := os.Rename(v1ConfigB, UserConfigFile)
 			if err != nil {
@@ -105,6 +133,7 @@ func Setup() {
 // LoadConfig load the configuration file
 func LoadConfig() {
 	configContents, configUid, configGid = readConfig()
+	invalidateLocalConfig()
 }
 
 // VersionCheck the last version check
@@ -118,6 +147,37 @@ func SetVersionCheck(version models.VersionCheck) {
 	writeConfig(configContents)
 }
 
+// HostPresets the saved host presets, by name
+func HostPresets() map[string]models.HostPreset {
+	presets := map[string]models.HostPreset{}
+	for name, preset := range configContents.HostPresets {
+		presets[name] = preset
+	}
+	return presets
+}
+
+// SetHostPreset saves the host preset, replacing any existing preset with the same name
+func SetHostPreset(name string, preset models.HostPreset) {
+	if configContents.HostPresets == nil {
+		configContents.HostPresets = map[string]models.HostPreset{}
+	}
+	configContents.HostPresets[name] = preset
+	writeConfig(configContents)
+}
+
+// RemoveHostPreset deletes the host preset, returning false if it doesn't exist
+func RemoveHostPreset(name string) bool {
+	if _, ok := configContents.HostPresets[name]; !ok {
+		return false
+	}
+	delete(configContents.HostPresets, name)
+	if len(configContents.HostPresets) == 0 {
+		configContents.HostPresets = nil
+	}
+	writeConfig(configContents)
+	return true
+}
+
 // Get the config at the specified scope
 func Get(scope string) models.ScopedOptions {
 	var normalizedScope string
@@ -168,11 +228,36 @@ func Get(scope string) models.ScopedOptions {
 	return scopedConfig
 }
 
-// LocalConfig retrieves the config for the scoped directory
+// LocalConfig retrieves the config for the scoped directory. The config is only resolved once per command and scope
+// (reading the keyring, environment, and flags), and is re-resolved after the config file is modified.
 func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
+	key := localConfigCacheKey{cmd: cmd, scope: Scope, canReadEnv: CanReadEnv}
+
+	localConfigCacheMutex.Lock()
+	defer localConfigCacheMutex.Unlock()
+	if localConfig, ok := localConfigCache[key]; ok {
+		return localConfig
+	}
+
+	localConfig := resolveLocalConfig(cmd)
+	localConfigCache[key] = localConfig
+	return localConfig
+}
+
+// invalidateLocalConfig discards the configs resolved by LocalConfig
+func invalidateLocalConfig() {
+	localConfigCacheMutex.Lock()
+	defer localConfigCacheMutex.Unlock()
+	localConfigCache = map[localConfigCacheKey]models.ScopedOptions{}
+}
+
+func resolveLocalConfig(cmd *cobra.Command) models.ScopedOptions {
 	// config file (lowest priority)
 	localConfig := Get(Scope)
 
+	// the project file nearest to the scope directory (e.g. ./.doppler.yaml)
+	applyProjectFile(&localConfig, Scope)
+
 	// environment variables
 	if CanReadEnv {
 		pairs := models.EnvOptions(&localConfig)
@@ -197,6 +282,20 @@ func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
 		}
 	}
 
+	// a host preset sets both hosts, which the individual host flags can still override
+	if flag := cmd.Flag("host-preset"); flag != nil && flag.Value.String() != "" {
+		name := flag.Value.String()
+		preset, ok := configContents.HostPresets[name]
+		if !ok {
+			utils.HandleError(fmt.Errorf("host preset %q does not exist. Run 'doppler configure hosts list' to view the saved presets", name))
+		}
+
+		localConfig.APIHost = models.ScopedOption{Value: preset.APIHost, Scope: "/", Source: models.FlagSource.String()}
+		if preset.DashboardHost != "" {
+			localConfig.DashboardHost = models.ScopedOption{Value: preset.DashboardHost, Scope: "/", Source: models.FlagSource.String()}
+		}
+	}
+
 	// individual flags (highest priority)
 	flagSet := cmd.Flags().Changed("token")
 	if flagSet || localConfig.Token.Value == "" {
@@ -272,9 +371,34 @@ func LocalConfig(cmd *cobra.Command) models.ScopedOptions {
 		}
 	}
 
+	// the config may be specified along with its project (e.g. "backend/dev")
+	if strings.Contains(localConfig.EnclaveConfig.Value, "/") {
+		project, config, err := SplitProjectConfig(localConfig.EnclaveConfig.Value)
+		if err != nil {
+			utils.HandleError(err)
+		}
+		if cmd.Flags().Changed("project") && localConfig.EnclaveProject.Value != project {
+			utils.HandleError(fmt.Errorf("config %q specifies project %q, which conflicts with --project %q", localConfig.EnclaveConfig.Value, project, localConfig.EnclaveProject.Value))
+		}
+
+		localConfig.EnclaveProject.Value = project
+		localConfig.EnclaveProject.Scope = localConfig.EnclaveConfig.Scope
+		localConfig.EnclaveProject.Source = localConfig.EnclaveConfig.Source
+		localConfig.EnclaveConfig.Value = config
+	}
+
 	return localConfig
 }
 
+// SplitProjectConfig splits a combined "project/config" value on the first slash
+func SplitProjectConfig(value string) (string, string, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid config %q, expected the form project/config", value)
+	}
+	return parts[0], parts[1], nil
+}
+
 // AllConfigs get all configs we know about
 func AllConfigs() map[string]models.FileScopedOptions {
 	all := map[string]models.FileScopedOptions{}
@@ -403,7 +527,9 @@ func ClearConfig() {
 
 // Write config to filesystem
 func writeConfig(config models.ConfigFile) {
-	bytes, err := yaml.Marshal(config)
+	invalidateLocalConfig()
+
+	bytes, err := marshalConfig(config, configFileFormat())
 	if err != nil {
 		utils.HandleError(err)
 	}
@@ -435,7 +561,7 @@ func readConfig() (models.ConfigFile, int, int) {
 	}
 
 	var config models.ConfigFile
-	err = yaml.Unmarshal(fileContents, &config)
+	err = unmarshalConfig(fileContents, configFileFormat(), &config)
 	if err != nil {
 		utils.HandleError(err, "Unable to parse user config file")
 	}
@@ -484,6 +610,52 @@ func readConfig() (models.ConfigFile, int, int) {
 	return config, uid, gid
 }
 
+// configFileFormat returns the format of the config file, as specified by ConfigFormat or the file's extension
+func configFileFormat() string {
+	if ConfigFormat != "" {
+		return ConfigFormat
+	}
+
+	switch strings.ToLower(filepath.Ext(UserConfigFile)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
+func marshalConfig(config models.ConfigFile, format string) ([]byte, error) {
+	switch format {
+	case "toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(config); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		return json.MarshalIndent(config, "", "  ")
+	default:
+		return yaml.Marshal(config)
+	}
+}
+
+func unmarshalConfig(data []byte, format string, config *models.ConfigFile) error {
+	switch format {
+	case "toml":
+		return toml.Unmarshal(data, config)
+	case "json":
+		// an empty file is a valid (empty) config, as it is for yaml
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		return json.Unmarshal(data, config)
+	default:
+		return yaml.Unmarshal(data, config)
+	}
+}
+
 // IsValidConfigOption whether the specified key is a valid config option
 func IsValidConfigOption(key string) bool {
 	configOptions := map[string]interface{}{
@@ -0,0 +1,317 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	config := models.ConfigFile{
+		Scoped: map[string]models.FileScopedOptions{
+			"/":                     {Token: "dp.pt.123", APIHost: "https://api.doppler.com", DashboardHost: "https://dashboard.doppler.com", VerifyTLS: "true"},
+			"/home/user/my project": {EnclaveProject: "backend", EnclaveConfig: "dev_personal"},
+		},
+		VersionCheck: models.VersionCheck{LatestVersion: "3.68.0", CheckedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)},
+		Analytics:    models.AnalyticsOptions{Disable: true},
+		TUI:          models.TUIOptions{IntroVersionSeen: 2},
+		HostPresets: map[string]models.HostPreset{
+			"internal": {APIHost: "https://doppler-api.internal", DashboardHost: "https://doppler.internal"},
+			"staging":  {APIHost: "https://api.staging.internal"},
+		},
+	}
+
+	for _, format := range ConfigFormats {
+		t.Run(format, func(t *testing.T) {
+			data, err := marshalConfig(config, format)
+			assert.NoError(t, err)
+
+			var parsed models.ConfigFile
+			assert.NoError(t, unmarshalConfig(data, format, &parsed))
+			assert.Equal(t, config, parsed)
+
+			// converting to every other format and back must not lose data
+			for _, other := range ConfigFormats {
+				converted, err := marshalConfig(parsed, other)
+				assert.NoError(t, err)
+
+				var reparsed models.ConfigFile
+				assert.NoError(t, unmarshalConfig(converted, other, &reparsed))
+				assert.Equal(t, config, reparsed, "%s -> %s", format, other)
+			}
+		})
+	}
+}
+
+func TestEmptyConfig(t *testing.T) {
+	for _, format := range ConfigFormats {
+		var parsed models.ConfigFile
+		assert.NoError(t, unmarshalConfig([]byte(""), format, &parsed), format)
+		assert.Equal(t, models.ConfigFile{}, parsed, format)
+
+		data, err := marshalConfig(models.ConfigFile{}, format)
+		assert.NoError(t, err, format)
+		parsed = models.ConfigFile{}
+		assert.NoError(t, unmarshalConfig(data, format, &parsed), format)
+		assert.Empty(t, parsed.Scoped, format)
+		assert.True(t, parsed.VersionCheck.CheckedAt.IsZero(), format)
+	}
+}
+
+func TestConfigFileFormat(t *testing.T) {
+	originalFile := UserConfigFile
+	defer func() {
+		UserConfigFile = originalFile
+		ConfigFormat = ""
+	}()
+
+	UserConfigFile = "/home/user/.doppler/.doppler.yaml"
+	assert.Equal(t, "yaml", configFileFormat())
+	UserConfigFile = "/home/user/.doppler/.doppler.TOML"
+	assert.Equal(t, "toml", configFileFormat())
+	UserConfigFile = "/home/user/.doppler/.doppler.json"
+	assert.Equal(t, "json", configFileFormat())
+	UserConfigFile = "/home/user/.doppler/config"
+	assert.Equal(t, "yaml", configFileFormat())
+
+	ConfigFormat = "toml"
+	assert.Equal(t, "toml", configFileFormat())
+}
+
+func TestSplitProjectConfig(t *testing.T) {
+	project, config, err := SplitProjectConfig("backend/dev")
+	assert.NoError(t, err)
+	assert.Equal(t, "backend", project)
+	assert.Equal(t, "dev", config)
+
+	// only the first slash separates the project from the config
+	project, config, err = SplitProjectConfig("backend/dev/personal")
+	assert.NoError(t, err)
+	assert.Equal(t, "backend", project)
+	assert.Equal(t, "dev/personal", config)
+
+	for _, value := range []string{"dev", "/dev", "backend/", "/"} {
+		_, _, err = SplitProjectConfig(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestLocalConfigSnapshot(t *testing.T) {
+	originalFile, originalContents, originalScope, originalCanReadEnv := UserConfigFile, configContents, Scope, CanReadEnv
+	defer func() {
+		UserConfigFile, configContents, Scope, CanReadEnv = originalFile, originalContents, originalScope, originalCanReadEnv
+		invalidateLocalConfig()
+	}()
+	UserConfigFile = filepath.Join(t.TempDir(), ".doppler.yaml")
+	configContents = models.ConfigFile{Scoped: map[string]models.FileScopedOptions{"/": {EnclaveConfig: "dev"}}}
+	Scope = t.TempDir()
+	CanReadEnv = false
+	invalidateLocalConfig()
+
+	// the project file is read each time the config is resolved
+	assert.NoError(t, os.WriteFile(filepath.Join(Scope, ProjectFileName), []byte("project: backend\n"), 0600))
+	originalReadFile := readFile
+	defer func() { readFile = originalReadFile }()
+	reads := 0
+	readFile = func(path string) ([]byte, error) {
+		reads++
+		return originalReadFile(path)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("token", "", "")
+	cmd.Flags().String("api-host", "https://api.doppler.com", "")
+	cmd.Flags().String("dashboard-host", "https://dashboard.doppler.com", "")
+	cmd.Flags().Bool("no-verify-tls", false, "")
+	cmd.Flags().String("project", "", "")
+	cmd.Flags().String("config", "", "")
+
+	assert.Equal(t, "dev", LocalConfig(cmd).EnclaveConfig.Value)
+	assert.Equal(t, "backend", LocalConfig(cmd).EnclaveProject.Value)
+
+	// the config is only resolved once per command, so later reads see the same snapshot
+	configContents.Scoped["/"] = models.FileScopedOptions{EnclaveConfig: "stg"}
+	assert.Equal(t, "dev", LocalConfig(cmd).EnclaveConfig.Value)
+
+	// modifying the returned config doesn't modify the snapshot
+	localConfig := LocalConfig(cmd)
+	localConfig.EnclaveConfig.Value = "qa"
+	assert.Equal(t, "dev", LocalConfig(cmd).EnclaveConfig.Value)
+	assert.Equal(t, 1, reads)
+
+	// each command resolves its own config
+	other := &cobra.Command{}
+	other.Flags().AddFlagSet(cmd.Flags())
+	assert.Equal(t, "stg", LocalConfig(other).EnclaveConfig.Value)
+	assert.Equal(t, 2, reads)
+
+	// writing the config file discards the snapshot
+	Set("/", map[string]string{models.ConfigEnclaveConfig.String(): "prd"})
+	assert.Equal(t, "prd", LocalConfig(cmd).EnclaveConfig.Value)
+	assert.Equal(t, 3, reads)
+}
+
+func TestLocalConfigHostPreset(t *testing.T) {
+	originalFile, originalContents, originalScope, originalCanReadEnv := UserConfigFile, configContents, Scope, CanReadEnv
+	defer func() {
+		UserConfigFile, configContents, Scope, CanReadEnv = originalFile, originalContents, originalScope, originalCanReadEnv
+		invalidateLocalConfig()
+	}()
+	UserConfigFile = filepath.Join(t.TempDir(), ".doppler.yaml")
+	configContents = models.ConfigFile{Scoped: map[string]models.FileScopedOptions{"/": {APIHost: "https://api.example.com"}}}
+	Scope = "/"
+	CanReadEnv = false
+	invalidateLocalConfig()
+
+	SetHostPreset("internal", models.HostPreset{APIHost: "https://doppler-api.internal", DashboardHost: "https://doppler.internal"})
+	SetHostPreset("api-only", models.HostPreset{APIHost: "https://api.staging.internal"})
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("token", "", "")
+		cmd.Flags().String("api-host", "https://api.doppler.com", "")
+		cmd.Flags().String("dashboard-host", "https://dashboard.doppler.com", "")
+		cmd.Flags().String("host-preset", "", "")
+		cmd.Flags().Bool("no-verify-tls", false, "")
+		cmd.Flags().String("project", "", "")
+		cmd.Flags().String("config", "", "")
+		assert.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	// the preset takes precedence over the config file
+	localConfig := LocalConfig(newCmd("--host-preset", "internal"))
+	assert.Equal(t, "https://doppler-api.internal", localConfig.APIHost.Value)
+	assert.Equal(t, "https://doppler.internal", localConfig.DashboardHost.Value)
+
+	// a preset without a dashboard host leaves the dashboard host unchanged
+	localConfig = LocalConfig(newCmd("--host-preset", "api-only"))
+	assert.Equal(t, "https://api.staging.internal", localConfig.APIHost.Value)
+	assert.Equal(t, "https://dashboard.doppler.com", localConfig.DashboardHost.Value)
+
+	// individual host flags take precedence over the preset
+	localConfig = LocalConfig(newCmd("--host-preset", "internal", "--api-host", "https://api.override"))
+	assert.Equal(t, "https://api.override", localConfig.APIHost.Value)
+	assert.Equal(t, "https://doppler.internal", localConfig.DashboardHost.Value)
+
+	assert.True(t, RemoveHostPreset("api-only"))
+	assert.False(t, RemoveHostPreset("api-only"))
+	assert.Equal(t, []string{"internal"}, func() []string {
+		var names []string
+		for name := range HostPresets() {
+			names = append(names, name)
+		}
+		return names
+	}())
+}
+
+func TestLocalConfigProjectFile(t *testing.T) {
+	originalFile, originalContents, originalScope, originalCanReadEnv := UserConfigFile, configContents, Scope, CanReadEnv
+	defer func() {
+		UserConfigFile, configContents, Scope, CanReadEnv = originalFile, originalContents, originalScope, originalCanReadEnv
+		invalidateLocalConfig()
+	}()
+
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "api")
+	assert.NoError(t, os.MkdirAll(nested, 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ProjectFileName), []byte("project: backend\n"), 0600))
+
+	// the user's config file shares the project file's name, but is never used as a project file
+	UserConfigFile = filepath.Join(nested, ".doppler.yaml")
+	assert.NoError(t, os.WriteFile(UserConfigFile, []byte("project: ignored\n"), 0600))
+	configContents = models.ConfigFile{Scoped: map[string]models.FileScopedOptions{"/": {EnclaveProject: "frontend", EnclaveConfig: "dev"}}}
+	Scope = nested
+	CanReadEnv = false
+
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("token", "", "")
+		cmd.Flags().String("api-host", "https://api.doppler.com", "")
+		cmd.Flags().String("dashboard-host", "https://dashboard.doppler.com", "")
+		cmd.Flags().Bool("no-verify-tls", false, "")
+		cmd.Flags().String("project", "", "")
+		cmd.Flags().String("config", "", "")
+		assert.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	// the nearest project file takes precedence over the config file, which still provides the config
+	invalidateLocalConfig()
+	localConfig := LocalConfig(newCmd())
+	assert.Equal(t, "backend", localConfig.EnclaveProject.Value)
+	assert.Equal(t, root, localConfig.EnclaveProject.Scope)
+	assert.Equal(t, models.ProjectFileSource.String(), localConfig.EnclaveProject.Source)
+	assert.Equal(t, "dev", localConfig.EnclaveConfig.Value)
+
+	// a nearer project file is used instead
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "services", ProjectFileName), []byte("project: billing\nconfig: stg\n"), 0600))
+	invalidateLocalConfig()
+	localConfig = LocalConfig(newCmd())
+	assert.Equal(t, "billing", localConfig.EnclaveProject.Value)
+	assert.Equal(t, "stg", localConfig.EnclaveConfig.Value)
+
+	// environment variables take precedence over the project file
+	CanReadEnv = true
+	t.Setenv("DOPPLER_PROJECT", "payments")
+	localConfig = LocalConfig(newCmd())
+	assert.Equal(t, "payments", localConfig.EnclaveProject.Value)
+	assert.Equal(t, "stg", localConfig.EnclaveConfig.Value)
+
+	// as do flags
+	localConfig = LocalConfig(newCmd("--project", "web", "--config", "prd"))
+	assert.Equal(t, "web", localConfig.EnclaveProject.Value)
+	assert.Equal(t, "prd", localConfig.EnclaveConfig.Value)
+
+	// a value set up for a directory within the project file's directory takes precedence over the project file
+	CanReadEnv = false
+	configContents.Scoped[nested] = models.FileScopedOptions{EnclaveProject: "scoped"}
+	invalidateLocalConfig()
+	localConfig = LocalConfig(newCmd())
+	assert.Equal(t, "scoped", localConfig.EnclaveProject.Value)
+	assert.Equal(t, nested, localConfig.EnclaveProject.Scope)
+	assert.Equal(t, models.ConfigFileSource.String(), localConfig.EnclaveProject.Source)
+	assert.Equal(t, "stg", localConfig.EnclaveConfig.Value)
+	assert.Equal(t, models.ProjectFileSource.String(), localConfig.EnclaveConfig.Source)
+
+	// but not over a project file in the same directory
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ProjectFileName), []byte("project: backend\nconfig: qa\n"), 0600))
+	configContents.Scoped[root] = models.FileScopedOptions{EnclaveConfig: "prd"}
+	delete(configContents.Scoped, nested)
+	assert.NoError(t, os.Remove(filepath.Join(root, "services", ProjectFileName)))
+	invalidateLocalConfig()
+	localConfig = LocalConfig(newCmd())
+	assert.Equal(t, "backend", localConfig.EnclaveProject.Value)
+	assert.Equal(t, "qa", localConfig.EnclaveConfig.Value)
+	assert.Equal(t, models.ProjectFileSource.String(), localConfig.EnclaveConfig.Source)
+
+	// an invalid project file is ignored
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ProjectFileName), []byte("project: [backend\n"), 0600))
+	_, err := readProjectFile(filepath.Join(root, ProjectFileName))
+	assert.Error(t, err)
+	invalidateLocalConfig()
+	localConfig = LocalConfig(newCmd())
+	assert.Equal(t, "frontend", localConfig.EnclaveProject.Value)
+	assert.Equal(t, models.ConfigFileSource.String(), localConfig.EnclaveProject.Source)
+	assert.Equal(t, "prd", localConfig.EnclaveConfig.Value)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package configuration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName the name of the file specifying the default project and config of a directory and its subdirectories
+const ProjectFileName = ".doppler.yaml"
+
+// projectFile the contents of a project file (e.g. "project: backend" and "config: dev")
+type projectFile struct {
+	Project string `yaml:"project"`
+	Config  string `yaml:"config"`
+}
+
+// readFile reads project files, and is replaced by tests to count the reads
+var readFile = ioutil.ReadFile
+
+// findProjectFile returns the path of the project file nearest to the directory, searching the directory and then
+// each of its parents. The user's config file shares the project file's name, so it's never returned.
+func findProjectFile(dir string) (string, bool) {
+	userConfigFile, err := filepath.Abs(UserConfigFile)
+	if err != nil {
+		userConfigFile = UserConfigFile
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if path != userConfigFile && utils.Exists(path) {
+			return path, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func readProjectFile(path string) (projectFile, error) {
+	// #nosec G304
+	data, err := readFile(path)
+	if err != nil {
+		return projectFile{}, err
+	}
+
+	var file projectFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return projectFile{}, err
+	}
+	return file, nil
+}
+
+// applyProjectFile sets the project and config specified by the project file nearest to the scope, if any. Like the
+// config file's scopes, the most specific directory wins: a value set via 'doppler setup' for a directory within the
+// project file's directory takes precedence over the project file. An invalid project file is ignored with a warning.
+func applyProjectFile(localConfig *models.ScopedOptions, scope string) {
+	dir, err := NormalizeScope(scope)
+	if err != nil {
+		utils.HandleError(err, fmt.Sprintf("Invalid scope: %s", scope))
+	}
+
+	path, ok := findProjectFile(dir)
+	if !ok {
+		return
+	}
+	utils.LogDebug(fmt.Sprintf("Using project file %s", path))
+
+	file, err := readProjectFile(path)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Ignoring invalid project file %s: %s", path, err))
+		return
+	}
+
+	scope = filepath.Dir(path)
+	if file.Project != "" && projectFileTakesPrecedence(scope, localConfig.EnclaveProject) {
+		localConfig.EnclaveProject = models.ScopedOption{Value: file.Project, Scope: scope, Source: models.ProjectFileSource.String()}
+	}
+	if file.Config != "" && projectFileTakesPrecedence(scope, localConfig.EnclaveConfig) {
+		localConfig.EnclaveConfig = models.ScopedOption{Value: file.Config, Scope: scope, Source: models.ProjectFileSource.String()}
+	}
+}
+
+// projectFileTakesPrecedence returns whether the project file in the directory should override the config file's value.
+// Both the directory and the value's scope contain the scope directory, so the longer path is the more specific one.
+func projectFileTakesPrecedence(dir string, option models.ScopedOption) bool {
+	if option.Value == "" {
+		return true
+	}
+	return len(filepath.Clean(dir)) >= len(filepath.Clean(option.Scope))
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/DopplerHQ/cli/pkg/version"
+)
+
+// the formats supported by FormatAuditEvent
+const (
+	NDJSONAuditFormat = "ndjson"
+	CEFAuditFormat    = "cef"
+)
+
+// AuditFormats the formats supported by FormatAuditEvent
+var AuditFormats = []string{NDJSONAuditFormat, CEFAuditFormat}
+
+// the actions of exported audit events
+const (
+	AuditActionSecretsUpdate = "config.secrets.update"
+	AuditActionConfigUpdate  = "config.update"
+)
+
+// GetConfigLogsBetween fetches the config's logs created within the specified bounds (a zero time is unbounded),
+// paging through the logs until they're older than since. Logs are returned newest first.
+func GetConfigLogsBetween(config models.ScopedOptions, since time.Time, until time.Time) ([]models.ConfigLog, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	var logs []models.ConfigLog
+	for page := 1; ; page++ {
+		pageLogs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, page, utils.PageSize)
+		if !err.IsNil() {
+			return nil, Error{Err: err.Unwrap(), Message: err.Message}
+		}
+
+		reachedSince := false
+		for _, log := range pageLogs {
+			createdAt, parseErr := time.Parse(time.RFC3339, log.CreatedAt)
+			if parseErr != nil {
+				// the log can't be placed within the bounds
+				if since.IsZero() && until.IsZero() {
+					logs = append(logs, log)
+				}
+				continue
+			}
+			if !since.IsZero() && createdAt.Before(since) {
+				reachedSince = true
+				continue
+			}
+			if !until.IsZero() && createdAt.After(until) {
+				continue
+			}
+			logs = append(logs, log)
+		}
+
+		if reachedSince || len(pageLogs) < utils.PageSize {
+			return logs, Error{}
+		}
+	}
+}
+
+// GetLatestConfigLogBefore fetches the most recent of the config's logs created at or before the specified time,
+// paging through the logs until one is found. Like GetConfigLogsBetween, this relies on the API returning logs newest
+// first, so the first such log is the most recent. The returned bool is false if no log predates the time.
+func GetLatestConfigLogBefore(config models.ScopedOptions, before time.Time) (models.ConfigLog, bool, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	for page := 1; ; page++ {
+		pageLogs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, page, utils.PageSize)
+		if !err.IsNil() {
+			return models.ConfigLog{}, false, Error{Err: err.Unwrap(), Message: err.Message}
+		}
+
+		for _, log := range pageLogs {
+			createdAt, parseErr := time.Parse(time.RFC3339, log.CreatedAt)
+			if parseErr == nil && !createdAt.After(before) {
+				return log, true, Error{}
+			}
+		}
+
+		if len(pageLogs) < utils.PageSize {
+			return models.ConfigLog{}, false, Error{}
+		}
+	}
+}
+
+// NewAuditEvent converts the config log to an audit event. Only the names of changed secrets are included.
+func NewAuditEvent(log models.ConfigLog) models.AuditEvent {
+	event := models.AuditEvent{
+		ID:          log.ID,
+		Timestamp:   log.CreatedAt,
+		Actor:       models.AuditActor{Email: log.User.Email, Name: log.User.Name},
+		Action:      AuditActionConfigUpdate,
+		Project:     log.Project,
+		Environment: log.Environment,
+		Config:      log.Config,
+		Message:     log.Text,
+		Diff:        models.AuditDiffSummary{Added: []string{}, Changed: []string{}, Removed: []string{}},
+	}
+	if t, err := time.Parse(time.RFC3339, log.CreatedAt); err == nil {
+		event.Timestamp = t.UTC().Format(time.RFC3339)
+	}
+
+	for _, diff := range log.Diff {
+		event.Action = AuditActionSecretsUpdate
+		switch {
+		case diff.Removed == "" && diff.Added != "":
+			event.Diff.Added = append(event.Diff.Added, diff.Name)
+		case diff.Added == "" && diff.Removed != "":
+			event.Diff.Removed = append(event.Diff.Removed, diff.Name)
+		default:
+			event.Diff.Changed = append(event.Diff.Changed, diff.Name)
+		}
+	}
+	return event
+}
+
+// FormatAuditEvent formats the event as a single line in the specified format
+func FormatAuditEvent(event models.AuditEvent, format string) (string, error) {
+	switch format {
+	case NDJSONAuditFormat:
+		line, err := json.Marshal(event)
+		return string(line), err
+	case CEFAuditFormat:
+		return formatCEF(event), nil
+	default:
+		return "", fmt.Errorf("invalid format %q. Valid formats are %v", format, AuditFormats)
+	}
+}
+
+// formatCEF formats the event in ArcSight's Common Event Format
+func formatCEF(event models.AuditEvent) string {
+	header := []string{"CEF:0", "Doppler", "Doppler CLI", version.ProgramVersion, event.Action, event.Message, "3"}
+	for i := 1; i < len(header); i++ {
+		header[i] = escapeCEFHeader(header[i])
+	}
+
+	actor := event.Actor.Email
+	if actor == "" {
+		actor = event.Actor.Name
+	}
+
+	var extension []string
+	addField := func(key string, value string) {
+		if value != "" {
+			extension = append(extension, key+"="+escapeCEFExtension(value))
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		addField("rt", fmt.Sprint(t.UnixMilli()))
+	}
+	addField("externalId", event.ID)
+	addField("suser", actor)
+	addField("cs1Label", "project")
+	addField("cs1", event.Project)
+	addField("cs2Label", "environment")
+	addField("cs2", event.Environment)
+	addField("cs3Label", "config")
+	addField("cs3", event.Config)
+	addField("cs4Label", "diff")
+	addField("cs4", auditDiffSummary(event.Diff))
+
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
+
+// auditDiffSummary summarizes the diff, e.g. "added: A, B; removed: C"
+func auditDiffSummary(diff models.AuditDiffSummary) string {
+	var parts []string
+	for _, group := range []struct {
+		label string
+		names []string
+	}{{"added", diff.Added}, {"changed", diff.Changed}, {"removed", diff.Removed}} {
+		if len(group.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", group.label, strings.Join(group.names, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// escapeCEFHeader escapes backslashes and pipes, which delimit the header fields
+func escapeCEFHeader(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+// escapeCEFExtension escapes backslashes and equals signs, and encodes line breaks
+func escapeCEFExtension(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(value)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/DopplerHQ/cli/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAuditEvent(t *testing.T) {
+	log := models.ConfigLog{
+		ID:          "log1",
+		Text:        "Jane | updated=secrets",
+		CreatedAt:   "2024-01-02T12:00:00.000+01:00",
+		Project:     "backend",
+		Environment: "prd",
+		Config:      "prd",
+		User:        models.User{Email: "jane@example.com", Name: "Jane"},
+		Diff: []models.LogDiff{
+			{Name: "NEW", Added: "secret"},
+			{Name: "UPDATED", Added: "new", Removed: "old"},
+			{Name: "DELETED", Removed: "old"},
+		},
+	}
+	event := NewAuditEvent(log)
+	assert.Equal(t, "2024-01-02T11:00:00Z", event.Timestamp)
+	assert.Equal(t, AuditActionSecretsUpdate, event.Action)
+	assert.Equal(t, models.AuditDiffSummary{Added: []string{"NEW"}, Changed: []string{"UPDATED"}, Removed: []string{"DELETED"}}, event.Diff)
+
+	line, err := FormatAuditEvent(event, NDJSONAuditFormat)
+	assert.NoError(t, err)
+	assert.NotContains(t, line, "\n")
+	// secret values are never exported
+	assert.NotContains(t, line, "old")
+	var parsed models.AuditEvent
+	assert.NoError(t, json.Unmarshal([]byte(line), &parsed))
+	assert.Equal(t, event, parsed)
+
+	line, err = FormatAuditEvent(event, CEFAuditFormat)
+	assert.NoError(t, err)
+	assert.Equal(t, "CEF:0|Doppler|Doppler CLI|"+version.ProgramVersion+`|config.secrets.update|Jane \| updated=secrets|3|`+
+		"rt=1704193200000 externalId=log1 suser=jane@example.com cs1Label=project cs1=backend cs2Label=environment cs2=prd cs3Label=config cs3=prd "+
+		"cs4Label=diff cs4=added: NEW; changed: UPDATED; removed: DELETED", line)
+
+	// logs without a diff don't change secrets
+	event = NewAuditEvent(models.ConfigLog{ID: "log2", Text: "line1\nline=2", User: models.User{Name: "Jane"}})
+	assert.Equal(t, AuditActionConfigUpdate, event.Action)
+	line, err = FormatAuditEvent(event, CEFAuditFormat)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(line, `|config.update|line1 line=2|3|externalId=log2 suser=Jane cs1Label=project cs2Label=environment cs3Label=config cs4Label=diff`), line)
+
+	_, err = FormatAuditEvent(event, "xml")
+	assert.Error(t, err)
+}
+
+func TestGetConfigLogsBetween(t *testing.T) {
+	// one log per day, newest first, starting on 2024-12-31
+	newest := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	var requestedPages []string
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		page := r.URL.Query().Get("page")
+		requestedPages = append(requestedPages, page)
+		var pageNumber, perPage int
+		fmt.Sscan(page, &pageNumber)
+		fmt.Sscan(r.URL.Query().Get("per_page"), &perPage)
+
+		var logs []string
+		for i := 0; i < perPage; i++ {
+			day := (pageNumber-1)*perPage + i
+			logs = append(logs, fmt.Sprintf(`{"id":"log%d","created_at":%q}`, day, newest.AddDate(0, 0, -day).Format(time.RFC3339)))
+		}
+		fmt.Fprintf(w, `{"logs":[%s]}`, strings.Join(logs, ","))
+	})
+
+	since := newest.AddDate(0, 0, -150)
+	until := newest.AddDate(0, 0, -140)
+	logs, err := GetConfigLogsBetween(options, since, until)
+	assert.True(t, err.IsNil())
+	assert.Len(t, logs, 11)
+	assert.Equal(t, "log140", logs[0].ID)
+	assert.Equal(t, "log150", logs[10].ID)
+	// paging stops once the logs are older than since
+	assert.Equal(t, []string{"1", "2"}, requestedPages)
+
+	// smaller pages require more requests
+	utils.PageSize = 50
+	requestedPages = nil
+	logs, err = GetConfigLogsBetween(options, since, until)
+	assert.True(t, err.IsNil())
+	assert.Len(t, logs, 11)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, requestedPages)
+}
+
+func TestGetLatestConfigLogBefore(t *testing.T) {
+	// 150 logs, one per day, newest first, starting on 2024-12-31
+	newest := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	total := 150
+	var requestedPages []string
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		page := r.URL.Query().Get("page")
+		requestedPages = append(requestedPages, page)
+		var pageNumber, perPage int
+		fmt.Sscan(page, &pageNumber)
+		fmt.Sscan(r.URL.Query().Get("per_page"), &perPage)
+
+		var logs []string
+		for i := 0; i < perPage; i++ {
+			day := (pageNumber-1)*perPage + i
+			if day >= total {
+				break
+			}
+			logs = append(logs, fmt.Sprintf(`{"id":"log%d","created_at":%q}`, day, newest.AddDate(0, 0, -day).Format(time.RFC3339)))
+		}
+		fmt.Fprintf(w, `{"logs":[%s]}`, strings.Join(logs, ","))
+	})
+
+	// a log created exactly at the time is included
+	log, found, err := GetLatestConfigLogBefore(options, newest.AddDate(0, 0, -2))
+	assert.True(t, err.IsNil())
+	assert.True(t, found)
+	assert.Equal(t, "log2", log.ID)
+	assert.Equal(t, []string{"1"}, requestedPages)
+
+	requestedPages = nil
+	log, found, err = GetLatestConfigLogBefore(options, newest.AddDate(0, 0, -120).Add(-time.Hour))
+	assert.True(t, err.IsNil())
+	assert.True(t, found)
+	assert.Equal(t, "log121", log.ID)
+	assert.Equal(t, []string{"1", "2"}, requestedPages)
+
+	// no log predates the time
+	requestedPages = nil
+	_, found, err = GetLatestConfigLogBefore(options, newest.AddDate(-1, 0, 0))
+	assert.True(t, err.IsNil())
+	assert.False(t, found)
+	assert.Equal(t, []string{"1", "2"}, requestedPages)
+}
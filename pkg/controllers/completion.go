@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/configuration"
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// CompletionCacheTTL the max age of cached completion results
+const CompletionCacheTTL = 10 * time.Minute
+
+type completionCache struct {
+	Names     []string  `json:"names"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetSecretNamesForCompletion returns the config's secret names. Names are cached for the duration of the
+// shell session (up to CompletionCacheTTL) so that secrets aren't fetched on every tab press.
+func GetSecretNamesForCompletion(config models.ScopedOptions) ([]string, Error) {
+	cacheDir := filepath.Join(configuration.UserConfigDir, "completion")
+	key := crypto.Hash(fmt.Sprintf("%s:%s:%s:%s:%s", config.APIHost.Value, config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, utils.SessionID()))
+	cacheFile := filepath.Join(cacheDir, fmt.Sprintf(".secret-names-%s.json", key))
+
+	if contents, err := ioutil.ReadFile(cacheFile); err == nil { // #nosec G304
+		var cache completionCache
+		if err := json.Unmarshal(contents, &cache); err == nil && time.Since(cache.CreatedAt) < CompletionCacheTTL {
+			return cache.Names, Error{}
+		}
+	}
+
+	names, err := GetSecretNames(config)
+	if !err.IsNil() {
+		return nil, err
+	}
+
+	// failing to write the cache only means the names will be fetched again
+	if contents, jsonErr := json.Marshal(completionCache{Names: names, CreatedAt: time.Now()}); jsonErr == nil {
+		if mkdirErr := os.MkdirAll(cacheDir, 0700); mkdirErr == nil {
+			if writeErr := utils.WriteFile(cacheFile, contents, utils.RestrictedFilePerms()); writeErr != nil {
+				utils.LogDebugError(writeErr)
+			}
+		}
+	}
+
+	return names, Error{}
+}
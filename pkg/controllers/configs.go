@@ -16,6 +16,17 @@ limitations under the License.
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/DopplerHQ/cli/pkg/utils"
@@ -32,6 +43,245 @@ func GetConfigs(config models.ScopedOptions) ([]models.ConfigInfo, Error) {
 	return configs, Error{}
 }
 
+// GetAllConfigs fetches the project's configs, optionally limited to an environment, paging through them (see
+// utils.PageSize) until the last page
+func GetAllConfigs(config models.ScopedOptions, project string, environment string) ([]models.ConfigInfo, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	var configs []models.ConfigInfo
+	for page := 1; ; page++ {
+		pageConfigs, err := http.GetConfigs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, project, environment, page, utils.PageSize)
+		if !err.IsNil() {
+			return nil, Error{Err: err.Unwrap(), Message: err.Message}
+		}
+
+		configs = append(configs, pageConfigs...)
+		if len(pageConfigs) < utils.PageSize {
+			return configs, Error{}
+		}
+	}
+}
+
+// GetConfigsForProjects fetches configs for each of the specified projects in parallel (see utils.Concurrency). Configs are returned in
+// project order; projects whose configs can't be fetched are skipped and their errors returned. A number of 0 fetches
+// every page of each project's configs.
+func GetConfigsForProjects(config models.ScopedOptions, projects []string, environment string, page int, number int) ([]models.ConfigInfo, []Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	results := make([][]models.ConfigInfo, len(projects))
+	errs := make([]Error, len(projects))
+
+	utils.ForEachConcurrently(len(projects), utils.Concurrency, func(i int) {
+		project := projects[i]
+		var configs []models.ConfigInfo
+		var err Error
+		if number == 0 {
+			configs, err = GetAllConfigs(config, project, environment)
+		} else {
+			var httpErr http.Error
+			configs, httpErr = http.GetConfigs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, project, environment, page, number)
+			err = Error{Err: httpErr.Unwrap(), Message: httpErr.Message}
+		}
+		if !err.IsNil() {
+			errs[i] = Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to fetch configs for project %s", project)}
+			return
+		}
+		results[i] = configs
+	})
+
+	var configs []models.ConfigInfo
+	var failures []Error
+	for i := range projects {
+		if !errs[i].IsNil() {
+			failures = append(failures, errs[i])
+			continue
+		}
+		configs = append(configs, results[i]...)
+	}
+	return configs, failures
+}
+
+// GetConfigsByName fetches each of the specified configs in parallel (see utils.Concurrency). Configs are returned in
+// the order specified; configs that can't be fetched are skipped and their errors returned. With failFast, configs
+// that haven't started being fetched are skipped once a fetch fails.
+func GetConfigsByName(config models.ScopedOptions, names []string, failFast bool) ([]models.ConfigInfo, []Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	results := make([]models.ConfigInfo, len(names))
+	errs := make([]Error, len(names))
+	fetched := make([]bool, len(names))
+	var failed atomic.Bool
+
+	utils.ForEachConcurrently(len(names), utils.Concurrency, func(i int) {
+		if failFast && failed.Load() {
+			return
+		}
+		configInfo, err := http.GetConfig(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, names[i])
+		if !err.IsNil() {
+			errs[i] = Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to fetch config %s", names[i])}
+			failed.Store(true)
+			return
+		}
+		results[i] = configInfo
+		fetched[i] = true
+	})
+
+	var configs []models.ConfigInfo
+	var failures []Error
+	for i := range names {
+		if !errs[i].IsNil() {
+			failures = append(failures, errs[i])
+		} else if fetched[i] {
+			configs = append(configs, results[i])
+		}
+	}
+	return configs, failures
+}
+
+// AddSecretCounts populates the secret count of each config that the API didn't already report one for
+func AddSecretCounts(config models.ScopedOptions, configs []models.ConfigInfo) []Error {
+	return enrichConfigs(config, configs, func(configInfo *models.ConfigInfo) Error {
+		if configInfo.SecretCount != nil {
+			return Error{}
+		}
+
+		names, err := http.GetSecretNames(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, configInfo.Project, configInfo.Name, false)
+		if !err.IsNil() {
+			return Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to count secrets for config %s/%s", configInfo.Project, configInfo.Name)}
+		}
+		count := len(names)
+		configInfo.SecretCount = &count
+		return Error{}
+	})
+}
+
+// AddLastModifiedBy populates the author of each config's most recent change, using the config's audit log when the
+// API didn't already report one. configs without any logs have an empty author.
+func AddLastModifiedBy(config models.ScopedOptions, configs []models.ConfigInfo) []Error {
+	return enrichConfigs(config, configs, func(configInfo *models.ConfigInfo) Error {
+		if configInfo.LastModifiedBy != nil {
+			return Error{}
+		}
+
+		logs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, configInfo.Project, configInfo.Name, 1, 1)
+		if !err.IsNil() {
+			return Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to fetch the last author of config %s/%s", configInfo.Project, configInfo.Name)}
+		}
+		author := ""
+		if len(logs) > 0 {
+			author = logs[0].User.Email
+			if author == "" {
+				author = logs[0].User.Name
+			}
+		}
+		configInfo.LastModifiedBy = &author
+		return Error{}
+	})
+}
+
+// enrichConfigs calls enrich concurrently for each config, returning the errors of those that couldn't be enriched
+func enrichConfigs(config models.ScopedOptions, configs []models.ConfigInfo, enrich func(configInfo *models.ConfigInfo) Error) []Error {
+	utils.RequireValue("token", config.Token.Value)
+
+	errs := make([]Error, len(configs))
+	utils.ForEachConcurrently(len(configs), utils.Concurrency, func(i int) {
+		errs[i] = enrich(&configs[i])
+	})
+
+	var failures []Error
+	for _, err := range errs {
+		if !err.IsNil() {
+			failures = append(failures, err)
+		}
+	}
+	return failures
+}
+
+// DeleteConfig deletes the config in the configured project. When unlock is true, the config is unlocked first and is
+// locked again if it can't be deleted, so that a failure doesn't leave it unlocked.
+func DeleteConfig(config models.ScopedOptions, name string, unlock bool) Error {
+	utils.RequireValue("token", config.Token.Value)
+
+	verifyTLS := utils.GetBool(config.VerifyTLS.Value, true)
+	if unlock {
+		if _, err := http.UnlockConfig(config.APIHost.Value, verifyTLS, config.Token.Value, config.EnclaveProject.Value, name); !err.IsNil() {
+			return Error{Err: err.Unwrap(), Message: err.Message}
+		}
+	}
+
+	err := http.DeleteConfig(config.APIHost.Value, verifyTLS, config.Token.Value, config.EnclaveProject.Value, name)
+	if err.IsNil() {
+		return Error{}
+	}
+	if !unlock {
+		return Error{Err: err.Unwrap(), Message: err.Message}
+	}
+
+	if _, lockErr := http.LockConfig(config.APIHost.Value, verifyTLS, config.Token.Value, config.EnclaveProject.Value, name); !lockErr.IsNil() {
+		return Error{
+			Err:     fmt.Errorf("%w\nunable to lock config %s again: %s", err.Unwrap(), name, lockErr.Unwrap()),
+			Message: fmt.Sprintf("%s. The config was unlocked and couldn't be locked again", err.Message),
+		}
+	}
+	utils.LogDebug(fmt.Sprintf("Locked config %s again", name))
+	return Error{Err: err.Unwrap(), Message: err.Message}
+}
+
+// ConfigExists returns whether a config with the name exists in the configured project. Only a response of HTTP 404
+// means the config doesn't exist; any other failure is returned as an error.
+func ConfigExists(config models.ScopedOptions, name string) (bool, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	_, err := http.GetConfig(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, name)
+	if err.IsNil() {
+		return true, Error{}
+	}
+	if err.Code == nethttp.StatusNotFound {
+		return false, Error{}
+	}
+	return false, Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to check whether config %s exists", name)}
+}
+
+// DiffConfigSecrets returns the changes needed to make the secrets of config `from` match those of config `to`, both in
+// the configured project. Raw values are compared, and Doppler's metadata secrets (which always differ) are ignored.
+func DiffConfigSecrets(config models.ScopedOptions, from string, to string) ([]models.ConfigSecretDiff, Error) {
+	values := make([]map[string]string, 2)
+	errs := make([]Error, 2)
+	utils.ForEachConcurrently(2, utils.Concurrency, func(i int) {
+		scoped := config
+		scoped.EnclaveConfig.Value = []string{from, to}[i]
+		secrets, _, err := GetRawSecretValues(scoped)
+		if !err.IsNil() {
+			errs[i] = Error{Err: err.Unwrap(), Message: fmt.Sprintf("Unable to fetch secrets for config %s", scoped.EnclaveConfig.Value)}
+			return
+		}
+		values[i] = secrets
+	})
+	for _, err := range errs {
+		if !err.IsNil() {
+			return nil, err
+		}
+	}
+
+	fromValues, toValues := values[0], values[1]
+	diff := DiffSecrets(fromValues, nil, toValues)
+	var changes []models.ConfigSecretDiff
+	value := func(values map[string]string, name string) *string {
+		v := values[name]
+		return &v
+	}
+	for _, name := range diff.Added {
+		changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretAdded, To: value(toValues, name)})
+	}
+	for _, name := range diff.Changed {
+		changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretChanged, From: value(fromValues, name), To: value(toValues, name)})
+	}
+	for _, name := range diff.Removed {
+		changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretRemoved, From: value(fromValues, name)})
+	}
+	return changes, Error{}
+}
+
 func GetConfigNames(config models.ScopedOptions) ([]string, Error) {
 	configs, err := GetConfigs(config)
 	if !err.IsNil() {
@@ -60,6 +310,134 @@ func GetConfigLogIDs(config models.ScopedOptions) ([]string, Error) {
 	return names, Error{}
 }
 
+// ResolveConfigLogID resolves an abbreviated log ID (i.e. a prefix of the ID) to the full ID of the config's log.
+// IDs that don't prefix any log are returned as-is, leaving the API to report that the log doesn't exist.
+func ResolveConfigLogID(config models.ScopedOptions, id string) (string, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	var candidates []string
+	for page := 1; ; page++ {
+		pageLogs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, page, utils.PageSize)
+		if !err.IsNil() {
+			return "", Error{Err: err.Unwrap(), Message: err.Message}
+		}
+
+		for _, log := range pageLogs {
+			if log.ID == id {
+				return id, Error{}
+			}
+			if strings.HasPrefix(log.ID, id) {
+				candidates = append(candidates, log.ID)
+			}
+		}
+
+		if len(pageLogs) < utils.PageSize {
+			break
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return id, Error{}
+	case 1:
+		return candidates[0], Error{}
+	default:
+		return "", Error{Err: fmt.Errorf("log ID %s is ambiguous. Candidates:\n%s", id, strings.Join(candidates, "\n"))}
+	}
+}
+
+// ConfigLogMatchesActor returns whether the log was made by the actor, which is matched case-insensitively against the
+// email, name, and username of the log's user. Changes made by service tokens are attributed to the token's slug.
+func ConfigLogMatchesActor(log models.ConfigLog, actor string) bool {
+	for _, value := range []string{log.User.Email, log.User.Name, log.User.Username} {
+		if value != "" && strings.EqualFold(value, actor) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConfigLogsByActor fetches up to max of the config's logs made by the actor, paging through the logs since the API
+// can't filter them. Logs are returned newest first.
+func GetConfigLogsByActor(config models.ScopedOptions, actor string, max int) ([]models.ConfigLog, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	logs := []models.ConfigLog{}
+	for page := 1; ; page++ {
+		pageLogs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, page, utils.PageSize)
+		if !err.IsNil() {
+			return nil, Error{Err: err.Unwrap(), Message: err.Message}
+		}
+
+		for _, log := range pageLogs {
+			if !ConfigLogMatchesActor(log, actor) {
+				continue
+			}
+			logs = append(logs, log)
+			if len(logs) == max {
+				return logs, Error{}
+			}
+		}
+
+		if len(pageLogs) < utils.PageSize {
+			return logs, Error{}
+		}
+	}
+}
+
+// GetAllConfigLogs fetches all of the config's logs, or up to max logs when max is positive, fetching several pages
+// concurrently. Logs are returned newest first, without duplicates (which occur when logs are created while paging).
+func GetAllConfigLogs(config models.ScopedOptions, max int) ([]models.ConfigLog, Error) {
+	utils.RequireValue("token", config.Token.Value)
+
+	concurrency := utils.Max(utils.Concurrency, 1)
+	var logs []models.ConfigLog
+	seen := map[string]bool{}
+	for firstPage := 1; ; firstPage += concurrency {
+		pages := make([][]models.ConfigLog, concurrency)
+		errs := make([]Error, concurrency)
+		utils.ForEachConcurrently(concurrency, concurrency, func(i int) {
+			pageLogs, err := http.GetConfigLogs(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value, firstPage+i, utils.PageSize)
+			if !err.IsNil() {
+				errs[i] = Error{Err: err.Unwrap(), Message: err.Message}
+				return
+			}
+			pages[i] = pageLogs
+		})
+
+		lastPage := false
+		for i, pageLogs := range pages {
+			if !errs[i].IsNil() {
+				return nil, errs[i]
+			}
+			for _, log := range pageLogs {
+				if !seen[log.ID] {
+					seen[log.ID] = true
+					logs = append(logs, log)
+				}
+			}
+			if len(pageLogs) < utils.PageSize {
+				lastPage = true
+				break
+			}
+		}
+
+		if lastPage || (max > 0 && len(logs) >= max) {
+			break
+		}
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		a, _ := time.Parse(time.RFC3339, logs[i].CreatedAt)
+		b, _ := time.Parse(time.RFC3339, logs[j].CreatedAt)
+		return a.After(b)
+	})
+	if max > 0 && len(logs) > max {
+		logs = logs[:max]
+	}
+	return logs, Error{}
+}
+
 func GetConfigTokenSlugs(config models.ScopedOptions) ([]string, Error) {
 	utils.RequireValue("token", config.Token.Value)
 
@@ -89,3 +467,199 @@ func GetEnvironmentIDs(config models.ScopedOptions) ([]string, Error) {
 	}
 	return ids, Error{}
 }
+
+// InferEnvironment returns the environment a branch config name belongs to, or an empty string if
+// the name isn't prefixed by one of the specified environments (e.g. "dev_personal_jane" -> "dev")
+func InferEnvironment(name string, environments []string) string {
+	inferred := ""
+	for _, environment := range environments {
+		// prefer the longest match in case environment names overlap
+		if strings.HasPrefix(name, environment+"_") && len(name) > len(environment)+1 && len(environment) > len(inferred) {
+			inferred = environment
+		}
+	}
+	return inferred
+}
+
+// MatchConfigNames returns the configs a partial name refers to. An exact match is always preferred;
+// otherwise every config whose name contains the partial name (ignoring case) is returned.
+func MatchConfigNames(partial string, names []string) []string {
+	for _, name := range names {
+		if name == partial {
+			return []string{name}
+		}
+	}
+
+	var matches []string
+	lowerPartial := strings.ToLower(partial)
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), lowerPartial) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// IsConfigDeployed returns whether a config has ever been deployed, i.e. had its secrets fetched
+func IsConfigDeployed(config models.ConfigInfo) bool {
+	return config.InitialFetchAt != ""
+}
+
+// FilterConfigsByDeployment returns the configs that have (or haven't) been deployed
+func FilterConfigsByDeployment(configs []models.ConfigInfo, deployed bool) []models.ConfigInfo {
+	filtered := []models.ConfigInfo{}
+	for _, config := range configs {
+		if IsConfigDeployed(config) == deployed {
+			filtered = append(filtered, config)
+		}
+	}
+	return filtered
+}
+
+// ValidateConfigNamePattern returns an error if the pattern isn't a valid glob
+func ValidateConfigNamePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q. Patterns may contain * and ? wildcards and [] character classes", pattern)
+	}
+	return nil
+}
+
+// ConfigNameMatches returns whether the config's name matches the glob pattern, using path.Match's syntax. The
+// pattern must be valid.
+func ConfigNameMatches(config models.ConfigInfo, pattern string) bool {
+	matched, _ := path.Match(pattern, config.Name)
+	return matched
+}
+
+// FilterConfigsByName returns the configs whose names match the glob pattern. The pattern must be valid.
+func FilterConfigsByName(configs []models.ConfigInfo, pattern string) []models.ConfigInfo {
+	filtered := []models.ConfigInfo{}
+	for _, config := range configs {
+		if ConfigNameMatches(config, pattern) {
+			filtered = append(filtered, config)
+		}
+	}
+	return filtered
+}
+
+// ConfigInfoFields returns the names of ConfigInfo's fields, as named in JSON output
+func ConfigInfoFields() []string {
+	var fields []string
+	infoType := reflect.TypeOf(models.ConfigInfo{})
+	for i := 0; i < infoType.NumField(); i++ {
+		name := strings.Split(infoType.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// ConfigInfoField returns the value of the config's field, as named in JSON output. Strings are returned as-is, and
+// other values as JSON. Optional fields that weren't populated are empty.
+func ConfigInfoField(info models.ConfigInfo, field string) (string, error) {
+	fields := ConfigInfoFields()
+	if !utils.Contains(fields, field) {
+		return "", fmt.Errorf("invalid field %q. Valid fields are %v", field, fields)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	values, err := utils.ParseJSONValue(string(data))
+	if err != nil {
+		return "", err
+	}
+	value, ok := values.(map[string]interface{})[field]
+	if !ok {
+		return "", nil
+	}
+	return utils.FormatJSONValue(value)
+}
+
+// DecodeConfigsStream decodes a configs list response from r, passing each config to the handler as soon as it's
+// decoded rather than waiting for the full response. Configs are passed in the order they're received.
+func DecodeConfigsStream(r io.Reader, handler func(models.ConfigInfo) error) error {
+	decoder := json.NewDecoder(r)
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		// the response's other fields (like the page) aren't needed
+		if key, _ := token.(string); key != "configs" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectJSONDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var config map[string]interface{}
+			if err := decoder.Decode(&config); err != nil {
+				return err
+			}
+			if err := handler(models.ParseConfigInfo(config)); err != nil {
+				return err
+			}
+		}
+		if err := expectJSONDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectJSONDelim(decoder, '}')
+}
+
+// expectJSONDelim reads the next token, which must be the specified delimiter
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected %v in API response, expected %v", token, delim)
+	}
+	return nil
+}
+
+// ConfigSortOptions the fields configs can be sorted by
+var ConfigSortOptions = []string{"name", "created", "deployed"}
+
+// SortConfigs sorts configs alphabetically by name, oldest-first by creation date, or by when they were last deployed.
+// When sorting by deployment, configs that have never been deployed are sorted first. Configs are left in server order if sortBy is empty.
+func SortConfigs(configs []models.ConfigInfo, sortBy string) {
+	// timestamps that fail to parse are compared as strings
+	before := func(a string, b string) bool {
+		timeA, errA := time.Parse(time.RFC3339, a)
+		timeB, errB := time.Parse(time.RFC3339, b)
+		if errA != nil || errB != nil {
+			return a < b
+		}
+		return timeA.Before(timeB)
+	}
+
+	switch sortBy {
+	case "name":
+		sort.SliceStable(configs, func(i, j int) bool {
+			return configs[i].Name < configs[j].Name
+		})
+	case "created":
+		sort.SliceStable(configs, func(i, j int) bool {
+			return before(configs[i].CreatedAt, configs[j].CreatedAt)
+		})
+	case "deployed":
+		sort.SliceStable(configs, func(i, j int) bool {
+			return before(configs[i].LastFetchAt, configs[j].LastFetchAt)
+		})
+	}
+}
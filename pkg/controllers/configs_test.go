@@ -0,0 +1,546 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferEnvironment(t *testing.T) {
+	environments := []string{"dev", "stg", "prd", "dev_eu"}
+
+	testCases := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{name: "Single underscore", config: "dev_jane", expected: "dev"},
+		{name: "Multiple underscores", config: "dev_personal_jane", expected: "dev"},
+		{name: "Longest environment wins", config: "dev_eu_jane", expected: "dev_eu"},
+		{name: "No underscore", config: "devjane", expected: ""},
+		{name: "Root config name", config: "dev", expected: ""},
+		{name: "Missing branch name", config: "dev_", expected: ""},
+		{name: "Prefix isn't an environment", config: "test_jane", expected: ""},
+		{name: "Prefix is a partial environment", config: "de_jane", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, InferEnvironment(tc.config, environments))
+		})
+	}
+}
+
+func TestMatchConfigNames(t *testing.T) {
+	names := []string{"dev", "dev_personal_jane", "dev_personal_john", "stg", "prd_critical"}
+
+	assert.Equal(t, []string{"dev"}, MatchConfigNames("dev", names))
+	assert.Equal(t, []string{"dev_personal_jane"}, MatchConfigNames("jane", names))
+	assert.Equal(t, []string{"prd_critical"}, MatchConfigNames("CRIT", names))
+	assert.Equal(t, []string{"dev_personal_jane", "dev_personal_john"}, MatchConfigNames("personal", names))
+	assert.Empty(t, MatchConfigNames("qa", names))
+}
+
+func TestFilterConfigsByDeployment(t *testing.T) {
+	configs := []models.ConfigInfo{
+		{Name: "dev", InitialFetchAt: "2024-01-01T00:00:00.000Z", LastFetchAt: "2024-03-01T00:00:00.000Z"},
+		{Name: "stg"},
+		{Name: "prd", InitialFetchAt: "2024-02-01T00:00:00.000Z", LastFetchAt: "2024-02-15T00:00:00.000Z"},
+		{Name: "dev_personal"},
+	}
+
+	var names []string
+	for _, config := range FilterConfigsByDeployment(configs, true) {
+		names = append(names, config.Name)
+	}
+	assert.Equal(t, []string{"dev", "prd"}, names)
+
+	names = nil
+	for _, config := range FilterConfigsByDeployment(configs, false) {
+		names = append(names, config.Name)
+	}
+	assert.Equal(t, []string{"stg", "dev_personal"}, names)
+
+	assert.Empty(t, FilterConfigsByDeployment([]models.ConfigInfo{{Name: "stg"}}, true))
+}
+
+func TestFilterConfigsByName(t *testing.T) {
+	configs := []models.ConfigInfo{{Name: "dev"}, {Name: "dev_pr1"}, {Name: "dev_pr12"}, {Name: "stg_pr1"}, {Name: "prd"}}
+
+	var names []string
+	for _, config := range FilterConfigsByName(configs, "dev_*") {
+		names = append(names, config.Name)
+	}
+	assert.Equal(t, []string{"dev_pr1", "dev_pr12"}, names)
+
+	names = nil
+	for _, config := range FilterConfigsByName(configs, "*_pr?") {
+		names = append(names, config.Name)
+	}
+	assert.Equal(t, []string{"dev_pr1", "stg_pr1"}, names)
+
+	assert.Empty(t, FilterConfigsByName(configs, "qa*"))
+
+	assert.NoError(t, ValidateConfigNamePattern("[a-z]*"))
+	assert.Error(t, ValidateConfigNamePattern("dev_[pr"))
+}
+
+func TestConfigInfoField(t *testing.T) {
+	count := 3
+	info := models.ConfigInfo{Name: "dev", Locked: true, CreatedAt: "2024-01-01T00:00:00.000Z", SecretCount: &count}
+
+	value, err := ConfigInfoField(info, "created_at")
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-01T00:00:00.000Z", value)
+
+	value, err = ConfigInfoField(info, "locked")
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	value, err = ConfigInfoField(info, "secret_count")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value)
+
+	// optional fields that weren't populated are empty
+	value, err = ConfigInfoField(info, "last_modified_by")
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	_, err = ConfigInfoField(info, "deployed_at")
+	assert.ErrorContains(t, err, "initial_fetch_at")
+	// fields are named as in JSON output
+	_, err = ConfigInfoField(info, "CreatedAt")
+	assert.Error(t, err)
+}
+
+func TestSortConfigs(t *testing.T) {
+	configs := []models.ConfigInfo{
+		{Name: "dev", CreatedAt: "2024-01-03T00:00:00.000Z", LastFetchAt: "2024-03-01T00:00:00.000Z"},
+		{Name: "stg", CreatedAt: "2024-01-02T00:00:00.000Z"},
+		{Name: "prd", CreatedAt: "2024-01-01T00:00:00.000Z", LastFetchAt: "2024-02-15T00:00:00.000Z"},
+	}
+
+	names := func() []string {
+		var names []string
+		for _, config := range configs {
+			names = append(names, config.Name)
+		}
+		return names
+	}
+
+	SortConfigs(configs, "deployed")
+	assert.Equal(t, []string{"stg", "prd", "dev"}, names())
+
+	SortConfigs(configs, "name")
+	assert.Equal(t, []string{"dev", "prd", "stg"}, names())
+
+	SortConfigs(configs, "created")
+	assert.Equal(t, []string{"prd", "stg", "dev"}, names())
+
+	SortConfigs(configs, "")
+	assert.Equal(t, []string{"prd", "stg", "dev"}, names())
+}
+
+func TestAddSecretCounts(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Query().Get("config") {
+		case "dev":
+			fmt.Fprint(w, `{"names":["A","B","C"]}`)
+		case "stg":
+			t.Errorf("the count for stg was reported by the API and shouldn't be fetched")
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+			fmt.Fprint(w, `{"messages":["Could not find requested config"]}`)
+		}
+	})
+
+	existingCount := 7
+	configs := []models.ConfigInfo{
+		{Name: "dev", Project: "backend"},
+		{Name: "stg", Project: "backend", SecretCount: &existingCount},
+		{Name: "prd", Project: "backend"},
+	}
+
+	errs := AddSecretCounts(options, configs)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "backend/prd")
+
+	assert.Equal(t, 3, *configs[0].SecretCount)
+	assert.Equal(t, 7, *configs[1].SecretCount)
+	assert.Nil(t, configs[2].SecretCount)
+}
+
+func TestAddLastModifiedBy(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("per_page"))
+		switch r.URL.Query().Get("config") {
+		case "dev":
+			fmt.Fprint(w, `{"logs":[{"id":"1","user":{"email":"alice@example.com","name":"Alice"}}]}`)
+		case "stg":
+			fmt.Fprint(w, `{"logs":[]}`)
+		case "tst":
+			t.Errorf("the author for tst was reported by the API and shouldn't be fetched")
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+			fmt.Fprint(w, `{"messages":["Could not find requested config"]}`)
+		}
+	})
+
+	existingAuthor := "bob@example.com"
+	configs := []models.ConfigInfo{
+		{Name: "dev", Project: "backend"},
+		{Name: "stg", Project: "backend"},
+		{Name: "tst", Project: "backend", LastModifiedBy: &existingAuthor},
+		{Name: "prd", Project: "backend"},
+	}
+
+	errs := AddLastModifiedBy(options, configs)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "backend/prd")
+
+	assert.Equal(t, "alice@example.com", *configs[0].LastModifiedBy)
+	assert.Equal(t, "", *configs[1].LastModifiedBy)
+	assert.Equal(t, "bob@example.com", *configs[2].LastModifiedBy)
+	assert.Nil(t, configs[3].LastModifiedBy)
+}
+
+func TestGetConfigsByName(t *testing.T) {
+	var requested []string
+	var mu sync.Mutex
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		config := r.URL.Query().Get("config")
+		mu.Lock()
+		requested = append(requested, config)
+		mu.Unlock()
+		if config == "missing" {
+			w.WriteHeader(nethttp.StatusNotFound)
+			fmt.Fprint(w, `{"messages":["Could not find requested config"]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"config":{"name":%q,"project":"backend"}}`, config)
+	})
+
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+	names := func(configs []models.ConfigInfo) []string {
+		var result []string
+		for _, config := range configs {
+			result = append(result, config.Name)
+		}
+		return result
+	}
+
+	configs, errs := GetConfigsByName(options, []string{"prd", "missing", "dev"}, false)
+	assert.Equal(t, []string{"prd", "dev"}, names(configs))
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "missing")
+
+	// running sequentially makes the skipped configs deterministic
+	utils.Concurrency = 1
+	requested = nil
+	configs, errs = GetConfigsByName(options, []string{"prd", "missing", "dev"}, true)
+	assert.Equal(t, []string{"prd"}, names(configs))
+	assert.Len(t, errs, 1)
+	assert.Equal(t, []string{"prd", "missing"}, requested)
+}
+
+func TestDiffConfigSecrets(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Query().Get("config") {
+		case "stg":
+			fmt.Fprint(w, `{"secrets":{"SAME":{"raw":"1","computed":"1"},"CHANGED":{"raw":"${SAME}","computed":"1"},"REMOVED":{"raw":"old","computed":"old"},"DOPPLER_CONFIG":{"raw":"stg","computed":"stg"}}}`)
+		case "prd":
+			fmt.Fprint(w, `{"secrets":{"SAME":{"raw":"1","computed":"1"},"CHANGED":{"raw":"1","computed":"1"},"ADDED":{"raw":"new","computed":"new"},"DOPPLER_CONFIG":{"raw":"prd","computed":"prd"}}}`)
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+			fmt.Fprint(w, `{"messages":["Could not find requested config"]}`)
+		}
+	})
+
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+
+	changes, err := DiffConfigSecrets(options, "stg", "prd")
+	assert.True(t, err.IsNil())
+	from, to := "${SAME}", "1"
+	added, removed := "new", "old"
+	// raw values are compared, even when the computed values match
+	assert.Equal(t, []models.ConfigSecretDiff{
+		{Name: "ADDED", Change: models.SecretAdded, To: &added},
+		{Name: "CHANGED", Change: models.SecretChanged, From: &from, To: &to},
+		{Name: "REMOVED", Change: models.SecretRemoved, From: &removed},
+	}, changes)
+
+	_, err = DiffConfigSecrets(options, "stg", "qa")
+	assert.False(t, err.IsNil())
+	assert.Contains(t, err.Message, "qa")
+}
+
+func TestResolveConfigLogID(t *testing.T) {
+	// the ambiguous logs are on different pages
+	pages := map[string]string{
+		"1": `{"logs":[` + strings.TrimSuffix(strings.Repeat(`{"id":"xxxxxxxxxxxx"},`, utils.PageSize-2), ",") + `,{"id":"abc1234567"},{"id":"def1234567"}]}`,
+		"2": `{"logs":[{"id":"abc9876543"}]}`,
+	}
+	requests := 0
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests++
+		fmt.Fprint(w, pages[r.URL.Query().Get("page")])
+	})
+
+	id, err := ResolveConfigLogID(options, "def")
+	assert.True(t, err.IsNil())
+	assert.Equal(t, "def1234567", id)
+	assert.Equal(t, 2, requests)
+
+	// full IDs are matched without fetching later pages
+	requests = 0
+	id, err = ResolveConfigLogID(options, "abc1234567")
+	assert.True(t, err.IsNil())
+	assert.Equal(t, "abc1234567", id)
+	assert.Equal(t, 1, requests)
+
+	_, err = ResolveConfigLogID(options, "abc")
+	assert.EqualError(t, err.Unwrap(), "log ID abc is ambiguous. Candidates:\nabc1234567\nabc9876543")
+
+	// unknown IDs are left for the API to reject
+	id, err = ResolveConfigLogID(options, "zzz")
+	assert.True(t, err.IsNil())
+	assert.Equal(t, "zzz", id)
+}
+
+func TestGetConfigLogsByActor(t *testing.T) {
+	pages := map[string]string{
+		"1": `{"logs":[` + strings.TrimSuffix(strings.Repeat(`{"id":"other","user":{"email":"john@example.com","name":"John"}},`, utils.PageSize-2), ",") +
+			`,{"id":"1","user":{"email":"jane@example.com","name":"Jane"}},{"id":"2","user":{"name":"ci-token"}}]}`,
+		"2": `{"logs":[{"id":"3","user":{"email":"JANE@example.com","name":"Jane"}},{"id":"4","user":{"name":"Jane Doe"}}]}`,
+	}
+	requests := 0
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests++
+		fmt.Fprint(w, pages[r.URL.Query().Get("page")])
+	})
+
+	ids := func(logs []models.ConfigLog) []string {
+		var ids []string
+		for _, log := range logs {
+			ids = append(ids, log.ID)
+		}
+		return ids
+	}
+
+	logs, err := GetConfigLogsByActor(options, "jane@example.com", 20)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, []string{"1", "3"}, ids(logs))
+	assert.Equal(t, 2, requests)
+
+	// later pages aren't fetched once enough logs are found
+	requests = 0
+	logs, err = GetConfigLogsByActor(options, "jane", 1)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, []string{"1"}, ids(logs))
+	assert.Equal(t, 1, requests)
+
+	logs, err = GetConfigLogsByActor(options, "ci-token", 20)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, []string{"2"}, ids(logs))
+
+	logs, err = GetConfigLogsByActor(options, "nobody", 20)
+	assert.True(t, err.IsNil())
+	assert.Empty(t, logs)
+}
+
+func TestDecodeConfigsStream(t *testing.T) {
+	r, w := io.Pipe()
+	received := make(chan string)
+	done := make(chan error)
+	go func() {
+		done <- DecodeConfigsStream(r, func(info models.ConfigInfo) error {
+			received <- info.Name
+			return nil
+		})
+	}()
+
+	// each config is passed to the handler before the rest of the response arrives
+	fmt.Fprint(w, `{"page":1,"configs":[{"name":"dev","environment":"dev"},`)
+	assert.Equal(t, "dev", <-received)
+	fmt.Fprint(w, `{"name":"prd","environment":"prd"}],"success":true}`)
+	assert.Equal(t, "prd", <-received)
+	assert.NoError(t, w.Close())
+	assert.NoError(t, <-done)
+
+	err := DecodeConfigsStream(strings.NewReader(`{"configs":{}}`), func(models.ConfigInfo) error { return nil })
+	assert.Error(t, err)
+	err = DecodeConfigsStream(strings.NewReader(`{"configs":[{"name":"dev"}`), func(models.ConfigInfo) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestGetAllConfigLogs(t *testing.T) {
+	// 25 logs, one per minute, newest first. a log created while paging shifts the later pages by one
+	newest := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	var mutex sync.Mutex
+	var requestedPages []int
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		mutex.Lock()
+		requestedPages = append(requestedPages, page)
+		mutex.Unlock()
+
+		start := (page - 1) * utils.PageSize
+		if page > 1 {
+			start--
+		}
+		var logs []string
+		for i := start; i < utils.Min(start+utils.PageSize, 25); i++ {
+			logs = append(logs, fmt.Sprintf(`{"id":"log%d","created_at":%q}`, i, newest.Add(-time.Duration(i)*time.Minute).Format(time.RFC3339)))
+		}
+		fmt.Fprintf(w, `{"logs":[%s]}`, strings.Join(logs, ","))
+	})
+	utils.PageSize = 10
+	utils.Concurrency = 2
+
+	logs, err := GetAllConfigLogs(options, 0)
+	assert.True(t, err.IsNil())
+	assert.Len(t, logs, 25)
+	for i, log := range logs {
+		assert.Equal(t, fmt.Sprintf("log%d", i), log.ID)
+	}
+	sort.Ints(requestedPages)
+	assert.Equal(t, []int{1, 2, 3, 4}, requestedPages)
+
+	// later pages aren't fetched once max logs are found
+	requestedPages = nil
+	logs, err = GetAllConfigLogs(options, 12)
+	assert.True(t, err.IsNil())
+	assert.Len(t, logs, 12)
+	assert.Equal(t, "log11", logs[11].ID)
+	sort.Ints(requestedPages)
+	assert.Equal(t, []int{1, 2}, requestedPages)
+}
+
+func TestDeleteConfig(t *testing.T) {
+	var requests []string
+	failDelete, failLock := false, false
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if (r.URL.Path == "/v3/configs/config" && failDelete) || (r.URL.Path == "/v3/configs/config/lock" && failLock) {
+			w.WriteHeader(nethttp.StatusBadRequest)
+			fmt.Fprint(w, `{"messages":["Request failed"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"config":{"name":"dev","project":"backend"},"success":true}`)
+	})
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+
+	err := DeleteConfig(options, "dev", false)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, []string{"DELETE /v3/configs/config"}, requests)
+
+	requests = nil
+	err = DeleteConfig(options, "dev", true)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, []string{"POST /v3/configs/config/unlock", "DELETE /v3/configs/config"}, requests)
+
+	// a config that can't be deleted is locked again
+	requests = nil
+	failDelete = true
+	err = DeleteConfig(options, "dev", true)
+	assert.Equal(t, "Unable to delete config", err.Message)
+	assert.Equal(t, []string{"POST /v3/configs/config/unlock", "DELETE /v3/configs/config", "POST /v3/configs/config/lock"}, requests)
+
+	// both errors are reported if it can't be locked again
+	failLock = true
+	err = DeleteConfig(options, "dev", true)
+	assert.Equal(t, "Unable to delete config. The config was unlocked and couldn't be locked again", err.Message)
+	assert.Contains(t, err.Unwrap().Error(), "unable to lock config dev again")
+
+	// an unlocked config isn't locked
+	requests = nil
+	err = DeleteConfig(options, "dev", false)
+	assert.Equal(t, "Unable to delete config", err.Message)
+	assert.Equal(t, []string{"DELETE /v3/configs/config"}, requests)
+}
+
+func TestConfigExists(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Query().Get("config") {
+		case "dev":
+			fmt.Fprint(w, `{"config":{"name":"dev","project":"backend"}}`)
+		case "missing":
+			w.WriteHeader(nethttp.StatusNotFound)
+			fmt.Fprint(w, `{"messages":["Could not find requested config"]}`)
+		default:
+			w.WriteHeader(nethttp.StatusForbidden)
+			fmt.Fprint(w, `{"messages":["You do not have access to this config"]}`)
+		}
+	})
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+
+	exists, err := ConfigExists(options, "dev")
+	assert.True(t, err.IsNil())
+	assert.True(t, exists)
+
+	exists, err = ConfigExists(options, "missing")
+	assert.True(t, err.IsNil())
+	assert.False(t, exists)
+
+	// only a 404 means the name is free
+	_, err = ConfigExists(options, "restricted")
+	assert.False(t, err.IsNil())
+	assert.Equal(t, "Unable to check whether config restricted exists", err.Message)
+}
+
+func TestGetAllConfigs(t *testing.T) {
+	var requestedPages []int
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		requestedPages = append(requestedPages, page)
+		assert.Equal(t, "backend", r.URL.Query().Get("project"))
+		assert.Equal(t, "prd", r.URL.Query().Get("environment"))
+
+		var configs []string
+		for i := (page - 1) * utils.PageSize; i < utils.Min(page*utils.PageSize, 5); i++ {
+			configs = append(configs, fmt.Sprintf(`{"name":"prd_%d"}`, i))
+		}
+		fmt.Fprintf(w, `{"configs":[%s]}`, strings.Join(configs, ","))
+	})
+	utils.PageSize = 2
+
+	configs, err := GetAllConfigs(options, "backend", "prd")
+	assert.True(t, err.IsNil())
+	assert.Len(t, configs, 5)
+	assert.Equal(t, "prd_4", configs[4].Name)
+	assert.Equal(t, []int{1, 2, 3}, requestedPages)
+
+	// a full last page requires fetching an empty page
+	utils.PageSize = 5
+	requestedPages = nil
+	configs, err = GetAllConfigs(options, "backend", "prd")
+	assert.True(t, err.IsNil())
+	assert.Len(t, configs, 5)
+	assert.Equal(t, []int{1, 2}, requestedPages)
+}
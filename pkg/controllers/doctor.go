@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// maxClockSkew the difference between the local and API clocks above which a warning is reported. The API's Date
+// header only has a precision of one second
+const maxClockSkew = time.Minute
+
+// RunDoctorChecks diagnoses common problems with the CLI's environment, like an unreadable config file, an invalid
+// token, or an unreachable API
+func RunDoctorChecks(config models.ScopedOptions, configFile string) []models.DoctorCheck {
+	checks := []models.DoctorCheck{
+		checkConfigFile(configFile),
+		checkProxy(config.APIHost.Value),
+		checkTLSVerification(config),
+	}
+	return append(checks, checkAPI(config)...)
+}
+
+// checkConfigFile checks that the config file can be read and written
+func checkConfigFile(path string) models.DoctorCheck {
+	check := models.DoctorCheck{Name: "config file"}
+
+	// #nosec G304
+	file, err := os.Open(path)
+	if err != nil {
+		check.Status = models.DoctorCheckFail
+		check.Message = fmt.Sprintf("Unable to read %s: %s", path, err)
+		return check
+	}
+	file.Close()
+
+	// opening the file for writing without truncating it leaves its contents untouched
+	// #nosec G304
+	file, err = os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		check.Status = models.DoctorCheckFail
+		check.Message = fmt.Sprintf("Unable to write %s: %s", path, err)
+		return check
+	}
+	file.Close()
+
+	check.Status = models.DoctorCheckPass
+	check.Message = fmt.Sprintf("%s is readable and writable", path)
+	return check
+}
+
+// checkProxy reports the proxy that requests to the API are sent through, if any
+func checkProxy(host string) models.DoctorCheck {
+	check := models.DoctorCheck{Name: "proxy"}
+
+	apiURL, err := url.Parse(host)
+	if err != nil {
+		check.Status = models.DoctorCheckFail
+		check.Message = fmt.Sprintf("Invalid API host %s: %s", host, err)
+		return check
+	}
+
+	// requests are proxied according to the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables
+	proxy, err := nethttp.ProxyFromEnvironment(&nethttp.Request{URL: apiURL})
+	switch {
+	case err != nil:
+		check.Status = models.DoctorCheckFail
+		check.Message = fmt.Sprintf("Invalid proxy: %s", err)
+	case proxy == nil:
+		check.Status = models.DoctorCheckPass
+		check.Message = fmt.Sprintf("Requests to %s aren't proxied", apiURL.Host)
+	default:
+		check.Status = models.DoctorCheckPass
+		check.Message = fmt.Sprintf("Requests to %s are sent through %s", apiURL.Host, proxy.Redacted())
+	}
+	return check
+}
+
+// checkTLSVerification warns when requests are sent without verifying the API's certificate
+func checkTLSVerification(config models.ScopedOptions) models.DoctorCheck {
+	check := models.DoctorCheck{Name: "tls"}
+	switch {
+	case strings.HasPrefix(strings.ToLower(config.APIHost.Value), "http://"):
+		check.Status = models.DoctorCheckWarn
+		check.Message = "Requests are sent over plaintext HTTP, which exposes your token to anyone able to observe the traffic"
+	case !utils.GetBool(config.VerifyTLS.Value, true):
+		check.Status = models.DoctorCheckWarn
+		check.Message = "TLS certificate verification is disabled"
+	default:
+		check.Status = models.DoctorCheckPass
+		check.Message = "TLS certificates are verified"
+	}
+	return check
+}
+
+// checkAPI checks that the API is reachable, that the token is valid, and that the local clock matches the API's
+func checkAPI(config models.ScopedOptions) []models.DoctorCheck {
+	api := models.DoctorCheck{Name: "api"}
+	token := models.DoctorCheck{Name: "token"}
+	clock := models.DoctorCheck{Name: "clock"}
+
+	start := time.Now()
+	info, serverTime, err := http.GetActorInfoAndServerTime(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value)
+	elapsed := time.Since(start)
+
+	// a status code means the API responded, even if the request was rejected
+	if !err.IsNil() && err.Code == 0 {
+		api.Status = models.DoctorCheckFail
+		api.Message = fmt.Sprintf("Unable to reach %s: %s", config.APIHost.Value, err.Unwrap())
+		token.Status = models.DoctorCheckSkip
+		token.Message = "The API is unreachable"
+		clock.Status = models.DoctorCheckSkip
+		clock.Message = "The API is unreachable"
+		return []models.DoctorCheck{api, token, clock}
+	}
+	api.Status = models.DoctorCheckPass
+	api.Message = fmt.Sprintf("%s responded in %s", config.APIHost.Value, elapsed.Round(time.Millisecond))
+
+	switch {
+	case config.Token.Value == "":
+		token.Status = models.DoctorCheckFail
+		token.Message = "No token is set. Run 'doppler login' or set DOPPLER_TOKEN"
+	case !err.IsNil():
+		token.Status = models.DoctorCheckFail
+		token.Message = fmt.Sprintf("%s: %s", err.Message, err.Unwrap())
+	default:
+		token.Status = models.DoctorCheckPass
+		token.Message = fmt.Sprintf("Authenticated as %s (%s) in workplace %s", info.Name, info.Type, info.Workplace.Name)
+	}
+
+	// the API's time is compared to the midpoint of the request
+	clock = checkClockSkew(serverTime, start.Add(elapsed/2))
+	return []models.DoctorCheck{api, token, clock}
+}
+
+// checkClockSkew compares the local time to the API's. A zero server time means the API didn't report its time
+func checkClockSkew(serverTime time.Time, localTime time.Time) models.DoctorCheck {
+	check := models.DoctorCheck{Name: "clock"}
+	if serverTime.IsZero() {
+		check.Status = models.DoctorCheckSkip
+		check.Message = "The API response doesn't include its time"
+		return check
+	}
+
+	skew := localTime.Sub(serverTime)
+	direction := "ahead of"
+	if skew < 0 {
+		skew = -skew
+		direction = "behind"
+	}
+	if skew > maxClockSkew {
+		check.Status = models.DoctorCheckWarn
+		check.Message = fmt.Sprintf("The local clock is %s %s the API's. Time-based features like token expiration may behave unexpectedly", skew.Round(time.Second), direction)
+		return check
+	}
+	check.Status = models.DoctorCheckPass
+	check.Message = fmt.Sprintf("The local clock is within %d seconds of the API's", int(maxClockSkew.Seconds()))
+	return check
+}
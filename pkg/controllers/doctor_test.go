@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func doctorStatuses(checks []models.DoctorCheck) map[string]string {
+	statuses := map[string]string{}
+	for _, check := range checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestRunDoctorChecks(t *testing.T) {
+	serverTime := time.Now()
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Date", serverTime.UTC().Format(nethttp.TimeFormat))
+		if r.Header.Get("Authorization") != "Bearer dp.st.valid" {
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			fmt.Fprint(w, `{"messages":["Invalid Auth token"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"ci","type":"service_token","workplace":{"name":"Acme"}}`)
+	})
+
+	configFile := filepath.Join(t.TempDir(), ".doppler.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("scoped: {}\n"), 0600))
+
+	options.Token.Value = "dp.st.valid"
+	checks := RunDoctorChecks(options, configFile)
+	assert.Equal(t, map[string]string{
+		"config file": models.DoctorCheckPass,
+		"proxy":       models.DoctorCheckPass,
+		// the test server doesn't use TLS
+		"tls":   models.DoctorCheckWarn,
+		"api":   models.DoctorCheckPass,
+		"token": models.DoctorCheckPass,
+		"clock": models.DoctorCheckPass,
+	}, doctorStatuses(checks))
+	assert.Equal(t, "Authenticated as ci (service_token) in workplace Acme", checks[4].Message)
+
+	// a rejected token still means the API is reachable
+	options.Token.Value = "dp.st.revoked"
+	serverTime = time.Now().Add(-10 * time.Minute)
+	statuses := doctorStatuses(RunDoctorChecks(options, filepath.Join(t.TempDir(), "missing.yaml")))
+	assert.Equal(t, models.DoctorCheckFail, statuses["config file"])
+	assert.Equal(t, models.DoctorCheckPass, statuses["api"])
+	assert.Equal(t, models.DoctorCheckFail, statuses["token"])
+	assert.Equal(t, models.DoctorCheckWarn, statuses["clock"])
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, models.DoctorCheckPass, checkClockSkew(now, now.Add(30*time.Second)).Status)
+	assert.Equal(t, models.DoctorCheckSkip, checkClockSkew(time.Time{}, now).Status)
+
+	check := checkClockSkew(now, now.Add(5*time.Minute))
+	assert.Equal(t, models.DoctorCheckWarn, check.Status)
+	assert.Contains(t, check.Message, "5m0s ahead of")
+	assert.Contains(t, checkClockSkew(now, now.Add(-2*time.Minute)).Message, "2m0s behind")
+}
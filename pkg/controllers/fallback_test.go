@@ -16,8 +16,14 @@ limitations under the License.
 package controllers
 
 import (
+	nethttp "net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/DopplerHQ/cli/pkg/crypto"
+	"github.com/DopplerHQ/cli/pkg/http"
 	"github.com/DopplerHQ/cli/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +73,38 @@ func TestGenerateFallbackFileHash(t *testing.T) {
 	}
 
 }
+
+func TestFetchSecretsFallbackMaxAge(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusServiceUnavailable)
+	})
+	http.RequestAttempts = 1
+
+	const passphrase = "passphrase"
+	path := filepath.Join(t.TempDir(), "fallback.json")
+	encrypted, err := crypto.Encrypt(passphrase, []byte(`{"A":"1"}`), "base64")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte(encrypted), 0600))
+	lastUpdated := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(path, lastUpdated, lastUpdated))
+
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+	options.EnclaveConfig = models.ScopedOption{Value: "dev"}
+	fallbackOpts := FallbackOptions{Enable: true, Path: path, Readonly: true, Passphrase: passphrase, MaxAge: 2 * time.Hour}
+
+	t.Run("fresh", func(t *testing.T) {
+		secrets := FetchSecrets(options, false, fallbackOpts, "", nil, 0, models.JSON, nil)
+		assert.Equal(t, map[string]string{"A": "1"}, secrets)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		fallbackOpts := fallbackOpts
+		fallbackOpts.MaxAge = 30 * time.Minute
+		code, stderr := runExiting(t, func() {
+			FetchSecrets(options, false, fallbackOpts, "", nil, 0, models.JSON, nil)
+		})
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "Refusing to use stale secrets from the fallback file")
+		assert.Contains(t, stderr, "exceeding the max age of 30m0s")
+	})
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/http"
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAPI starts a test API server with the handler and returns options for sending requests to it. Tests may modify
+// the request settings (e.g. utils.PageSize), which are restored along with closing the server when the test finishes.
+func newTestAPI(t *testing.T, handler nethttp.HandlerFunc) models.ScopedOptions {
+	allowPlaintextHTTP, requestAttempts, pageSize, concurrency := http.AllowPlaintextHTTP, http.RequestAttempts, utils.PageSize, utils.Concurrency
+	t.Cleanup(func() {
+		http.AllowPlaintextHTTP, http.RequestAttempts, utils.PageSize, utils.Concurrency = allowPlaintextHTTP, requestAttempts, pageSize, concurrency
+	})
+	http.AllowPlaintextHTTP = true
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return models.ScopedOptions{
+		APIHost: models.ScopedOption{Value: server.URL},
+		Token:   models.ScopedOption{Value: "dp.st.test"},
+	}
+}
+
+// runExiting runs fn in a child process running the current test, so that fn may exit (e.g. via utils.HandleError). It
+// returns the exit code and stderr. Each test or subtest may only call it once.
+func runExiting(t *testing.T, fn func()) (int, string) {
+	if os.Getenv("DOPPLER_TEST_EXITING") != "" {
+		fn()
+		os.Exit(0)
+	}
+
+	var patterns []string
+	for _, name := range strings.Split(t.Name(), "/") {
+		patterns = append(patterns, "^"+regexp.QuoteMeta(name)+"$")
+	}
+	// #nosec G204
+	c := exec.Command(os.Args[0], "-test.run="+strings.Join(patterns, "/"))
+	c.Env = append(os.Environ(), "DOPPLER_TEST_EXITING=1")
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	err := c.Run()
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return exitError.ExitCode(), stderr.String()
+	}
+	assert.NoError(t, err)
+	return 0, stderr.String()
+}
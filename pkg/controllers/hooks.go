@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
+
+// HookTimeout how long a hook may run before it's killed
+const HookTimeout = 30 * time.Second
+
+// HookEvent details of a successful operation, passed to hooks via DOPPLER_ environment variables
+type HookEvent struct {
+	// Name the operation that succeeded (e.g. config.create)
+	Name    string
+	Project string
+	Config  string
+	// Extra additional variables specific to the operation
+	Extra map[string]string
+}
+
+// Env returns the event's environment variables
+func (e HookEvent) Env() []string {
+	vars := map[string]string{
+		"DOPPLER_EVENT":   e.Name,
+		"DOPPLER_PROJECT": e.Project,
+		"DOPPLER_CONFIG":  e.Config,
+	}
+	for name, value := range e.Extra {
+		vars[name] = value
+	}
+
+	var env []string
+	for name, value := range vars {
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// RunHook runs the hook command with the event's details in its environment. The hook's output is written to stderr.
+// Hook failures are only logged as warnings so they never mask the success of the operation itself.
+func RunHook(command string, event HookEvent) {
+	if command == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	shell := [2]string{"sh", "-c"}
+	if utils.IsWindows() {
+		shell = [2]string{"cmd", "/C"}
+	}
+
+	utils.LogDebug(fmt.Sprintf("Running hook for %s event", event.Name))
+	cmd := exec.CommandContext(ctx, shell[0], shell[1], command) // #nosec G204 nosemgrep: semgrep_configs.prohibit-exec-command
+	cmd.Env = append(os.Environ(), event.Env()...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		utils.LogWarning(fmt.Sprintf("Hook timed out after %s", HookTimeout))
+	} else if err != nil {
+		utils.LogWarning(fmt.Sprintf("Hook failed: %s", err))
+	}
+}
@@ -16,14 +16,18 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
@@ -35,6 +39,7 @@ import (
 	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/spf13/cobra"
 	"gopkg.in/gookit/color.v1"
+	"gopkg.in/yaml.v3"
 )
 
 // Documentation about potentially dangerous secret names can be found here: https://docs.doppler.com/docs/accessing-secrets#injection
@@ -64,6 +69,18 @@ var dangerousSecretNames = [...]string{
 	"NODE_OPTIONS",
 }
 
+// placeholderRegex matches ${SECRET_NAME} placeholders
+var placeholderRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// policies for placeholders that don't match a secret
+const (
+	MissingPlaceholderKeep  = "keep"
+	MissingPlaceholderError = "error"
+	MissingPlaceholderEmpty = "empty"
+)
+
+var MissingPlaceholderPolicies = []string{MissingPlaceholderKeep, MissingPlaceholderError, MissingPlaceholderEmpty}
+
 type FallbackOptions struct {
 	Enable             bool
 	Path               string
@@ -72,6 +89,10 @@ type FallbackOptions struct {
 	Exclusive          bool
 	ExitOnWriteFailure bool
 	Passphrase         string
+	// prompt for the passphrase when Passphrase can't decrypt the fallback file
+	PromptForPassphrase bool
+	// the max age of the fallback file's secrets when reading from it. 0 means no limit
+	MaxAge time.Duration
 }
 
 type MountOptions struct {
@@ -97,6 +118,90 @@ func GetSecrets(config models.ScopedOptions) (map[string]models.ComputedSecret,
 	return secrets, Error{}
 }
 
+// GetRawSecretValues fetches the raw (i.e. unexpanded) values of the config's secrets, excluding Doppler's metadata
+// secrets. The names of secrets without a raw value (e.g. restricted secrets) are returned separately.
+func GetRawSecretValues(config models.ScopedOptions) (map[string]string, []string, Error) {
+	secrets, err := GetSecrets(config)
+	if !err.IsNil() {
+		return nil, nil, err
+	}
+
+	values := map[string]string{}
+	var unreadable []string
+	for name, secret := range secrets {
+		if utils.Contains(metadataSecretNames, name) {
+			continue
+		}
+		if secret.RawValue == nil {
+			unreadable = append(unreadable, name)
+			continue
+		}
+		values[name] = *secret.RawValue
+	}
+	sort.Strings(unreadable)
+	return values, unreadable, Error{}
+}
+
+// AddSecretSources populates each secret's Source by comparing its raw value with the environment's default, i.e. the
+// value in the environment's root config. All of a root config's secrets are defaults.
+func AddSecretSources(config models.ScopedOptions, secrets map[string]models.ComputedSecret) Error {
+	utils.RequireValue("token", config.Token.Value)
+
+	info, err := http.GetConfig(config.APIHost.Value, utils.GetBool(config.VerifyTLS.Value, true), config.Token.Value, config.EnclaveProject.Value, config.EnclaveConfig.Value)
+	if !err.IsNil() {
+		return Error{Err: err.Unwrap(), Message: err.Message}
+	}
+
+	defaults := secrets
+	if !info.Root {
+		configs, configsErr := GetAllConfigs(config, info.Project, info.Environment)
+		if !configsErr.IsNil() {
+			return configsErr
+		}
+		rootConfig := ""
+		for _, c := range configs {
+			if c.Root {
+				rootConfig = c.Name
+				break
+			}
+		}
+		if rootConfig == "" {
+			return Error{Err: fmt.Errorf("no root config found for environment %s", info.Environment), Message: "Unable to determine the environment's default secrets"}
+		}
+
+		scoped := config
+		scoped.EnclaveProject.Value = info.Project
+		scoped.EnclaveConfig.Value = rootConfig
+		var secretsErr Error
+		defaults, secretsErr = GetSecrets(scoped)
+		if !secretsErr.IsNil() {
+			return Error{Err: secretsErr.Unwrap(), Message: fmt.Sprintf("Unable to fetch secrets for root config %s", rootConfig)}
+		}
+	}
+
+	for name, secret := range secrets {
+		defaultSecret, ok := defaults[name]
+		switch {
+		case !ok:
+			secret.Source = models.SecretSourceCustom
+		case equalValues(secret.RawValue, defaultSecret.RawValue):
+			secret.Source = models.SecretSourceDefault
+		default:
+			secret.Source = models.SecretSourceOverridden
+		}
+		secrets[name] = secret
+	}
+	return Error{}
+}
+
+// equalValues returns whether both values are restricted, or both are visible and equal
+func equalValues(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
 func SetSecrets(config models.ScopedOptions, changeRequests []models.ChangeRequest) (map[string]models.ComputedSecret, Error) {
 	utils.RequireValue("token", config.Token.Value)
 
@@ -297,6 +402,345 @@ func RenderSecretsTemplate(templateBody string, secretsMap map[string]string) st
 	return buffer.String()
 }
 
+// RenderSecretsPlaceholders replaces ${SECRET_NAME} placeholders with the secret's value.
+// Placeholders that don't match a secret are handled according to the missing policy.
+func RenderSecretsPlaceholders(body string, secretsMap map[string]string, missing string) (string, error) {
+	return replacePlaceholders(body, secretsMap, missing, func(name string) string {
+		return secretsMap[name]
+	})
+}
+
+// TemplatePlaceholderActions rewrites the ${SECRET_NAME} placeholders in a Go template as actions that output the
+// secret's value, so they're rendered by RenderSecretsTemplate along with the rest of the template. Values are never
+// themselves treated as templates or placeholders. Placeholders that don't match a secret are handled according to
+// the missing policy.
+func TemplatePlaceholderActions(templateBody string, secretsMap map[string]string, missing string) (string, error) {
+	return replacePlaceholders(templateBody, secretsMap, missing, func(name string) string {
+		return fmt.Sprintf("{{index . %q}}", name)
+	})
+}
+
+// replacePlaceholders replaces each ${SECRET_NAME} placeholder matching a secret with replacement(name), and handles
+// the others according to the missing policy
+func replacePlaceholders(body string, secretsMap map[string]string, missing string, replacement func(name string) string) (string, error) {
+	if !utils.Contains(MissingPlaceholderPolicies, missing) {
+		return "", fmt.Errorf("invalid missing placeholder policy. Valid policies are %s", strings.Join(MissingPlaceholderPolicies, ", "))
+	}
+
+	var missingNames []string
+	output := placeholderRegex.ReplaceAllStringFunc(body, func(placeholder string) string {
+		name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		if _, ok := secretsMap[name]; ok {
+			return replacement(name)
+		}
+
+		if missing == MissingPlaceholderEmpty {
+			return ""
+		}
+		if missing == MissingPlaceholderError && !utils.Contains(missingNames, name) {
+			missingNames = append(missingNames, name)
+		}
+		return placeholder
+	})
+
+	if len(missingNames) > 0 {
+		return "", fmt.Errorf("the template references secrets which do not exist in your config:\n- %s", strings.Join(missingNames, "\n- "))
+	}
+
+	return output, nil
+}
+
+// OmitEmptySecrets returns a copy of the secrets without those whose value is empty
+func OmitEmptySecrets(secrets map[string]string) map[string]string {
+	nonEmpty := map[string]string{}
+	for name, value := range secrets {
+		if value != "" {
+			nonEmpty[name] = value
+		}
+	}
+	return nonEmpty
+}
+
+// FormatSecrets renders the secrets in a format that the CLI renders itself (see SecretsFormat.ClientRendered),
+// with one line (or JSON field) per secret sorted by name
+func FormatSecrets(secrets map[string]string, format models.SecretsFormat) ([]byte, error) {
+	var escapeFormat string
+	var lines []string
+	switch format {
+	case models.ENV:
+		escapeFormat = utils.DotEnvEscapeFormat
+	case models.DOCKER:
+		escapeFormat = utils.DockerEscapeFormat
+	case models.TFVARS:
+		escapeFormat = utils.TFVarsEscapeFormat
+		secrets = TerraformVariables(secrets)
+	case models.TFVARS_JSON:
+		return formatJSONVariables(TerraformVariables(secrets))
+	case models.SYSTEMD:
+		escapeFormat = utils.SystemdEscapeFormat
+		// the output is intended to be used as a unit drop-in
+		lines = append(lines, "[Service]")
+	case models.NGINX:
+		escapeFormat = utils.NginxEscapeFormat
+	case models.SHELL:
+		escapeFormat = utils.ShellExportEscapeFormat
+	default:
+		return nil, fmt.Errorf("the %s format is rendered by the API", format)
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		line, err := utils.FormatEnvLine(escapeFormat, name, secrets[name])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// terraformVariableNameRegex matches valid Terraform variable names
+var terraformVariableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// terraformReservedVariableNames names that Terraform reserves for its own use
+var terraformReservedVariableNames = []string{"source", "version", "providers", "count", "for_each", "lifecycle", "depends_on", "locals"}
+
+// TerraformVariables returns a copy of the secrets without those whose names aren't valid Terraform variable names,
+// logging a warning listing the omitted secrets
+func TerraformVariables(secrets map[string]string) map[string]string {
+	variables := map[string]string{}
+	var invalid []string
+	for name, value := range secrets {
+		if !terraformVariableNameRegex.MatchString(name) || utils.Contains(terraformReservedVariableNames, name) {
+			invalid = append(invalid, name)
+			continue
+		}
+		variables[name] = value
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		utils.LogWarning(fmt.Sprintf("Omitting secrets whose names aren't valid Terraform variable names: %s", strings.Join(invalid, ", ")))
+	}
+	return variables
+}
+
+// formatJSONVariables renders the secrets as a JSON object, as used by Terraform's .tfvars.json files. Terraform reads
+// the values in a .tfvars.json file literally, so unlike the native syntax, template sequences aren't escaped.
+func formatJSONVariables(secrets map[string]string) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	// the file isn't embedded in HTML, so values are written as-is
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(secrets); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// envEntryRegex matches the first line of an entry in the env-no-quotes format
+var envEntryRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// SortSecretsOutput sorts the secrets in a file rendered by the API alphabetically by name, so that regenerating the
+// file produces a stable result. Formats rendered by the CLI are always sorted and are returned unchanged.
+func SortSecretsOutput(body []byte, format models.SecretsFormat) ([]byte, error) {
+	switch format {
+	case models.ENV_NO_QUOTES:
+		return sortEnvEntries(body), nil
+	case models.YAML:
+		return sortYAMLKeys(body)
+	case models.DOTNET_JSON:
+		return sortJSONKeys(body)
+	default:
+		return body, nil
+	}
+}
+
+// sortEnvEntries sorts NAME=value entries by name. Unquoted values may span multiple lines, so lines that don't
+// begin an entry are kept with the preceding entry. Like the tools that read these files, a line that looks like an
+// entry is treated as one.
+func sortEnvEntries(body []byte) []byte {
+	text := string(body)
+	trailingNewline := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	var header []string
+	var entries [][]string
+	for _, line := range lines {
+		if envEntryRegex.MatchString(line) {
+			entries = append(entries, []string{line})
+		} else if len(entries) == 0 {
+			header = append(header, line)
+		} else {
+			entries[len(entries)-1] = append(entries[len(entries)-1], line)
+		}
+	}
+
+	name := func(entry []string) string {
+		return strings.SplitN(entry[0], "=", 2)[0]
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return name(entries[i]) < name(entries[j])
+	})
+
+	sorted := header
+	for _, entry := range entries {
+		sorted = append(sorted, entry...)
+	}
+	result := strings.Join(sorted, "\n")
+	if trailingNewline {
+		result += "\n"
+	}
+	return []byte(result)
+}
+
+// sortYAMLKeys sorts the keys of the document's top-level mapping, preserving the style of each value
+func sortYAMLKeys(body []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return body, nil
+	}
+
+	mapping := doc.Content[0]
+	pairs := make([][2]*yaml.Node, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{mapping.Content[i], mapping.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	mapping.Content = mapping.Content[:0]
+	for _, pair := range pairs {
+		mapping.Content = append(mapping.Content, pair[0], pair[1])
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sortJSONKeys sorts the keys of a JSON object, preserving whether the object was indented and had a trailing newline
+func sortJSONKeys(body []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, err
+	}
+
+	// maps are encoded with sorted keys
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if bytes.Contains(bytes.TrimSpace(body), []byte("\n")) {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(object); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+	}
+	return out.Bytes(), nil
+}
+
+// KubernetesSecretOptions the metadata of a rendered Kubernetes Secret
+type KubernetesSecretOptions struct {
+	Name      string
+	Namespace string
+	Type      string
+}
+
+// DefaultKubernetesSecretType the type of Secret used for arbitrary user data
+const DefaultKubernetesSecretType = "Opaque"
+
+var kubernetesNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+var invalidKubernetesKeyCharsRegex = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+type kubernetesSecret struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace,omitempty"`
+	} `yaml:"metadata"`
+	Type string            `yaml:"type"`
+	Data map[string]string `yaml:"data"`
+}
+
+// KubernetesSecretKey returns the secret name as a valid Secret data key, replacing invalid characters with underscores
+func KubernetesSecretKey(name string) string {
+	return invalidKubernetesKeyCharsRegex.ReplaceAllString(name, "_")
+}
+
+// FormatKubernetesSecret renders the secrets as a Kubernetes Secret manifest, with base64 encoded values.
+// Secret names that aren't valid data keys are sanitized with a warning.
+func FormatKubernetesSecret(secrets map[string]string, opts KubernetesSecretOptions) ([]byte, error) {
+	if opts.Name == "" {
+		return nil, errors.New("the Secret name must be specified")
+	}
+	// the Secret's name and namespace are validated up front since the API server would reject the manifest
+	if len(opts.Name) > 253 || !kubernetesNameRegex.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid Secret name %q. Names must consist of lowercase alphanumeric characters, '-', or '.', and must start and end with an alphanumeric character", opts.Name)
+	}
+	if opts.Namespace != "" && (len(opts.Namespace) > 63 || strings.Contains(opts.Namespace, ".") || !kubernetesNameRegex.MatchString(opts.Namespace)) {
+		return nil, fmt.Errorf("invalid namespace %q. Namespaces must consist of lowercase alphanumeric characters or '-', and must start and end with an alphanumeric character", opts.Namespace)
+	}
+	if opts.Type == "" {
+		opts.Type = DefaultKubernetesSecretType
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := kubernetesSecret{APIVersion: "v1", Kind: "Secret", Type: opts.Type, Data: map[string]string{}}
+	manifest.Metadata.Name = opts.Name
+	manifest.Metadata.Namespace = opts.Namespace
+	sources := map[string]string{}
+	for _, name := range names {
+		key := KubernetesSecretKey(name)
+		if key == "." || key == ".." || len(key) > 253 {
+			return nil, fmt.Errorf("secret %s can't be used as a Kubernetes Secret data key", name)
+		}
+		if source, ok := sources[key]; ok {
+			return nil, fmt.Errorf("secrets %s and %s both map to the Kubernetes Secret data key %s", source, name, key)
+		}
+		if key != name {
+			utils.LogWarning(fmt.Sprintf("Secret %s isn't a valid Kubernetes Secret data key, using %s instead", name, key))
+		}
+		sources[key] = name
+		manifest.Data[key] = base64.StdEncoding.EncodeToString([]byte(secrets[name]))
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
 func MissingSecrets(secrets map[string]string, secretsToInclude []string) []string {
 	var missingSecrets []string
 	for _, name := range secretsToInclude {
@@ -372,8 +816,7 @@ func PrepareSecrets(dopplerSecrets map[string]string, originalEnv []string, pres
 		env = append(env, fmt.Sprintf("%s=%s", "DOPPLER_CLI_SECRETS_PATH", mountPath))
 	} else {
 		// remove any reserved keys from secrets
-		reservedKeys := []string{"PATH", "PS1", "HOME"}
-		for _, reservedKey := range reservedKeys {
+		for _, reservedKey := range runReservedSecretNames {
 			if _, found := dopplerSecrets[reservedKey]; found {
 				utils.LogDebug(fmt.Sprintf("Ignoring reserved secret %s", reservedKey))
 				delete(dopplerSecrets, reservedKey)
@@ -432,7 +875,7 @@ func FetchSecrets(localConfig models.ScopedOptions, enableCache bool, fallbackOp
 		if !fallbackOpts.Enable {
 			utils.HandleError(errors.New("Conflict: unable to specify --no-fallback with --fallback-only"))
 		}
-		return readFallbackFile(fallbackOpts.Path, fallbackOpts.LegacyPath, fallbackOpts.Passphrase, false)
+		return readFallbackFile(fallbackOpts, false)
 	}
 
 	// this scenario likely isn't possible, but just to be safe, disable using cache when there's no metadata file
@@ -457,7 +900,7 @@ func FetchSecrets(localConfig models.ScopedOptions, enableCache bool, fallbackOp
 		if fallbackOpts.Enable && canUseFallback {
 			utils.Log("Unable to fetch secrets from the Doppler API")
 			utils.LogError(httpErr.Unwrap())
-			return readFallbackFile(fallbackOpts.Path, fallbackOpts.LegacyPath, fallbackOpts.Passphrase, false)
+			return readFallbackFile(fallbackOpts, false)
 		}
 		utils.HandleError(httpErr.Unwrap(), httpErr.Message)
 	}
@@ -473,6 +916,14 @@ func FetchSecrets(localConfig models.ScopedOptions, enableCache bool, fallbackOp
 			utils.HandleError(err.Unwrap(), err.Message)
 		}
 
+		// the API confirmed the cached secrets are current, so they're as fresh as a new fetch
+		if !fallbackOpts.Readonly {
+			now := time.Now()
+			if err := os.Chtimes(fallbackOpts.Path, now, now); err != nil {
+				utils.LogDebugError(err)
+			}
+		}
+
 		return cache
 	}
 
@@ -484,7 +935,7 @@ func FetchSecrets(localConfig models.ScopedOptions, enableCache bool, fallbackOp
 		if fallbackOpts.Enable {
 			utils.Log("Unable to parse the Doppler API response")
 			utils.LogError(httpErr.Unwrap())
-			return readFallbackFile(fallbackOpts.Path, fallbackOpts.LegacyPath, fallbackOpts.Passphrase, false)
+			return readFallbackFile(fallbackOpts, false)
 		}
 		utils.HandleError(err, "Unable to parse API response")
 	}
@@ -524,21 +975,257 @@ func FetchSecrets(localConfig models.ScopedOptions, enableCache bool, fallbackOp
 	return secrets
 }
 
-func Run(cmd *cobra.Command, args []string, env []string, forwardSignals bool) (*exec.Cmd, error) {
+// StreamSecretsJSON copies a JSON object of secret names to values from r to w one secret at a time,
+// so the full set of secrets is never held in memory. Secrets are written in the order they're received.
+func StreamSecretsJSON(r io.Reader, w io.Writer) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.New("expected a JSON object")
+	}
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	for i := 0; decoder.More(); i++ {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name, ok := token.(string)
+		if !ok {
+			return errors.New("expected a secret name")
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		// re-encode the name to ensure it's properly escaped
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+
+		separator := ""
+		if i > 0 {
+			separator = ","
+		}
+		if _, err := fmt.Fprintf(w, "%s%s:%s", separator, encodedName, value); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+// metadataSecretNames are the read-only secrets Doppler adds to every config
+var metadataSecretNames = []string{"DOPPLER_PROJECT", "DOPPLER_CONFIG", "DOPPLER_ENVIRONMENT"}
+
+// RemoveMetadataSecrets removes Doppler's read-only metadata secrets (which are computed by the API) from the secrets,
+// returning whether any were removed
+func RemoveMetadataSecrets(secrets map[string]string) bool {
+	removed := false
+	for _, name := range metadataSecretNames {
+		if _, ok := secrets[name]; ok {
+			utils.LogDebug(fmt.Sprintf("Ignoring Doppler metadata secret %s", name))
+			delete(secrets, name)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// runReservedSecretNames are the secrets ignored when injecting secrets into the environment, as overriding them
+// would break the command being run
+var runReservedSecretNames = []string{"PATH", "PS1", "HOME"}
+
+var invalidSecretNameCharsRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ValidateSecretNames returns an error describing each name that is reserved by 'doppler run' or can't be used as an
+// environment variable (i.e. names must consist of letters, digits, and underscores, and must not start with a digit).
+// Doppler's metadata secrets are left for the API to validate, so files created by 'secrets download' can be uploaded.
+func ValidateSecretNames(names []string) error {
+	var problems []string
+	for _, name := range names {
+		switch {
+		case utils.Contains(runReservedSecretNames, name):
+			problems = append(problems, fmt.Sprintf("%s is reserved and would be ignored by 'doppler run'", name))
+		case name == "":
+			problems = append(problems, "secret names must not be empty")
+		case invalidSecretNameCharsRegex.MatchString(name) || (name[0] >= '0' && name[0] <= '9'):
+			suggestion := invalidSecretNameCharsRegex.ReplaceAllString(name, "_")
+			if suggestion[0] >= '0' && suggestion[0] <= '9' {
+				suggestion = "_" + suggestion
+			}
+			problems = append(problems, fmt.Sprintf("%s isn't a valid environment variable name (did you mean %s?)", name, suggestion))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	if len(problems) == 1 {
+		return fmt.Errorf("invalid secret name: %s", problems[0])
+	}
+	return fmt.Errorf("invalid secret names:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// SecretValuesToMask returns the secret values that should be redacted from command output.
+// Doppler's metadata secrets aren't sensitive and are excluded.
+func SecretValuesToMask(secrets map[string]string) []string {
+	var values []string
+	for name, value := range secrets {
+		if utils.Contains(metadataSecretNames, name) {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// SecretValueFilePrefix values beginning with this prefix (e.g. TLS_CERT=@cert.pem) are read from the specified file
+const SecretValueFilePrefix = "@"
+
+// ResolveSecretValueFile returns the contents of the file referenced by a value of the form @path, or the value unchanged
+// when it doesn't reference a file. Windows line endings are converted to LF unless keepCRLF is true, and the
+// contents are otherwise used exactly as written unless trim is true.
+func ResolveSecretValueFile(value string, trim bool, keepCRLF bool) (string, error) {
+	if !strings.HasPrefix(value, SecretValueFilePrefix) {
+		return value, nil
+	}
+
+	path, err := utils.ParsePath(strings.TrimPrefix(value, SecretValueFilePrefix))
+	if err != nil {
+		return "", err
+	}
+	contents, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return "", err
+	}
+
+	resolved := string(contents)
+	if !keepCRLF {
+		resolved = utils.NormalizeLineEndings(resolved)
+	}
+	if trim {
+		resolved = strings.TrimSpace(resolved)
+	}
+	return resolved, nil
+}
+
+// ParseSecretsFile parses a JSON or YAML document containing a flat map of secret names to values. Numbers and booleans
+// are taken exactly as written (e.g. 1.10 stays 1.10), so uploading a file doesn't change its values.
+func ParseSecretsFile(data []byte) (map[string]string, error) {
+	// YAML is a superset of JSON, so a single parser handles both formats. decoding into a map validates the document
+	// (e.g. rejecting duplicate names), while the nodes preserve the text of each value
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+	if len(doc.Content) == 0 {
+		return secrets, nil
+	}
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		name := mapping.Content[i].Value
+		value := mapping.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("secret %s must be a string, number, or boolean", name)
+		}
+		if value.Tag == "!!null" {
+			secrets[name] = ""
+		} else {
+			secrets[name] = value.Value
+		}
+	}
+	return secrets, nil
+}
+
+// SecretsDiff the changes required to turn one set of secrets into another
+type SecretsDiff struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// IsEmpty whether the diff contains any changes
+func (d SecretsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// DiffSecrets computes the changes needed to make current match desired. Doppler's metadata secrets are never removed.
+// Unreadable names are current secrets whose values are restricted (see GetRawSecretValues); since their values can't be
+// compared, they're changed if desired and removed otherwise.
+func DiffSecrets(current map[string]string, unreadable []string, desired map[string]string) SecretsDiff {
+	var diff SecretsDiff
+	for name, value := range desired {
+		currentValue, exists := current[name]
+		if utils.Contains(unreadable, name) {
+			diff.Changed = append(diff.Changed, name)
+		} else if !exists {
+			diff.Added = append(diff.Added, name)
+		} else if currentValue != value {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range current {
+		if _, exists := desired[name]; !exists && !utils.Contains(metadataSecretNames, name) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	for _, name := range unreadable {
+		if _, exists := desired[name]; !exists && !utils.Contains(metadataSecretNames, name) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+func Run(cmd *cobra.Command, args []string, env []string, stdout io.Writer, stderr io.Writer, forwardSignals bool) (*exec.Cmd, error) {
 	var c *exec.Cmd
 	var err error
 
 	if cmd.Flags().Changed("command") {
 		command := cmd.Flag("command").Value.String()
-		c, err = utils.RunCommandString(command, env, os.Stdin, os.Stdout, os.Stderr, forwardSignals)
+		c, err = utils.RunCommandString(command, env, os.Stdin, stdout, stderr, forwardSignals)
 	} else {
-		c, err = utils.RunCommand(args, env, os.Stdin, os.Stdout, os.Stderr, forwardSignals)
+		c, err = utils.RunCommand(args, env, os.Stdin, stdout, stderr, forwardSignals)
 	}
 
 	return c, err
 }
 
-func readFallbackFile(path string, legacyPath string, passphrase string, silent bool) map[string]string {
+// the number of times to prompt for the fallback file's passphrase before giving up
+const maxPassphraseAttempts = 3
+
+func readFallbackFile(fallbackOpts FallbackOptions, silent bool) map[string]string {
+	path := fallbackOpts.Path
+	passphrase := fallbackOpts.Passphrase
+	promptForPassphrase := fallbackOpts.PromptForPassphrase
+
 	// avoid re-logging if re-running for legacy file
 	// TODO remove this when removing legacy path support
 	if !silent {
@@ -546,12 +1233,15 @@ func readFallbackFile(path string, legacyPath string, passphrase string, silent
 	}
 	utils.LogDebug(fmt.Sprintf("Using fallback file %s", path))
 
-	if _, err := os.Stat(path); err != nil {
+	info, err := os.Stat(path)
+	if err != nil {
 		if os.IsNotExist(err) {
 			// attempt to read from the legacy path, in case the fallback file was created with an older version of the CLI
 			// TODO remove this when releasing CLI v4 (DPLR-435)
-			if legacyPath != "" {
-				return readFallbackFile(legacyPath, "", passphrase, true)
+			if fallbackOpts.LegacyPath != "" {
+				fallbackOpts.Path = fallbackOpts.LegacyPath
+				fallbackOpts.LegacyPath = ""
+				return readFallbackFile(fallbackOpts, true)
 			}
 
 			utils.HandleError(errors.New("The fallback file does not exist"))
@@ -560,6 +1250,13 @@ func readFallbackFile(path string, legacyPath string, passphrase string, silent
 		utils.HandleError(err, "Unable to read fallback file")
 	}
 
+	// the file is written (or touched) after each successful fetch, so its modification time is the age of the secrets
+	age := time.Since(info.ModTime()).Round(time.Second)
+	utils.Log(fmt.Sprintf("Fallback file was last updated %s ago", age))
+	if fallbackOpts.MaxAge > 0 && age > fallbackOpts.MaxAge {
+		utils.HandleError(fmt.Errorf("the fallback file was last updated %s ago, exceeding the max age of %s", age, fallbackOpts.MaxAge), "Refusing to use stale secrets from the fallback file")
+	}
+
 	response, err := ioutil.ReadFile(path) // #nosec G304
 	if err != nil {
 		utils.HandleError(err, "Unable to read fallback file")
@@ -567,6 +1264,13 @@ func readFallbackFile(path string, legacyPath string, passphrase string, silent
 
 	utils.LogDebug("Decrypting fallback file")
 	decryptedSecrets, err := crypto.Decrypt(passphrase, response)
+	// the passphrase may not be derivable from the current scope (e.g. when using a different token), so ask for it
+	for attempt := 0; promptForPassphrase && errors.Is(err, crypto.ErrIncorrectPassphrase) && attempt < maxPassphraseAttempts; attempt++ {
+		if attempt > 0 {
+			utils.LogError(err)
+		}
+		decryptedSecrets, err = crypto.Decrypt(utils.PasswordPrompt("Fallback file passphrase:"), response)
+	}
 	if err != nil {
 		var msg []string
 		msg = append(msg, "")
@@ -580,6 +1284,7 @@ func readFallbackFile(path string, legacyPath string, passphrase string, silent
 		msg = append(msg, color.Green.Render("What should I do now?"))
 		msg = append(msg, "Ensure you are using the same scope that you used when creating the fallback file.")
 		msg = append(msg, "Alternatively, manually specify your configuration using the appropriate flags (e.g. --project).")
+		msg = append(msg, "If the fallback file was saved with a custom passphrase, specify it via --fallback-passphrase or the DOPPLER_PASSPHRASE environment variable.")
 		msg = append(msg, "")
 		msg = append(msg, "Run 'doppler run --help' for more info.")
 		msg = append(msg, "")
@@ -653,3 +1358,53 @@ func getCacheFileETag(metadataPath string, cachePath string) string {
 
 	return ""
 }
+
+// SecretHistory returns the changes to the named secret contained in the specified config logs, in the order the logs were provided
+func SecretHistory(logs []models.ConfigLog, name string) []models.SecretChange {
+	var changes []models.SecretChange
+	for _, log := range logs {
+		for _, diff := range log.Diff {
+			if diff.Name != name {
+				continue
+			}
+			changes = append(changes, models.SecretChange{
+				Log:       log.ID,
+				CreatedAt: log.CreatedAt,
+				User:      log.User,
+				Text:      log.Text,
+				Previous:  diff.Removed,
+				Current:   diff.Added,
+			})
+		}
+	}
+	return changes
+}
+
+// SecretChanges compares the values being set to the config's current secrets, in the order the names were specified.
+// Names without a value (e.g. because --if-not-exists skipped them) are unchanged.
+func SecretChanges(current map[string]models.ComputedSecret, names []string, values map[string]string) []models.ConfigSecretDiff {
+	var changes []models.ConfigSecretDiff
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// the current value is nil when it can't be read (e.g. due to its visibility), so it's reported as changed
+		secret, exists := current[name]
+		from := secret.RawValue
+		to, ok := values[name]
+		switch {
+		case !ok:
+			changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretUnchanged, From: from, To: from})
+		case !exists:
+			changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretAdded, To: &to})
+		case from != nil && *from == to:
+			changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretUnchanged, From: from, To: &to})
+		default:
+			changes = append(changes, models.ConfigSecretDiff{Name: name, Change: models.SecretChanged, From: from, To: &to})
+		}
+	}
+	return changes
+}
@@ -17,9 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,3 +100,388 @@ func TestSecretsToBytes(t *testing.T) {
 		t.Errorf("Unable to convert secrets to byte array in %s format", format)
 	}
 }
+
+func TestRenderSecretsPlaceholders(t *testing.T) {
+	secrets := map[string]string{"HOST": "127.0.0.1", "PORT": "8080"}
+	body := "listen ${HOST}:${PORT}; ${MISSING} $HOST"
+
+	output, err := RenderSecretsPlaceholders(body, secrets, MissingPlaceholderKeep)
+	assert.Nil(t, err)
+	assert.Equal(t, "listen 127.0.0.1:8080; ${MISSING} $HOST", output)
+
+	output, err = RenderSecretsPlaceholders(body, secrets, MissingPlaceholderEmpty)
+	assert.Nil(t, err)
+	assert.Equal(t, "listen 127.0.0.1:8080;  $HOST", output)
+
+	_, err = RenderSecretsPlaceholders(body, secrets, MissingPlaceholderError)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "MISSING")
+
+	_, err = RenderSecretsPlaceholders(body, secrets, "invalid")
+	assert.NotNil(t, err)
+}
+
+func TestTemplatePlaceholderActions(t *testing.T) {
+	// values aren't treated as templates or placeholders
+	secrets := map[string]string{"HOST": "{{.PORT}}", "PORT": "${HOST}"}
+	body := "listen ${HOST}:{{.PORT}}; ${MISSING}"
+
+	templateBody, err := TemplatePlaceholderActions(body, secrets, MissingPlaceholderKeep)
+	assert.NoError(t, err)
+	assert.Equal(t, "listen {{.PORT}}:${HOST}; ${MISSING}", RenderSecretsTemplate(templateBody, secrets))
+
+	templateBody, err = TemplatePlaceholderActions(body, secrets, MissingPlaceholderEmpty)
+	assert.NoError(t, err)
+	assert.Equal(t, "listen {{.PORT}}:${HOST}; ", RenderSecretsTemplate(templateBody, secrets))
+
+	_, err = TemplatePlaceholderActions(body, secrets, MissingPlaceholderError)
+	assert.EqualError(t, err, "the template references secrets which do not exist in your config:\n- MISSING")
+}
+
+func TestParseSecretsFile(t *testing.T) {
+	secrets, err := ParseSecretsFile([]byte(`{"A": "1", "B": 2, "C": true, "D": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "1", "B": "2", "C": "true", "D": ""}, secrets)
+
+	secrets, err = ParseSecretsFile([]byte("A: one\nB: 'two'\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "one", "B": "two"}, secrets)
+
+	_, err = ParseSecretsFile([]byte("A:\n  B: nested\n"))
+	assert.Error(t, err)
+
+	// numbers are uploaded exactly as written
+	secrets, err = ParseSecretsFile([]byte("VERSION: 1.10\nTIMEOUT: 1e3\nMODE: 0o644\nFLAGS: 0x1F\nID: 123456789012345678901234567890\nNULL: 'null'\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"VERSION": "1.10", "TIMEOUT": "1e3", "MODE": "0o644", "FLAGS": "0x1F", "ID": "123456789012345678901234567890", "NULL": "null"}, secrets)
+	secrets, err = ParseSecretsFile([]byte(`{"VERSION": 1.10, "ID": 123456789012345678901234567890, "ESCAPED": "a\nb"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"VERSION": "1.10", "ID": "123456789012345678901234567890", "ESCAPED": "a\nb"}, secrets)
+
+	secrets, err = ParseSecretsFile([]byte(""))
+	assert.NoError(t, err)
+	assert.Empty(t, secrets)
+}
+
+func TestUploadDownloadedSecrets(t *testing.T) {
+	// the shape of 'secrets download --format json'
+	downloaded := `{"API_KEY":"abc","CERT":"line1\nline2","DOPPLER_CONFIG":"dev","DOPPLER_ENVIRONMENT":"dev","DOPPLER_PROJECT":"backend","EMPTY":""}`
+	secrets, err := ParseSecretsFile([]byte(downloaded))
+	assert.NoError(t, err)
+
+	assert.True(t, RemoveMetadataSecrets(secrets))
+	assert.Equal(t, map[string]string{"API_KEY": "abc", "CERT": "line1\nline2", "EMPTY": ""}, secrets)
+	assert.NoError(t, ValidateSecretNames([]string{"API_KEY", "CERT", "EMPTY"}))
+	assert.False(t, RemoveMetadataSecrets(secrets))
+}
+
+func TestDiffSecrets(t *testing.T) {
+	current := map[string]string{"DOPPLER_CONFIG": "dev", "KEEP": "1", "CHANGE": "old", "REMOVE": "x"}
+	desired := map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "y"}
+
+	diff := DiffSecrets(current, nil, desired)
+	assert.Equal(t, []string{"ADD"}, diff.Added)
+	assert.Equal(t, []string{"CHANGE"}, diff.Changed)
+	assert.Equal(t, []string{"REMOVE"}, diff.Removed)
+	assert.False(t, diff.IsEmpty())
+
+	assert.True(t, DiffSecrets(desired, nil, desired).IsEmpty())
+
+	// restricted values can't be compared, so they're always set or removed
+	diff = DiffSecrets(desired, []string{"RESTRICTED", "RESTRICTED_KEPT"}, map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "y", "RESTRICTED_KEPT": "z"})
+	assert.Empty(t, diff.Added)
+	assert.Equal(t, []string{"RESTRICTED_KEPT"}, diff.Changed)
+	assert.Equal(t, []string{"RESTRICTED"}, diff.Removed)
+}
+
+func TestStreamSecretsJSON(t *testing.T) {
+	var out strings.Builder
+	err := StreamSecretsJSON(strings.NewReader(`{"B": "2", "A": "multi\nline \"quoted\"", "EMPTY": ""}`), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"B\":\"2\",\"A\":\"multi\\nline \\\"quoted\\\"\",\"EMPTY\":\"\"}\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, StreamSecretsJSON(strings.NewReader(`{}`), &out))
+	assert.Equal(t, "{}\n", out.String())
+
+	assert.Error(t, StreamSecretsJSON(strings.NewReader(`["A"]`), &out))
+	assert.Error(t, StreamSecretsJSON(strings.NewReader(`{"A": "1"`), &out))
+}
+
+func TestSecretHistory(t *testing.T) {
+	logs := []models.ConfigLog{
+		{ID: "3", Diff: []models.LogDiff{{Name: "OTHER", Added: "x"}, {Name: "API_KEY", Removed: "b"}}},
+		{ID: "2", Text: "Updated secrets", Diff: []models.LogDiff{{Name: "API_KEY", Removed: "a", Added: "b"}}},
+		{ID: "1", Diff: []models.LogDiff{{Name: "API_KEY", Added: "a"}}},
+		{ID: "0", Diff: []models.LogDiff{{Removed: "config created"}}},
+	}
+
+	changes := SecretHistory(logs, "API_KEY")
+	assert.Equal(t, []models.SecretChange{
+		{Log: "3", Previous: "b"},
+		{Log: "2", Text: "Updated secrets", Previous: "a", Current: "b"},
+		{Log: "1", Current: "a"},
+	}, changes)
+
+	assert.Empty(t, SecretHistory(logs, "MISSING"))
+}
+
+func TestOmitEmptySecrets(t *testing.T) {
+	secrets := map[string]string{"HOST": "localhost", "EMPTY": "", "SPACE": " "}
+
+	nonEmpty := OmitEmptySecrets(secrets)
+	assert.Equal(t, map[string]string{"HOST": "localhost", "SPACE": " "}, nonEmpty)
+	// the original secrets are unchanged
+	assert.Len(t, secrets, 3)
+
+	// dotenv output
+	bytes, err := SecretsToBytes(secrets, models.EnvMountFormat, "")
+	assert.True(t, err.IsNil())
+	assert.Contains(t, string(bytes), `EMPTY=""`)
+	bytes, err = SecretsToBytes(nonEmpty, models.EnvMountFormat, "")
+	assert.True(t, err.IsNil())
+	assert.Equal(t, strings.Join([]string{`HOST="localhost"`, `SPACE=" "`}, "\n"), string(bytes))
+
+	// run environment
+	env, _ := PrepareSecrets(secrets, []string{"EMPTY=original"}, "false", MountOptions{})
+	assert.Contains(t, env, "EMPTY=")
+	assert.NotContains(t, env, "EMPTY=original")
+	env, _ = PrepareSecrets(OmitEmptySecrets(secrets), []string{"EMPTY=original"}, "false", MountOptions{})
+	assert.Contains(t, env, "EMPTY=original")
+	assert.Contains(t, env, "HOST=localhost")
+}
+
+func TestResolveSecretValueFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	contents := "-----BEGIN CERTIFICATE-----\r\nabc\n-----END CERTIFICATE-----\n"
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+
+	value, err := ResolveSecretValueFile("@"+path, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, value)
+
+	value, err = ResolveSecretValueFile("@"+path, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----\n", value)
+
+	value, err = ResolveSecretValueFile("@"+path, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----", value)
+
+	value, err = ResolveSecretValueFile("plain value\r\n", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain value\r\n", value)
+
+	_, err = ResolveSecretValueFile("@"+filepath.Join(t.TempDir(), "missing.pem"), false, false)
+	assert.Error(t, err)
+
+	_, err = ResolveSecretValueFile("@", false, false)
+	assert.Error(t, err)
+}
+
+func TestFormatSecrets(t *testing.T) {
+	secrets := map[string]string{"B": "it's 100%", "A": "line1\nline2"}
+
+	systemd, err := FormatSecrets(secrets, models.SYSTEMD)
+	assert.NoError(t, err)
+	assert.Equal(t, "[Service]\nEnvironment=\"A=line1\\nline2\"\nEnvironment=\"B=it's 100%%\"", string(systemd))
+
+	nginx, err := FormatSecrets(secrets, models.NGINX)
+	assert.NoError(t, err)
+	assert.Equal(t, "env A;\nenv B;", string(nginx))
+
+	shell, err := FormatSecrets(secrets, models.SHELL)
+	assert.NoError(t, err)
+	assert.Equal(t, "export A='line1\nline2'\nexport B='it'\\''s 100%'", string(shell))
+
+	tfvars, err := FormatSecrets(secrets, models.TFVARS)
+	assert.NoError(t, err)
+	assert.Equal(t, "A = \"line1\\nline2\"\nB = \"it's 100%\"", string(tfvars))
+
+	env, err := FormatSecrets(secrets, models.ENV)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=\"line1\\nline2\"\nB=\"it's 100%\"", string(env))
+
+	_, err = FormatSecrets(secrets, models.DOCKER)
+	assert.EqualError(t, err, "A: values containing line breaks can't be represented in the docker format")
+
+	_, err = FormatSecrets(secrets, models.YAML)
+	assert.Error(t, err)
+}
+
+func TestFormatTerraformVariables(t *testing.T) {
+	secrets := map[string]string{"API_URL": "https://example.com/?a=1&b=<2>", "db-host": "${HOST}", "1PASSWORD": "x", "count": "3"}
+
+	tfvars, err := FormatSecrets(secrets, models.TFVARS)
+	assert.NoError(t, err)
+	assert.Equal(t, `API_URL = "https://example.com/?a=1&b=<2>"
+db-host = "$${HOST}"`, string(tfvars))
+
+	tfvarsJSON, err := FormatSecrets(secrets, models.TFVARS_JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "API_URL": "https://example.com/?a=1&b=<2>",
+  "db-host": "${HOST}"
+}`, string(tfvarsJSON))
+}
+
+func TestFormatKubernetesSecret(t *testing.T) {
+	secrets := map[string]string{"API_KEY": "abc123", "EMPTY": "", "app.config": "a: b"}
+
+	manifest, err := FormatKubernetesSecret(secrets, KubernetesSecretOptions{Name: "my-secret", Namespace: "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+  namespace: default
+type: Opaque
+data:
+  API_KEY: YWJjMTIz
+  EMPTY: ""
+  app.config: YTogYg==`, string(manifest))
+
+	manifest, err = FormatKubernetesSecret(map[string]string{"MY KEY": "value"}, KubernetesSecretOptions{Name: "my-secret", Type: "kubernetes.io/basic-auth"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifest), "type: kubernetes.io/basic-auth\n")
+	assert.Contains(t, string(manifest), "MY_KEY: dmFsdWU=")
+	assert.NotContains(t, string(manifest), "namespace")
+
+	_, err = FormatKubernetesSecret(map[string]string{"MY KEY": "a", "MY_KEY": "b"}, KubernetesSecretOptions{Name: "my-secret"})
+	assert.Error(t, err)
+
+	_, err = FormatKubernetesSecret(secrets, KubernetesSecretOptions{Name: "My_Secret"})
+	assert.Error(t, err)
+	_, err = FormatKubernetesSecret(secrets, KubernetesSecretOptions{Name: "my-secret", Namespace: "my.namespace"})
+	assert.Error(t, err)
+}
+
+func TestAddSecretSources(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		config := r.URL.Query().Get("config")
+		switch r.URL.Path {
+		case "/v3/configs/config":
+			fmt.Fprintf(w, `{"config":{"name":%q,"root":%t,"environment":"dev","project":"backend"}}`, config, config == "dev")
+		case "/v3/configs":
+			// one config per page, so the root config is on the last page
+			assert.Equal(t, "dev", r.URL.Query().Get("environment"))
+			switch r.URL.Query().Get("page") {
+			case "1":
+				fmt.Fprint(w, `{"configs":[{"name":"dev_personal","root":false}]}`)
+			case "2":
+				fmt.Fprint(w, `{"configs":[{"name":"dev","root":true}]}`)
+			default:
+				fmt.Fprint(w, `{"configs":[]}`)
+			}
+		case "/v3/configs/config/secrets":
+			assert.Equal(t, "dev", config)
+			fmt.Fprint(w, `{"secrets":{"HOST":{"raw":"localhost","computed":"localhost"},"PORT":{"raw":"80","computed":"80"}}}`)
+		default:
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	})
+	utils.PageSize = 1
+
+	value := func(v string) *string { return &v }
+	secrets := map[string]models.ComputedSecret{
+		"HOST":  {Name: "HOST", RawValue: value("localhost")},
+		"PORT":  {Name: "PORT", RawValue: value("8080")},
+		"DEBUG": {Name: "DEBUG", RawValue: value("true")},
+	}
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+	options.EnclaveConfig = models.ScopedOption{Value: "dev_personal"}
+
+	err := AddSecretSources(options, secrets)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, models.SecretSourceDefault, secrets["HOST"].Source)
+	assert.Equal(t, models.SecretSourceOverridden, secrets["PORT"].Source)
+	assert.Equal(t, models.SecretSourceCustom, secrets["DEBUG"].Source)
+
+	// a root config's secrets are the environment's defaults
+	options.EnclaveConfig.Value = "dev"
+	err = AddSecretSources(options, secrets)
+	assert.True(t, err.IsNil())
+	for _, secret := range secrets {
+		assert.Equal(t, models.SecretSourceDefault, secret.Source)
+	}
+}
+
+func TestGetRawSecretValues(t *testing.T) {
+	options := newTestAPI(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		assert.Equal(t, "/v3/configs/config/secrets", r.URL.Path)
+		fmt.Fprint(w, `{"secrets":{"HOST":{"raw":"localhost","computed":"localhost"},"URL":{"raw":"http://${HOST}","computed":"http://localhost"},`+
+			`"TOKEN":{"raw":null,"computed":null},"DOPPLER_CONFIG":{"raw":"dev","computed":"dev"}}}`)
+	})
+
+	options.EnclaveProject = models.ScopedOption{Value: "backend"}
+	options.EnclaveConfig = models.ScopedOption{Value: "dev"}
+
+	values, unreadable, err := GetRawSecretValues(options)
+	assert.True(t, err.IsNil())
+	// references aren't expanded, and metadata secrets are omitted
+	assert.Equal(t, map[string]string{"HOST": "localhost", "URL": "http://${HOST}"}, values)
+	assert.Equal(t, []string{"TOKEN"}, unreadable)
+}
+
+func TestValidateSecretNames(t *testing.T) {
+	assert.NoError(t, ValidateSecretNames([]string{"API_KEY", "_PRIVATE", "db_url2", "DOPPLER_CONFIG"}))
+	assert.NoError(t, ValidateSecretNames(nil))
+
+	assert.EqualError(t, ValidateSecretNames([]string{"API-KEY"}), "invalid secret name: API-KEY isn't a valid environment variable name (did you mean API_KEY?)")
+	assert.EqualError(t, ValidateSecretNames([]string{"2FA.SECRET", "PATH", "OK", ""}), "invalid secret names:\n"+
+		"  2FA.SECRET isn't a valid environment variable name (did you mean _2FA_SECRET?)\n"+
+		"  PATH is reserved and would be ignored by 'doppler run'\n"+
+		"  secret names must not be empty")
+}
+
+func TestSortSecretsOutput(t *testing.T) {
+	// unquoted values may span multiple lines
+	sorted, err := SortSecretsOutput([]byte("ZED=1\nCERT=-----BEGIN-----\n+/abc=\n-----END-----\nA_B=2\nA=3\n"), models.ENV_NO_QUOTES)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=3\nA_B=2\nCERT=-----BEGIN-----\n+/abc=\n-----END-----\nZED=1\n", string(sorted))
+
+	sorted, err = SortSecretsOutput([]byte("B: '2'\nA: |-\n  line1\n  line2\n"), models.YAML)
+	assert.NoError(t, err)
+	assert.Equal(t, "A: |-\n  line1\n  line2\nB: '2'\n", string(sorted))
+
+	sorted, err = SortSecretsOutput([]byte(`{"B":"<2>","A:Nested":"1"}`), models.DOTNET_JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A:Nested":"1","B":"<2>"}`, string(sorted))
+
+	sorted, err = SortSecretsOutput([]byte("{\n  \"B\": \"2\",\n  \"A\": \"1\"\n}\n"), models.DOTNET_JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"A\": \"1\",\n  \"B\": \"2\"\n}\n", string(sorted))
+
+	// formats rendered by the CLI are already sorted
+	sorted, err = SortSecretsOutput([]byte("export B='2'\nexport A='1'"), models.SHELL)
+	assert.NoError(t, err)
+	assert.Equal(t, "export B='2'\nexport A='1'", string(sorted))
+
+	_, err = SortSecretsOutput([]byte("{"), models.DOTNET_JSON)
+	assert.Error(t, err)
+}
+
+func TestSecretChanges(t *testing.T) {
+	value := func(v string) *string { return &v }
+	current := map[string]models.ComputedSecret{
+		"CHANGED":    {RawValue: value("old")},
+		"SAME":       {RawValue: value("same")},
+		"RESTRICTED": {},
+	}
+
+	changes := SecretChanges(current, []string{"NEW", "CHANGED", "SAME", "RESTRICTED", "SKIPPED", "NEW"}, map[string]string{
+		"NEW":        "new",
+		"CHANGED":    "new",
+		"SAME":       "same",
+		"RESTRICTED": "new",
+	})
+	assert.Equal(t, []models.ConfigSecretDiff{
+		{Name: "NEW", Change: models.SecretAdded, To: value("new")},
+		{Name: "CHANGED", Change: models.SecretChanged, From: value("old"), To: value("new")},
+		{Name: "SAME", Change: models.SecretUnchanged, From: value("same"), To: value("same")},
+		// a value that can't be read can't be compared
+		{Name: "RESTRICTED", Change: models.SecretChanged, To: value("new")},
+		// names without a value were skipped, e.g. by --if-not-exists
+		{Name: "SKIPPED", Change: models.SecretUnchanged},
+	}, changes)
+}
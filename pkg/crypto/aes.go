@@ -37,6 +37,9 @@ import (
 
 var currentFileVersion = models.FileVersions[4].Version
 
+// ErrIncorrectPassphrase the ciphertext failed authentication, typically because the passphrase is wrong
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase or corrupted file")
+
 func deriveKey(passphrase string, salt []byte, numRounds int) ([]byte, []byte, error) {
 	if salt == nil {
 		salt = make([]byte, 8)
@@ -211,9 +214,10 @@ func Decrypt(passphrase string, ciphertext []byte) (string, error) {
 		return "", err
 	}
 
+	// gcm authenticates the ciphertext, so a wrong passphrase is detected rather than producing garbage
 	data, err = aesgcm.Open(nil, iv, data, nil)
 	if err != nil {
-		return "", err
+		return "", ErrIncorrectPassphrase
 	}
 
 	return string(data), nil
@@ -16,6 +16,7 @@ limitations under the License.
 package crypto
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -104,3 +105,14 @@ func TestEncrypt(t *testing.T) {
 		t.Error("Invalid plaintext when decrypting base64 value")
 	}
 }
+
+func TestDecryptIncorrectPassphrase(t *testing.T) {
+	ciphertext, err := Encrypt(originalPassphrase, []byte(originalPlaintext), "base64")
+	if err != nil {
+		t.Error("Invalid ciphertext when encrypting value w/ base64 encoding")
+	}
+	plaintext, err := Decrypt("incorrect passphrase", []byte(ciphertext))
+	if !errors.Is(err, ErrIncorrectPassphrase) || plaintext != "" {
+		t.Error(fmt.Sprintf("Expected incorrect passphrase error, got %v", err))
+	}
+}
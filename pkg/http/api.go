@@ -18,12 +18,14 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/utils"
 	"github.com/DopplerHQ/cli/pkg/version"
 )
 
@@ -40,6 +42,22 @@ func (e *Error) Unwrap() error { return e.Err }
 // IsNil whether the error is nil
 func (e *Error) IsNil() bool { return e.Err == nil && e.Message == "" }
 
+// parseListMeta reads pagination info from a list response, falling back to the requested page
+func parseListMeta(headers http.Header, result map[string]interface{}, page int) models.ListMeta {
+	meta := models.ListMeta{RequestID: headers.Get("x-request-id"), ETag: headers.Get("etag"), Page: page}
+	if responsePage, ok := result["page"].(float64); ok {
+		meta.Page = int(responsePage)
+	}
+	if meta.Page == 0 {
+		meta.Page = 1
+	}
+	if total, ok := result["total"].(float64); ok {
+		t := int(total)
+		meta.Total = &t
+	}
+	return meta
+}
+
 func apiKeyHeader(apiKey string) map[string]string {
 	return map[string]string{"Authorization": fmt.Sprintf("Bearer %s", apiKey)}
 }
@@ -170,7 +188,7 @@ func WatchSecrets(host string, verifyTLS bool, apiKey string, project string, co
 	headers["Accept"] = "text/event-stream"
 	headers["Connection"] = "keep-alive"
 
-	req, err := http.NewRequest("GET", url.String(), nil)
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "GET", url.String(), nil)
 	if err != nil {
 		return 0, nil, Error{Err: err, Message: "Unable to submit request"}
 	}
@@ -224,6 +242,35 @@ func DownloadSecrets(host string, verifyTLS bool, apiKey string, project string,
 	return statusCode, respHeaders, response, Error{}
 }
 
+// StreamSecrets downloads secrets in JSON format, passing the response body to the handler as it's received
+func StreamSecrets(host string, verifyTLS bool, apiKey string, project string, config string, nameTransformer *models.SecretsNameTransformer, dynamicSecretsTTL time.Duration, handler func(io.Reader) error) Error {
+	var params []queryParam
+	params = append(params, queryParam{Key: "project", Value: project})
+	params = append(params, queryParam{Key: "config", Value: config})
+	params = append(params, queryParam{Key: "format", Value: models.JSON.String()})
+	params = append(params, queryParam{Key: "include_dynamic_secrets", Value: "true"})
+
+	if dynamicSecretsTTL > 0 {
+		ttlSeconds := int(dynamicSecretsTTL.Seconds())
+		params = append(params, queryParam{Key: "dynamic_secrets_ttl_sec", Value: strconv.Itoa(ttlSeconds)})
+	}
+	if nameTransformer != nil {
+		params = append(params, queryParam{Key: "name_transformer", Value: nameTransformer.Type})
+	}
+
+	url, err := generateURL(host, "/v3/configs/config/secrets/download", params)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to generate url"}
+	}
+
+	statusCode, _, err := GetRequestStream(url, verifyTLS, apiKeyHeader(apiKey), handler)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to download secrets", Code: statusCode}
+	}
+
+	return Error{}
+}
+
 // GetSecrets for specified project and config
 func GetSecrets(host string, verifyTLS bool, apiKey string, project string, config string, secrets []string, includeDynamicSecrets bool, dynamicSecretsTTL time.Duration) ([]byte, Error) {
 	var params []queryParam
@@ -762,6 +809,12 @@ func RenameEnvironment(host string, verifyTLS bool, apiKey string, project strin
 
 // GetConfigs get configs
 func GetConfigs(host string, verifyTLS bool, apiKey string, project string, environment string, page int, number int) ([]models.ConfigInfo, Error) {
+	configs, _, err := GetConfigsPage(host, verifyTLS, apiKey, project, environment, page, number)
+	return configs, err
+}
+
+// GetConfigsPage get configs, along with metadata about the page of results
+func GetConfigsPage(host string, verifyTLS bool, apiKey string, project string, environment string, page int, number int) ([]models.ConfigInfo, models.ListMeta, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "per_page", Value: strconv.Itoa(number)})
@@ -772,60 +825,88 @@ func GetConfigs(host string, verifyTLS bool, apiKey string, project string, envi
 
 	url, err := generateURL(host, "/v3/configs", params)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to generate url"}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to generate url"}
 	}
 
-	statusCode, _, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	statusCode, headers, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
 	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	var info []models.ConfigInfo
 	for _, config := range result["configs"].([]interface{}) {
 		config, ok := config.(map[string]interface{})
 		if !ok {
-			return nil, Error{Err: fmt.Errorf("Unexpected type parsing config, expected map[string]interface{}, got %T", config), Message: "Unable to parse API response", Code: statusCode}
+			return nil, models.ListMeta{}, Error{Err: fmt.Errorf("Unexpected type parsing config, expected map[string]interface{}, got %T", config), Message: "Unable to parse API response", Code: statusCode}
 		}
 		configInfo := models.ParseConfigInfo(config)
 		info = append(info, configInfo)
 	}
-	return info, Error{}
+	return info, parseListMeta(headers, result, page), Error{}
+}
+
+// StreamConfigs get configs, passing the response body to the handler as it's received rather than buffering it
+func StreamConfigs(host string, verifyTLS bool, apiKey string, project string, environment string, page int, number int, handler func(io.Reader) error) Error {
+	var params []queryParam
+	params = append(params, queryParam{Key: "project", Value: project})
+	params = append(params, queryParam{Key: "per_page", Value: strconv.Itoa(number)})
+	params = append(params, queryParam{Key: "page", Value: strconv.Itoa(page)})
+	if environment != "" {
+		params = append(params, queryParam{Key: "environment", Value: environment})
+	}
+
+	url, err := generateURL(host, "/v3/configs", params)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to generate url"}
+	}
+
+	statusCode, _, err := GetRequestStream(url, verifyTLS, apiKeyHeader(apiKey), handler)
+	if err != nil {
+		return Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
+	}
+	return Error{}
 }
 
 // GetConfig get a config
 func GetConfig(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, Error) {
+	info, _, err := GetConfigWithETag(host, verifyTLS, apiKey, project, config)
+	return info, err
+}
+
+// GetConfigWithETag get a config, along with the ETag the API returned for it (empty if none was returned)
+func GetConfigWithETag(host string, verifyTLS bool, apiKey string, project string, config string) (models.ConfigInfo, string, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
 
 	url, err := generateURL(host, "/v3/configs/config", params)
 	if err != nil {
-		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to generate url"}
+		return models.ConfigInfo{}, "", Error{Err: err, Message: "Unable to generate url"}
 	}
 
-	statusCode, _, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	statusCode, headers, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
 	if err != nil {
-		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
+		return models.ConfigInfo{}, "", Error{Err: err, Message: "Unable to fetch configs", Code: statusCode}
 	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return models.ConfigInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return models.ConfigInfo{}, "", Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	configInfo, ok := result["config"].(map[string]interface{})
 	if !ok {
-		return models.ConfigInfo{}, Error{Err: fmt.Errorf("Unexpected type parsing config, expected map[string]interface{}, got %T", result["config"]), Message: "Unable to parse API response", Code: statusCode}
+		return models.ConfigInfo{}, "", Error{Err: fmt.Errorf("Unexpected type parsing config, expected map[string]interface{}, got %T", result["config"]), Message: "Unable to parse API response", Code: statusCode}
 	}
 	info := models.ParseConfigInfo(configInfo)
-	return info, Error{}
+	return info, headers.Get("etag"), Error{}
 }
 
 // CreateConfig create a config
@@ -984,9 +1065,15 @@ func CloneConfig(host string, verifyTLS bool, apiKey string, project string, con
 	return info, Error{}
 }
 
-// UpdateConfig update a config
-func UpdateConfig(host string, verifyTLS bool, apiKey string, project string, config string, name string) (models.ConfigInfo, Error) {
-	postBody := map[string]interface{}{"name": name}
+// UpdateConfig update a config's name and description. An empty name or a nil description leaves it unchanged.
+func UpdateConfig(host string, verifyTLS bool, apiKey string, project string, config string, name string, description *string) (models.ConfigInfo, Error) {
+	postBody := map[string]interface{}{}
+	if name != "" {
+		postBody["name"] = name
+	}
+	if description != nil {
+		postBody["description"] = *description
+	}
 	body, err := json.Marshal(postBody)
 	if err != nil {
 		return models.ConfigInfo{}, Error{Err: err, Message: "Invalid config info"}
@@ -1022,6 +1109,12 @@ func UpdateConfig(host string, verifyTLS bool, apiKey string, project string, co
 
 // GetActivityLogs get activity logs
 func GetActivityLogs(host string, verifyTLS bool, apiKey string, page int, number int) ([]models.ActivityLog, Error) {
+	logs, _, err := GetActivityLogsPage(host, verifyTLS, apiKey, page, number)
+	return logs, err
+}
+
+// GetActivityLogsPage get activity logs, along with metadata about the page of results
+func GetActivityLogsPage(host string, verifyTLS bool, apiKey string, page int, number int) ([]models.ActivityLog, models.ListMeta, Error) {
 	var params []queryParam
 	if page != 0 {
 		params = append(params, queryParam{Key: "page", Value: fmt.Sprint(page)})
@@ -1032,30 +1125,30 @@ func GetActivityLogs(host string, verifyTLS bool, apiKey string, page int, numbe
 
 	url, err := generateURL(host, "/v3/logs", params)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to generate url"}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to generate url"}
 	}
 
-	statusCode, _, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	statusCode, headers, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch activity logs", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to fetch activity logs", Code: statusCode}
 	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	var logs []models.ActivityLog
 	for _, log := range result["logs"].([]interface{}) {
 		log, ok := log.(map[string]interface{})
 		if !ok {
-			return nil, Error{Err: fmt.Errorf("Unexpected type parsing activity log, expected map[string]interface{}, got %T", log), Message: "Unable to parse API response", Code: statusCode}
+			return nil, models.ListMeta{}, Error{Err: fmt.Errorf("Unexpected type parsing activity log, expected map[string]interface{}, got %T", log), Message: "Unable to parse API response", Code: statusCode}
 		}
 		parsedLog := models.ParseActivityLog(log)
 		logs = append(logs, parsedLog)
 	}
-	return logs, Error{}
+	return logs, parseListMeta(headers, result, page), Error{}
 }
 
 // GetActivityLog get specified activity log
@@ -1088,6 +1181,12 @@ func GetActivityLog(host string, verifyTLS bool, apiKey string, log string) (mod
 
 // GetConfigLogs get config audit logs
 func GetConfigLogs(host string, verifyTLS bool, apiKey string, project string, config string, page int, number int) ([]models.ConfigLog, Error) {
+	logs, _, err := GetConfigLogsPage(host, verifyTLS, apiKey, project, config, page, number)
+	return logs, err
+}
+
+// GetConfigLogsPage get config audit logs, along with metadata about the page of results
+func GetConfigLogsPage(host string, verifyTLS bool, apiKey string, project string, config string, page int, number int) ([]models.ConfigLog, models.ListMeta, Error) {
 	var params []queryParam
 	params = append(params, queryParam{Key: "project", Value: project})
 	params = append(params, queryParam{Key: "config", Value: config})
@@ -1100,30 +1199,30 @@ func GetConfigLogs(host string, verifyTLS bool, apiKey string, project string, c
 
 	url, err := generateURL(host, "/v3/configs/config/logs", params)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to generate url"}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to generate url"}
 	}
 
-	statusCode, _, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	statusCode, headers, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to fetch config logs", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to fetch config logs", Code: statusCode}
 	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(response, &result)
 	if err != nil {
-		return nil, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return nil, models.ListMeta{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
 	var logs []models.ConfigLog
 	for _, log := range result["logs"].([]interface{}) {
 		log, ok := log.(map[string]interface{})
 		if !ok {
-			return nil, Error{Err: fmt.Errorf("Unexpected type for ConfigLog response, expected map[string]interface{}, got %T", log), Message: "Unable to parse API response", Code: statusCode}
+			return nil, models.ListMeta{}, Error{Err: fmt.Errorf("Unexpected type for ConfigLog response, expected map[string]interface{}, got %T", log), Message: "Unable to parse API response", Code: statusCode}
 		}
 		parsedLog := models.ParseConfigLog(log)
 		logs = append(logs, parsedLog)
 	}
-	return logs, Error{}
+	return logs, parseListMeta(headers, result, page), Error{}
 }
 
 // GetConfigLog get config audit log
@@ -1336,21 +1435,34 @@ func ImportTemplate(host string, verifyTLS bool, apiKey string, template []byte)
 }
 
 func GetActorInfo(host string, verifyTLS bool, apiKey string) (models.ActorInfo, Error) {
+	info, _, err := GetActorInfoAndServerTime(host, verifyTLS, apiKey)
+	return info, err
+}
+
+// GetActorInfoAndServerTime get info about the authenticated entity, along with the API's time from the response's
+// Date header. The time is zero if the header is missing, and is returned even if the request fails once the API responds
+func GetActorInfoAndServerTime(host string, verifyTLS bool, apiKey string) (models.ActorInfo, time.Time, Error) {
 	url, err := generateURL(host, "/v3/me", nil)
 	if err != nil {
-		return models.ActorInfo{}, Error{Err: err, Message: "Unable to generate url"}
+		return models.ActorInfo{}, time.Time{}, Error{Err: err, Message: "Unable to generate url"}
 	}
 
-	statusCode, _, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	statusCode, headers, response, err := GetRequest(url, verifyTLS, apiKeyHeader(apiKey))
+	var serverTime time.Time
+	if headers != nil {
+		if date, parseErr := http.ParseTime(headers.Get("Date")); parseErr == nil {
+			serverTime = date
+		}
+	}
 	if err != nil {
-		return models.ActorInfo{}, Error{Err: err, Message: "Unable to fetch actor", Code: statusCode}
+		return models.ActorInfo{}, serverTime, Error{Err: err, Message: "Unable to fetch actor", Code: statusCode}
 	}
 
 	var info models.ActorInfo
 	err = json.Unmarshal(response, &info)
 	if err != nil {
-		return models.ActorInfo{}, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
+		return models.ActorInfo{}, serverTime, Error{Err: err, Message: "Unable to parse API response", Code: statusCode}
 	}
 
-	return info, Error{}
+	return info, serverTime, Error{}
 }
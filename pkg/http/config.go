@@ -15,7 +15,17 @@ limitations under the License.
 */
 package http
 
-import "time"
+import (
+	"crypto/tls"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/version"
+)
 
 // UseTimeout whether to timeout long-running requests
 var UseTimeout = true
@@ -25,3 +35,77 @@ var TimeoutDuration = 10 * time.Second
 
 // RequestAttempts how many request attempts are made before giving up
 var RequestAttempts = 5
+
+// AllowPlaintextHTTP whether requests may be sent to http:// hosts, exposing the token to anyone observing network traffic
+var AllowPlaintextHTTP = false
+
+// MinTLSVersion the minimum TLS version negotiated with the API
+var MinTLSVersion uint16 = tls.VersionTLS12
+
+// TLSCipherSuites the cipher suites allowed for TLS 1.2 connections. nil allows Go's default cipher suites.
+// TLS 1.3 cipher suites aren't configurable.
+var TLSCipherSuites []uint16
+
+// TLSVersions the supported values for the minimum TLS version
+var TLSVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// ParseTLSVersion parses a TLS version (e.g. 1.3)
+func ParseTLSVersion(version string) (uint16, error) {
+	if v, ok := TLSVersions[version]; ok {
+		return v, nil
+	}
+
+	var versions []string
+	for v := range TLSVersions {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return 0, fmt.Errorf("unsupported TLS version %q. Supported versions are %s", version, strings.Join(versions, ", "))
+}
+
+// ParseTLSCipherSuites parses IANA cipher suite names (e.g. TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384).
+// Only secure cipher suites that can be used with TLS 1.2 are accepted.
+func ParseTLSCipherSuites(names []string) ([]uint16, error) {
+	supported := map[string]uint16{}
+	var supportedNames []string
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				supported[suite.Name] = suite.ID
+				supportedNames = append(supportedNames, suite.Name)
+				break
+			}
+		}
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := supported[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS 1.2 cipher suite %q. Supported cipher suites are %s", name, strings.Join(supportedNames, ", "))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// UserAgent the User-Agent header sent with every request
+var UserAgent = DefaultUserAgent()
+
+// DefaultUserAgent identifies the CLI version and platform (e.g. doppler-cli/3.68.0 (darwin; arm64))
+func DefaultUserAgent() string {
+	return fmt.Sprintf("doppler-cli/%s (%s; %s)", version.ProgramVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// MaxClockSkew how far the local clock may differ from the server's before warning
+const MaxClockSkew = 5 * time.Minute
+
+// SunsetWarningPeriod how soon before an endpoint's sunset date its deprecation is considered imminent
+const SunsetWarningPeriod = 30 * 24 * time.Hour
+
+// reportedAPIWarnings the API warnings that have already been printed, so each is only printed once per run
+var reportedAPIWarnings = map[string]bool{}
+var reportedAPIWarningsMutex sync.Mutex
+
+// clockSkewWarning ensures the clock skew warning is only printed once
+var clockSkewWarning sync.Once
@@ -19,14 +19,17 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -45,6 +48,47 @@ type errorResponse struct {
 	Success  bool
 }
 
+// ResponseError an error returned by the API
+type ResponseError struct {
+	StatusCode int
+	Messages   []string
+	requestID  string
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("Request failed with HTTP %d", e.StatusCode)
+	}
+	return strings.Join(e.Messages, "\n")
+}
+
+// Code a machine-readable error code derived from the response status
+func (e *ResponseError) Code() string {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	}
+	if e.StatusCode >= 500 {
+		return "server_error"
+	}
+	return "request_failed"
+}
+
+// RequestID the ID the API assigned to the failed request, if any
+func (e *ResponseError) RequestID() string {
+	return e.requestID
+}
+
 // DNS resolver
 var UseCustomDNSResolver = false
 var DNSResolverAddress = "1.1.1.1:53"
@@ -61,6 +105,10 @@ func generateURL(host string, uri string, params []queryParam) (*url.URL, error)
 		return nil, err
 	}
 
+	if strings.EqualFold(url.Scheme, "http") && !AllowPlaintextHTTP {
+		return nil, fmt.Errorf("refusing to send requests to %s over plaintext HTTP, which would expose your Doppler token to anyone able to observe the traffic. Use an https:// API host, or specify --insecure-allow-plaintext-http if you understand the risk", host)
+	}
+
 	values := url.Query()
 	for _, param := range params {
 		values.Add(param.Key, param.Value)
@@ -72,7 +120,7 @@ func generateURL(host string, uri string, params []queryParam) (*url.URL, error)
 
 // GetRequest perform HTTP GET
 func GetRequest(url *url.URL, verifyTLS bool, headers map[string]string) (int, http.Header, []byte, error) {
-	req, err := http.NewRequest("GET", url.String(), nil)
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "GET", url.String(), nil)
 	if err != nil {
 		return 0, nil, nil, err
 	}
@@ -89,9 +137,23 @@ func GetRequest(url *url.URL, verifyTLS bool, headers map[string]string) (int, h
 	return statusCode, respHeaders, body, nil
 }
 
+// GetRequestStream perform HTTP GET, passing the response body to the handler as it's received rather than buffering it
+func GetRequestStream(url *url.URL, verifyTLS bool, headers map[string]string, handler func(io.Reader) error) (int, http.Header, error) {
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "GET", url.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return performStreamingRequest(req, verifyTLS, handler)
+}
+
 // PostRequest perform HTTP POST
 func PostRequest(url *url.URL, verifyTLS bool, headers map[string]string, body []byte) (int, http.Header, []byte, error) {
-	req, err := http.NewRequest("POST", url.String(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "POST", url.String(), bytes.NewReader(body))
 	if err != nil {
 		return 0, nil, nil, err
 	}
@@ -110,7 +172,7 @@ func PostRequest(url *url.URL, verifyTLS bool, headers map[string]string, body [
 
 // PutRequest perform HTTP PUT
 func PutRequest(url *url.URL, verifyTLS bool, headers map[string]string, body []byte) (int, http.Header, []byte, error) {
-	req, err := http.NewRequest("PUT", url.String(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "PUT", url.String(), bytes.NewReader(body))
 	if err != nil {
 		return 0, nil, nil, err
 	}
@@ -129,7 +191,7 @@ func PutRequest(url *url.URL, verifyTLS bool, headers map[string]string, body []
 
 // DeleteRequest perform HTTP DELETE
 func DeleteRequest(url *url.URL, verifyTLS bool, headers map[string]string, body []byte) (int, http.Header, []byte, error) {
-	req, err := http.NewRequest("DELETE", url.String(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(utils.InterruptContext(), "DELETE", url.String(), bytes.NewReader(body))
 	if err != nil {
 		return 0, nil, nil, err
 	}
@@ -152,7 +214,7 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 	req.Header.Set("client-version", version.ProgramVersion)
 	req.Header.Set("client-os", runtime.GOOS)
 	req.Header.Set("client-arch", runtime.GOARCH)
-	req.Header.Set("user-agent", "doppler-go-cli-"+version.ProgramVersion)
+	req.Header.Set("user-agent", UserAgent)
 	if req.Header.Get("Accept") == "" {
 		req.Header.Set("Accept", "application/json")
 	}
@@ -162,14 +224,16 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 	req.Close = true
 
 	client := &http.Client{}
-	// set http timeout
+	// set http timeout. streamed responses may take longer to read than to start receiving, so they're only limited by
+	// the connection and response header timeouts set on the transport
 	if allowTimeout && UseTimeout {
 		client.Timeout = TimeoutDuration
 	}
 
 	// set TLS config
 	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
+		MinVersion:   MinTLSVersion,
+		CipherSuites: TLSCipherSuites,
 	}
 	// #nosec G402
 	if !verifyTLS {
@@ -193,6 +257,9 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 			},
 		}
 	}
+	if UseTimeout {
+		dialer.Timeout = TimeoutDuration
+	}
 	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
 		return dialer.DialContext(ctx, network, addr)
 	}
@@ -207,7 +274,7 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 		utils.LogDebug(fmt.Sprintf("Using proxy %s", proxyUrl))
 	}
 
-	client.Transport = &http.Transport{
+	transport := &http.Transport{
 		// disable keep alives to prevent multiple CLI instances from exhausting the
 		// OS's available network sockets. this adds a negligible performance penalty
 		DisableKeepAlives: true,
@@ -215,6 +282,11 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 		DialContext:       dialContext,
 		Proxy:             http.ProxyURL(proxyUrl),
 	}
+	if UseTimeout {
+		transport.TLSHandshakeTimeout = TimeoutDuration
+		transport.ResponseHeaderTimeout = TimeoutDuration
+	}
+	client.Transport = transport
 
 	utils.LogDebug(fmt.Sprintf("Performing HTTP %s to %s", req.Method, req.URL))
 
@@ -222,7 +294,18 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 	var response *http.Response
 	response = nil
 
+	attempt := 0
 	err = utils.Retry(RequestAttempts, 500*time.Millisecond, func() error {
+		// the previous attempt consumed the request body
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return utils.StopRetryError(err)
+			}
+			req.Body = body
+		}
+		attempt++
+
 		// disable semgrep rule b/c we properly check that resp isn't nil before using it within the err block
 		resp, err := client.Do(req) // nosemgrep: trailofbits.go.invalid-usage-of-modified-variable.invalid-usage-of-modified-variable
 		if err != nil {
@@ -236,7 +319,13 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 
 			utils.LogDebug(err.Error())
 
-			if isTimeout(err) || errors.Is(err, syscall.ECONNREFUSED) {
+			var certErr x509.CertificateInvalidError
+			if errors.As(err, &certErr) && certErr.Reason == x509.Expired {
+				utils.LogWarning("The server's TLS certificate appears to be expired or not yet valid. Please verify that your system clock is correct")
+			}
+
+			// a write that timed out may have been applied, so only requests that weren't sent are retried
+			if isConnectionError(err) || (isIdempotent(req.Method) && isTimeout(err)) {
 				// retry request
 				return err
 			}
@@ -246,6 +335,9 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 
 		response = resp
 
+		checkClockSkew(resp.Header.Get("Date"))
+		reportAPIWarnings(apiWarnings(resp.Header, req.URL.Path, time.Now()))
+
 		if requestID := resp.Header.Get("x-request-id"); requestID != "" {
 			utils.LogDebug(fmt.Sprintf("Request ID %s", requestID))
 		}
@@ -255,7 +347,19 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 		}
 
 		contentType := resp.Header.Get("content-type")
-		if IsRetry(resp.StatusCode, contentType) {
+		// the server may have applied a write before failing, while a rate limited request was rejected before being processed
+		if IsRetry(resp.StatusCode, contentType) && (isIdempotent(req.Method) || resp.StatusCode == http.StatusTooManyRequests) {
+			if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if delay > maxRetryAfter {
+					utils.LogDebug(fmt.Sprintf("Not retrying, as the server requested a delay of %s", delay))
+					return utils.StopRetryError(errors.New("Request failed"))
+				}
+				if delay >= time.Second {
+					utils.Log(fmt.Sprintf("Request failed with HTTP %d, retrying in %s", resp.StatusCode, delay))
+				}
+				return utils.RetryAfterError(errors.New("Request failed"), delay)
+			}
+
 			// start logging retries after 10 seconds so it doesn't feel like we've frozen
 			// we subtract 1 millisecond so that we always win the race against a request that exhausts its full 10 second time out
 			if time.Now().After(startTime.Add(10 * time.Second).Add(-1 * time.Millisecond)) {
@@ -271,6 +375,107 @@ func request(req *http.Request, verifyTLS bool, allowTimeout bool) (*http.Respon
 	return response, err
 }
 
+// checkClockSkew warns if the local clock differs significantly from the server's Date header.
+// A skewed clock is a common cause of TLS and authentication failures on freshly provisioned machines.
+func checkClockSkew(date string) {
+	if date == "" {
+		return
+	}
+
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		utils.LogDebug(fmt.Sprintf("Unable to parse Date header %q", date))
+		return
+	}
+
+	skew := time.Since(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > MaxClockSkew {
+		clockSkewWarning.Do(func() {
+			utils.LogWarning(fmt.Sprintf("Your system clock appears to be off by %s; this may cause auth failures", skew.Round(time.Second)))
+		})
+	}
+}
+
+type apiWarning struct {
+	message string
+	// whether the warning concerns breakage that's imminent (or has already occurred)
+	imminent bool
+}
+
+// apiWarnings parses the Deprecation, Sunset, and Warning response headers
+func apiWarnings(headers http.Header, path string, now time.Time) []apiWarning {
+	var warnings []apiWarning
+
+	if deprecation := headers.Get("Deprecation"); deprecation != "" {
+		message := fmt.Sprintf("The API endpoint %s is deprecated", path)
+		if date, ok := parseHeaderDate(deprecation); ok {
+			message = fmt.Sprintf("The API endpoint %s is deprecated as of %s", path, date.Format("2006-01-02"))
+		}
+		warnings = append(warnings, apiWarning{message: message + ". Please update the Doppler CLI"})
+	}
+
+	if sunset := headers.Get("Sunset"); sunset != "" {
+		if date, ok := parseHeaderDate(sunset); ok {
+			warnings = append(warnings, apiWarning{
+				message:  fmt.Sprintf("The API endpoint %s will be removed on %s. Please update the Doppler CLI", path, date.Format("2006-01-02")),
+				imminent: date.Sub(now) < SunsetWarningPeriod,
+			})
+		} else {
+			utils.LogDebug(fmt.Sprintf("Unable to parse Sunset header %q", sunset))
+		}
+	}
+
+	for _, warning := range headers.Values("Warning") {
+		// warnings have the form: code agent "text" [date]
+		message := warning
+		if start := strings.Index(warning, `"`); start != -1 {
+			if end := strings.Index(warning[start+1:], `"`); end != -1 {
+				message = warning[start+1 : start+1+end]
+			}
+		}
+		warnings = append(warnings, apiWarning{message: message})
+	}
+
+	return warnings
+}
+
+// parseHeaderDate parses an HTTP date, or a structured field date (e.g. @1688169599)
+func parseHeaderDate(value string) (time.Time, bool) {
+	if strings.HasPrefix(value, "@") {
+		seconds, err := strconv.ParseInt(value[1:], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(seconds, 0).UTC(), true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// reportAPIWarnings prints each warning once per run. Warnings about imminent breakage are printed even when --silent is specified.
+func reportAPIWarnings(warnings []apiWarning) {
+	reportedAPIWarningsMutex.Lock()
+	defer reportedAPIWarningsMutex.Unlock()
+
+	for _, warning := range warnings {
+		if reportedAPIWarnings[warning.message] {
+			continue
+		}
+		if !warning.imminent && utils.Silent {
+			continue
+		}
+		reportedAPIWarnings[warning.message] = true
+		utils.LogWarning(warning.message)
+	}
+}
+
 func performSSERequest(req *http.Request, verifyTLS bool, handler func([]byte)) (int, http.Header, error) {
 	response, requestErr := request(req, verifyTLS, false)
 	if requestErr != nil {
@@ -336,19 +541,58 @@ func performRequest(req *http.Request, verifyTLS bool) (int, http.Header, []byte
 		return response.StatusCode, headers, body, nil
 	}
 
+	errBody, err := parseErrorResponse(response, body)
+	return response.StatusCode, headers, errBody, err
+}
+
+// parseErrorResponse converts a failed response into an error containing the response body's error messages.
+// The body is returned if it was successfully parsed.
+func parseErrorResponse(response *http.Response, body []byte) ([]byte, error) {
+	requestID := response.Header.Get("x-request-id")
+
 	// print the response body error messages
 	if contentType := response.Header.Get("content-type"); strings.HasPrefix(contentType, "application/json") {
 		var errResponse errorResponse
-		err = json.Unmarshal(body, &errResponse)
+		err := json.Unmarshal(body, &errResponse)
 		if err != nil {
 			utils.LogDebug(fmt.Sprintf("Unable to parse response body: \n%s", string(body)))
-			return response.StatusCode, headers, nil, err
+			return nil, err
 		}
 
-		return response.StatusCode, headers, body, errors.New(strings.Join(errResponse.Messages, "\n"))
+		return body, &ResponseError{StatusCode: response.StatusCode, Messages: errResponse.Messages, requestID: requestID}
 	}
 
-	return response.StatusCode, headers, nil, fmt.Errorf("Request failed with HTTP %d", response.StatusCode)
+	return nil, &ResponseError{StatusCode: response.StatusCode, requestID: requestID}
+}
+
+func performStreamingRequest(req *http.Request, verifyTLS bool, handler func(io.Reader) error) (int, http.Header, error) {
+	response, requestErr := request(req, verifyTLS, false)
+	if response != nil {
+		defer func() {
+			if closeErr := response.Body.Close(); closeErr != nil {
+				utils.LogDebug(closeErr.Error())
+			}
+		}()
+	}
+
+	if requestErr != nil && response == nil {
+		return 0, nil, requestErr
+	}
+
+	headers := response.Header.Clone()
+
+	// success
+	if requestErr == nil {
+		return response.StatusCode, headers, handler(response.Body)
+	}
+
+	// error responses are small, so read them in full
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, nil, err
+	}
+	_, err = parseErrorResponse(response, body)
+	return response.StatusCode, headers, err
 }
 
 func isSuccess(statusCode int) bool {
@@ -362,6 +606,49 @@ func IsRetry(statusCode int, contentType string) bool {
 		(statusCode >= 500 && statusCode <= 599 && !strings.HasPrefix(contentType, "application/json"))
 }
 
+// isIdempotent returns whether a request with the method can be retried after the server received it, without risking
+// applying a change twice
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isConnectionError returns whether the request failed before it was sent, i.e. while connecting to the server
+func isConnectionError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout()
+}
+
+// maxRetryAfter the longest Retry-After delay that's waited for. longer delays fail the request rather than hanging
+const maxRetryAfter = time.Minute
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		utils.LogDebug(fmt.Sprintf("Unable to parse Retry-After header %q", header))
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
 func isTimeout(err error) bool {
 	if urlErr, ok := err.(*url.Error); ok {
 		if netErr, ok := urlErr.Err.(net.Error); ok {
@@ -0,0 +1,333 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package http
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/DopplerHQ/cli/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultUserAgent(t *testing.T) {
+	assert.Equal(t, "doppler-cli/"+version.ProgramVersion+" ("+runtime.GOOS+"; "+runtime.GOARCH+")", DefaultUserAgent())
+}
+
+func TestUserAgentHeader(t *testing.T) {
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("user-agent"))
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	originalUserAgent := UserAgent
+	defer func() { UserAgent = originalUserAgent }()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	_, _, _, err = GetRequest(u, true, nil)
+	assert.NoError(t, err)
+	_, _, _, err = PostRequest(u, true, nil, []byte("{}"))
+	assert.NoError(t, err)
+
+	UserAgent = "custom-agent/1.0"
+	_, _, _, err = DeleteRequest(u, true, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{DefaultUserAgent(), DefaultUserAgent(), "custom-agent/1.0"}, userAgents)
+}
+
+func TestParseListMeta(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("x-request-id", "req-123")
+
+	meta := parseListMeta(headers, map[string]interface{}{"page": float64(2), "total": float64(42)}, 1)
+	assert.Equal(t, "req-123", meta.RequestID)
+	assert.Equal(t, 2, meta.Page)
+	if assert.NotNil(t, meta.Total) {
+		assert.Equal(t, 42, *meta.Total)
+	}
+
+	// fall back to the requested page, and omit the total when the API doesn't report it
+	meta = parseListMeta(http.Header{}, map[string]interface{}{}, 3)
+	assert.Equal(t, models.ListMeta{Page: 3}, meta)
+
+	meta = parseListMeta(http.Header{}, map[string]interface{}{}, 0)
+	assert.Equal(t, models.ListMeta{Page: 1}, meta)
+}
+
+func TestAPIWarnings(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	headers := http.Header{}
+	assert.Empty(t, apiWarnings(headers, "/v3/configs", now))
+
+	headers.Set("Deprecation", "true")
+	headers.Set("Sunset", "Sat, 01 Mar 2025 00:00:00 GMT")
+	headers.Add("Warning", `299 - "Parameter 'environment' is deprecated"`)
+	headers.Add("Warning", `malformed warning`)
+	assert.Equal(t, []apiWarning{
+		{message: "The API endpoint /v3/configs is deprecated. Please update the Doppler CLI"},
+		{message: "The API endpoint /v3/configs will be removed on 2025-03-01. Please update the Doppler CLI"},
+		{message: "Parameter 'environment' is deprecated"},
+		{message: "malformed warning"},
+	}, apiWarnings(headers, "/v3/configs", now))
+
+	// sunsets within the warning period are imminent
+	headers = http.Header{}
+	headers.Set("Deprecation", "@1704067200")
+	headers.Set("Sunset", "Mon, 10 Jun 2024 00:00:00 GMT")
+	assert.Equal(t, []apiWarning{
+		{message: "The API endpoint /v3/configs is deprecated as of 2024-01-01. Please update the Doppler CLI"},
+		{message: "The API endpoint /v3/configs will be removed on 2024-06-10. Please update the Doppler CLI", imminent: true},
+	}, apiWarnings(headers, "/v3/configs", now))
+
+	// unparseable sunset dates are ignored
+	headers = http.Header{}
+	headers.Set("Sunset", "soon")
+	assert.Empty(t, apiWarnings(headers, "/v3/configs", now))
+}
+
+func TestGenerateURLPlaintextHTTP(t *testing.T) {
+	originalAllowPlaintextHTTP := AllowPlaintextHTTP
+	defer func() { AllowPlaintextHTTP = originalAllowPlaintextHTTP }()
+
+	AllowPlaintextHTTP = false
+	u, err := generateURL("https://api.doppler.com/", "v3/configs", []queryParam{{Key: "project", Value: "backend"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.doppler.com/v3/configs?project=backend", u.String())
+
+	_, err = generateURL("http://doppler.internal", "/v3/configs", nil)
+	assert.ErrorContains(t, err, "--insecure-allow-plaintext-http")
+	_, err = generateURL("HTTP://doppler.internal", "/v3/configs", nil)
+	assert.Error(t, err)
+
+	AllowPlaintextHTTP = true
+	u, err = generateURL("http://doppler.internal", "/v3/configs", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://doppler.internal/v3/configs", u.String())
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	version, err := ParseTLSVersion("1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), version)
+
+	version, err = ParseTLSVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), version)
+
+	for _, invalid := range []string{"", "1.1", "1.0", "TLS1.3", "1.4"} {
+		_, err = ParseTLSVersion(invalid)
+		assert.ErrorContains(t, err, "Supported versions are 1.2, 1.3", invalid)
+	}
+}
+
+func TestParseTLSCipherSuites(t *testing.T) {
+	ids, err := ParseTLSCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "tls_ecdhe_rsa_with_aes_128_gcm_sha256"})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, ids)
+
+	// TLS 1.3 cipher suites aren't configurable
+	_, err = ParseTLSCipherSuites([]string{"TLS_AES_128_GCM_SHA256"})
+	assert.Error(t, err)
+	// insecure cipher suites are rejected
+	_, err = ParseTLSCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	assert.Error(t, err)
+	_, err = ParseTLSCipherSuites([]string{"NOT_A_CIPHER"})
+	assert.Error(t, err)
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	originalMinTLSVersion := MinTLSVersion
+	originalRequestAttempts := RequestAttempts
+	defer func() {
+		MinTLSVersion = originalMinTLSVersion
+		RequestAttempts = originalRequestAttempts
+	}()
+	RequestAttempts = 1
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	MinTLSVersion = tls.VersionTLS12
+	statusCode, _, _, err := GetRequest(u, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	// the server doesn't support TLS 1.3
+	MinTLSVersion = tls.VersionTLS13
+	_, _, _, err = GetRequest(u, false, nil)
+	assert.Error(t, err)
+}
+
+func TestUpdateConfigBody(t *testing.T) {
+	originalAllowPlaintextHTTP := AllowPlaintextHTTP
+	defer func() { AllowPlaintextHTTP = originalAllowPlaintextHTTP }()
+	AllowPlaintextHTTP = true
+
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"config":{"name":"dev_jane","description":"Jane's sandbox","project":"backend"}}`)) // #nosec G104
+	}))
+	defer server.Close()
+
+	info, err := UpdateConfig(server.URL, true, "dp.st.test", "backend", "dev_personal", "dev_jane", nil)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, map[string]interface{}{"name": "dev_jane"}, body)
+	assert.Equal(t, "Jane's sandbox", info.Description)
+
+	description := "Jane's sandbox"
+	_, err = UpdateConfig(server.URL, true, "dp.st.test", "backend", "dev_personal", "", &description)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, map[string]interface{}{"description": "Jane's sandbox"}, body)
+
+	// an empty description clears it
+	description = ""
+	_, err = UpdateConfig(server.URL, true, "dp.st.test", "backend", "dev_personal", "dev_jane", &description)
+	assert.True(t, err.IsNil())
+	assert.Equal(t, map[string]interface{}{"name": "dev_jane", "description": ""}, body)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = parseRetryAfter("Tue, 02 Jan 2024 15:04:35 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	// a date in the past means the request can be retried immediately
+	delay, ok = parseRetryAfter("Tue, 02 Jan 2024 15:00:00 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	for _, header := range []string{"", "-1", "soon"} {
+		_, ok = parseRetryAfter(header, now)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	originalRequestAttempts := RequestAttempts
+	defer func() { RequestAttempts = originalRequestAttempts }()
+	RequestAttempts = 3
+
+	var requests int
+	var bodies []string
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	// reads are retried on server errors
+	statusCode, _, _, err := GetRequest(u, false, nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, 3, requests)
+
+	// writes aren't, since the server may have applied them
+	requests = 0
+	_, _, _, err = PostRequest(u, false, nil, []byte(`{"name":"dev"}`))
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+
+	// rate limited writes weren't processed, so they're retried with their body
+	requests = 0
+	bodies = nil
+	status = http.StatusTooManyRequests
+	_, _, _, err = PostRequest(u, false, nil, []byte(`{"name":"dev"}`))
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, []string{`{"name":"dev"}`, `{"name":"dev"}`, `{"name":"dev"}`}, bodies)
+}
+
+func TestStreamingRequestTimeout(t *testing.T) {
+	originalTimeoutDuration, originalRequestAttempts := TimeoutDuration, RequestAttempts
+	defer func() { TimeoutDuration, RequestAttempts = originalTimeoutDuration, originalRequestAttempts }()
+	TimeoutDuration = 100 * time.Millisecond
+	RequestAttempts = 1
+
+	headerDelay := time.Duration(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(headerDelay)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// the body takes longer to send than the timeout
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk%d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(TimeoutDuration)
+		}
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	// streamed bodies aren't limited by the timeout
+	var body []byte
+	_, _, err = GetRequestStream(u, false, nil, func(r io.Reader) error {
+		body, err = io.ReadAll(r)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "chunk0\nchunk1\nchunk2\n", string(body))
+
+	// buffered requests are
+	_, _, _, err = GetRequest(u, false, nil)
+	assert.Error(t, err)
+
+	// but a response that doesn't start in time still times out
+	headerDelay = 3 * TimeoutDuration
+	_, _, err = GetRequestStream(u, false, nil, func(r io.Reader) error {
+		_, err := io.ReadAll(r)
+		return err
+	})
+	assert.Error(t, err)
+}
@@ -23,8 +23,20 @@ type ComputedSecret struct {
 	RawVisibility      string  `json:"rawVisibility"`
 	ComputedVisibility string  `json:"computedVisibility"`
 	Note               string  `json:"note"`
+	// whether the secret is inherited from the environment's root config, only populated when requested
+	Source string `json:"source,omitempty"`
 }
 
+// the sources of a ComputedSecret
+const (
+	// the secret has the same value as in the environment's root config
+	SecretSourceDefault = "default"
+	// the secret is in the environment's root config, but with a different value
+	SecretSourceOverridden = "overridden"
+	// the secret isn't in the environment's root config
+	SecretSourceCustom = "custom"
+)
+
 // ChangeRequest can be used to smartly update secrets
 type ChangeRequest struct {
 	OriginalName  interface{} `json:"originalName"`
@@ -67,6 +79,7 @@ type EnvironmentInfo struct {
 // ConfigInfo project info
 type ConfigInfo struct {
 	Name           string `json:"name"`
+	Description    string `json:"description,omitempty"`
 	Root           bool   `json:"root"`
 	Locked         bool   `json:"locked"`
 	Environment    string `json:"environment"`
@@ -74,6 +87,44 @@ type ConfigInfo struct {
 	CreatedAt      string `json:"created_at"`
 	InitialFetchAt string `json:"initial_fetch_at"`
 	LastFetchAt    string `json:"last_fetch_at"`
+	// the number of secrets in the config, only populated when requested
+	SecretCount *int `json:"secret_count,omitempty"`
+	// the user who made the config's most recent change, only populated when requested
+	LastModifiedBy *string `json:"last_modified_by,omitempty"`
+}
+
+// ListMeta metadata about a page of list results
+type ListMeta struct {
+	RequestID string `json:"requestId,omitempty"`
+	// the ETag of the response, which identifies this version of the results
+	ETag string `json:"etag,omitempty"`
+	Page int    `json:"page"`
+	// the total number of results across all pages, when reported by the API
+	Total *int `json:"total,omitempty"`
+}
+
+// JSONEnvelope wraps JSON output with metadata
+type JSONEnvelope struct {
+	Data interface{} `json:"data"`
+	Meta ListMeta    `json:"meta"`
+}
+
+// the kinds of ConfigSecretDiff
+const (
+	SecretAdded   = "added"
+	SecretChanged = "changed"
+	SecretRemoved = "removed"
+	// the secret already has the value being set
+	SecretUnchanged = "unchanged"
+)
+
+// ConfigSecretDiff a secret that differs between two configs, or between a config and the values being set
+type ConfigSecretDiff struct {
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	// the values are only populated when revealing them
+	From *string `json:"from,omitempty"`
+	To   *string `json:"to,omitempty"`
 }
 
 // ConfigLog a log
@@ -89,6 +140,32 @@ type ConfigLog struct {
 	Diff        []LogDiff `json:"diff"`
 }
 
+// AuditEvent a config log in the format exported for ingestion by other systems
+type AuditEvent struct {
+	ID          string           `json:"id"`
+	Timestamp   string           `json:"timestamp"`
+	Actor       AuditActor       `json:"actor"`
+	Action      string           `json:"action"`
+	Project     string           `json:"project"`
+	Environment string           `json:"environment"`
+	Config      string           `json:"config"`
+	Message     string           `json:"message"`
+	Diff        AuditDiffSummary `json:"diff"`
+}
+
+// AuditActor the user who made a change
+type AuditActor struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// AuditDiffSummary the names of the secrets affected by a change. Values are never included.
+type AuditDiffSummary struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
 // ActivityLog an activity log
 type ActivityLog struct {
 	ID                 string `json:"id"`
@@ -116,6 +193,16 @@ type LogDiff struct {
 	Removed string `json:"removed"`
 }
 
+// SecretChange a change to a single secret, as recorded in a config log
+type SecretChange struct {
+	Log       string `json:"log"`
+	CreatedAt string `json:"created_at"`
+	User      User   `json:"user"`
+	Text      string `json:"text"`
+	Previous  string `json:"previous"`
+	Current   string `json:"current"`
+}
+
 // ConfigServiceToken a service token
 type ConfigServiceToken struct {
 	Name        string `json:"name"`
@@ -161,3 +248,19 @@ type ActorWorkplaceInfo struct {
 type WatchSecrets struct {
 	Type string `json:"type"`
 }
+
+// the statuses of a DoctorCheck
+const (
+	DoctorCheckPass = "pass"
+	DoctorCheckWarn = "warn"
+	DoctorCheckFail = "fail"
+	// the check couldn't be performed because an earlier check failed
+	DoctorCheckSkip = "skip"
+)
+
+// DoctorCheck the result of one of the diagnostics run by 'doppler doctor'
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
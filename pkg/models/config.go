@@ -21,36 +21,43 @@ import (
 
 // ConfigFile structure of the config file
 type ConfigFile struct {
-	Scoped       map[string]FileScopedOptions `yaml:"scoped"`
-	VersionCheck VersionCheck                 `yaml:"version-check"`
-	Analytics    AnalyticsOptions             `yaml:"analytics"`
-	TUI          TUIOptions                   `yaml:"tui"`
+	Scoped       map[string]FileScopedOptions `json:"scoped" toml:"scoped" yaml:"scoped"`
+	VersionCheck VersionCheck                 `json:"version-check" toml:"version-check" yaml:"version-check"`
+	Analytics    AnalyticsOptions             `json:"analytics" toml:"analytics" yaml:"analytics"`
+	TUI          TUIOptions                   `json:"tui" toml:"tui" yaml:"tui"`
+	HostPresets  map[string]HostPreset        `json:"host-presets,omitempty" toml:"host-presets,omitempty" yaml:"host-presets,omitempty"`
+}
+
+// HostPreset a named pair of API and dashboard hosts, e.g. for a self-hosted deployment
+type HostPreset struct {
+	APIHost       string `json:"api-host" toml:"api-host" yaml:"api-host"`
+	DashboardHost string `json:"dashboard-host,omitempty" toml:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
 }
 
 // FileScopedOptions config options
 type FileScopedOptions struct {
-	Token          string `json:"token,omitempty" yaml:"token,omitempty"`
-	APIHost        string `json:"api-host,omitempty" yaml:"api-host,omitempty"`
-	DashboardHost  string `json:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
-	VerifyTLS      string `json:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
-	EnclaveProject string `json:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
-	EnclaveConfig  string `json:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
+	Token          string `json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty"`
+	APIHost        string `json:"api-host,omitempty" toml:"api-host,omitempty" yaml:"api-host,omitempty"`
+	DashboardHost  string `json:"dashboard-host,omitempty" toml:"dashboard-host,omitempty" yaml:"dashboard-host,omitempty"`
+	VerifyTLS      string `json:"verify-tls,omitempty" toml:"verify-tls,omitempty" yaml:"verify-tls,omitempty"`
+	EnclaveProject string `json:"enclave.project,omitempty" toml:"enclave.project,omitempty" yaml:"enclave.project,omitempty"`
+	EnclaveConfig  string `json:"enclave.config,omitempty" toml:"enclave.config,omitempty" yaml:"enclave.config,omitempty"`
 }
 
 // VersionCheck info about the last check for the latest cli version
 type VersionCheck struct {
-	LatestVersion string    `yaml:"latest-version,omitempty"`
-	CheckedAt     time.Time `yaml:"checked-at,omitempty"`
+	LatestVersion string    `json:"latest-version,omitempty" toml:"latest-version,omitempty" yaml:"latest-version,omitempty"`
+	CheckedAt     time.Time `json:"checked-at,omitempty" toml:"checked-at,omitempty" yaml:"checked-at,omitempty"`
 }
 
 type AnalyticsOptions struct {
 	// we use the key 'disable' rather than 'enable' because blank value are automatically parsed as 'false',
 	// and we want this feature to be enabled by default
-	Disable bool `yaml:"disable"`
+	Disable bool `json:"disable" toml:"disable" yaml:"disable"`
 }
 
 type TUIOptions struct {
-	IntroVersionSeen int `yaml:"introVersionSeen"`
+	IntroVersionSeen int `json:"introVersionSeen" toml:"introVersionSeen" yaml:"introVersionSeen"`
 }
 
 // ScopedOptions options with their scope
@@ -78,10 +85,11 @@ const (
 	ConfigFileSource
 	EnvironmentSource
 	DefaultValueSource
+	ProjectFileSource
 )
 
 func (s source) String() string {
-	return [...]string{"Flag", "Config File", "Environment", "Default Value"}[s]
+	return [...]string{"Flag", "Config File", "Environment", "Default Value", "Project File"}[s]
 }
 
 var allConfigOptions = []string{
@@ -90,6 +90,9 @@ func ParseConfigInfo(info map[string]interface{}) ConfigInfo {
 	if info["name"] != nil {
 		configInfo.Name = info["name"].(string)
 	}
+	if description, ok := info["description"].(string); ok {
+		configInfo.Description = description
+	}
 	if info["root"] != nil {
 		configInfo.Root = info["root"].(bool)
 	}
@@ -111,6 +114,13 @@ func ParseConfigInfo(info map[string]interface{}) ConfigInfo {
 	if info["last_fetch_at"] != nil {
 		configInfo.LastFetchAt = info["last_fetch_at"].(string)
 	}
+	if count, ok := info["secret_count"].(float64); ok {
+		secretCount := int(count)
+		configInfo.SecretCount = &secretCount
+	}
+	if lastModifiedBy, ok := info["last_modified_by"].(string); ok {
+		configInfo.LastModifiedBy = &lastModifiedBy
+	}
 
 	return configInfo
 }
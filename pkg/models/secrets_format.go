@@ -26,9 +26,15 @@ const (
 	YAML
 	DOCKER
 	ENV_NO_QUOTES
+	SYSTEMD
+	NGINX
+	SHELL
+	KUBERNETES
+	TFVARS
+	TFVARS_JSON
 )
 
-var SecretFormats = []string{"json", "dotnet-json", "env", "yaml", "docker", "env-no-quotes"}
+var SecretFormats = []string{"json", "dotnet-json", "env", "yaml", "docker", "env-no-quotes", "systemd", "nginx", "shell", "k8s", "tfvars", "tfvars-json"}
 
 func (s SecretsFormat) String() string {
 	return SecretFormats[s]
@@ -36,7 +42,12 @@ func (s SecretsFormat) String() string {
 
 // OutputFile the default secrets file name
 func (s SecretsFormat) OutputFile() string {
-	return [...]string{"doppler.json", "appsettings.json", "doppler.env", "secrets.yaml", "doppler.env", "doppler.env"}[s]
+	return [...]string{"doppler.json", "appsettings.json", "doppler.env", "secrets.yaml", "doppler.env", "doppler.env", "doppler.conf", "doppler-nginx.conf", "doppler.sh", "doppler-secret.yaml", "doppler.auto.tfvars", "doppler.auto.tfvars.json"}[s]
+}
+
+// ClientRendered whether the format is rendered by the CLI from the JSON secrets, rather than by the API
+func (s SecretsFormat) ClientRendered() bool {
+	return s == ENV || s == DOCKER || s == SYSTEMD || s == NGINX || s == SHELL || s == KUBERNETES || s == TFVARS || s == TFVARS_JSON
 }
 
 // SecretsFormatList list of supported secrets formats
@@ -49,4 +60,10 @@ func init() {
 	SecretsFormatList = append(SecretsFormatList, YAML)
 	SecretsFormatList = append(SecretsFormatList, DOCKER)
 	SecretsFormatList = append(SecretsFormatList, ENV_NO_QUOTES)
+	SecretsFormatList = append(SecretsFormatList, SYSTEMD)
+	SecretsFormatList = append(SecretsFormatList, NGINX)
+	SecretsFormatList = append(SecretsFormatList, SHELL)
+	SecretsFormatList = append(SecretsFormatList, KUBERNETES)
+	SecretsFormatList = append(SecretsFormatList, TFVARS)
+	SecretsFormatList = append(SecretsFormatList, TFVARS_JSON)
 }
@@ -16,7 +16,6 @@ limitations under the License.
 package printer
 
 import (
-	"fmt"
 	"sort"
 	"strings"
 
@@ -30,7 +29,23 @@ func ScopedConfig(conf models.ScopedOptions, jsonFlag bool) {
 	ScopedConfigSource(conf, jsonFlag, false, true)
 }
 
-// ScopedConfigSource print scoped config with source
+// ScopedConfigSources print each option's value, scope, and source as JSON
+func ScopedConfigSources(conf models.ScopedOptions, obfuscateToken bool) {
+	sourceMap := map[string]models.ScopedOption{}
+	for name, pair := range models.ScopedOptionsMap(&conf) {
+		if *pair != (models.ScopedOption{}) {
+			option := *pair
+			if obfuscateToken && name == models.ConfigToken.String() {
+				option.Value = utils.RedactAuthToken(option.Value)
+			}
+			sourceMap[name] = option
+		}
+	}
+
+	JSON(sourceMap)
+}
+
+// ScopedConfigSource print scoped config with source. JSON output is grouped by scope and doesn't include the source
 func ScopedConfigSource(conf models.ScopedOptions, jsonFlag bool, source bool, obfuscateToken bool) {
 	pairs := models.ScopedOptionsMap(&conf)
 
@@ -41,6 +56,9 @@ func ScopedConfigSource(conf models.ScopedOptions, jsonFlag bool, source bool, o
 			if *pair != (models.ScopedOption{}) {
 				scope := pair.Scope
 				value := pair.Value
+				if obfuscateToken && name == models.ConfigToken.String() {
+					value = utils.RedactAuthToken(value)
+				}
 
 				if confMap[scope] == nil {
 					confMap[scope] = map[string]string{}
@@ -104,7 +122,7 @@ func ScopedConfigValues(conf models.ScopedOptions, args []string, values map[str
 		}
 
 		if plain {
-			fmt.Println(print)
+			utils.Print(print)
 			return
 		}
 	}
@@ -161,6 +179,24 @@ func Configs(configs map[string]models.FileScopedOptions, jsonFlag bool) {
 	Table([]string{"name", "value", "scope"}, rows, TableOptions())
 }
 
+// HostPresets print the saved host presets, sorted by name
+func HostPresets(presets map[string]models.HostPreset, jsonFlag bool) {
+	if jsonFlag {
+		JSON(presets)
+		return
+	}
+
+	var rows [][]string
+	for name, preset := range presets {
+		rows = append(rows, []string{name, preset.APIHost, preset.DashboardHost})
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		return rows[a][0] < rows[b][0]
+	})
+
+	Table([]string{"name", "api host", "dashboard host"}, rows, TableOptions())
+}
+
 // ConfigOptionNames prints all supported config options
 func ConfigOptionNames(options []string, jsonFlag bool) {
 	if jsonFlag {
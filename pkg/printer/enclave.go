@@ -16,10 +16,12 @@ limitations under the License.
 package printer
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -52,32 +54,73 @@ func ConfigLog(log models.ConfigLog, jsonFlag bool, diff bool) {
 
 	dateTime, err := time.Parse(time.RFC3339, log.CreatedAt)
 
-	fmt.Println("Log " + log.ID)
-	fmt.Println("User: " + log.User.Name + " <" + log.User.Email + ">")
+	utils.Print("Log " + log.ID)
+	utils.Print("User: " + log.User.Name + " <" + log.User.Email + ">")
 	if err == nil {
-		fmt.Println("Date: " + dateTime.In(time.Local).String())
+		utils.Print("Date: " + dateTime.In(utils.TimeZone).String())
 	}
-	fmt.Println("")
-	fmt.Println("\t" + log.Text)
-	fmt.Println("")
+	utils.Print("")
+	utils.Print("\t" + log.Text)
 
 	if diff && len(log.Diff) > 0 {
-		fmt.Println("")
-
-		for i, logDiff := range log.Diff {
-			if i != 0 {
-				fmt.Println("")
-			}
+		utils.Print("")
 
+		for _, logDiff := range log.Diff {
+			// changes that aren't to a secret (e.g. renaming the config) only have before and after text
 			if logDiff.Name == "" {
-				color.Red.Println(logDiff.Removed)
-				color.Green.Println(logDiff.Added)
-			} else {
-				color.Red.Println("-", logDiff.Name, "=", logDiff.Removed)
-				color.Green.Println("+", logDiff.Name, "=", logDiff.Added)
+				if logDiff.Removed != "" {
+					utils.Print(color.Red.Render("- " + logDiff.Removed))
+				}
+				if logDiff.Added != "" {
+					utils.Print(color.Green.Render("+ " + logDiff.Added))
+				}
+				continue
 			}
+
+			printSecretChange(configLogChange(logDiff))
+		}
+	}
+	utils.Print("")
+}
+
+// configLogChange converts an entry of a log's diff to a change, classifying it the same way as exported audit events
+func configLogChange(logDiff models.LogDiff) models.ConfigSecretDiff {
+	change := models.ConfigSecretDiff{Name: logDiff.Name}
+	removed, added := logDiff.Removed, logDiff.Added
+	switch {
+	case removed == "" && added != "":
+		change.Change = models.SecretAdded
+		change.To = &added
+	case added == "" && removed != "":
+		change.Change = models.SecretRemoved
+		change.From = &removed
+	default:
+		change.Change = models.SecretChanged
+		change.From = &removed
+		change.To = &added
+	}
+	return change
+}
+
+// SecretHistory print the changes to a secret
+func SecretHistory(changes []models.SecretChange, jsonFlag bool) {
+	if jsonFlag {
+		JSON(changes)
+		return
+	}
+
+	formatValue := func(value string) string {
+		if value == "" {
+			return "(none)"
 		}
+		return value
+	}
+
+	var rows [][]string
+	for _, change := range changes {
+		rows = append(rows, []string{change.Log, timestamp(change.CreatedAt), change.User.Email, formatValue(change.Previous) + " → " + formatValue(change.Current)})
 	}
+	Table([]string{"log", "created at", "user", "change"}, rows, TableOptions())
 }
 
 // ActivityLogs print activity logs
@@ -104,14 +147,14 @@ func ActivityLog(log models.ActivityLog, jsonFlag bool, diff bool) {
 
 	dateTime, err := time.Parse(time.RFC3339, log.CreatedAt)
 
-	fmt.Println("Log " + log.ID)
-	fmt.Println("User: " + log.User.Name + " <" + log.User.Email + ">")
+	utils.Print("Log " + log.ID)
+	utils.Print("User: " + log.User.Name + " <" + log.User.Email + ">")
 	if err == nil {
-		fmt.Println("Date: " + dateTime.In(time.Local).String())
+		utils.Print("Date: " + dateTime.In(utils.TimeZone).String())
 	}
-	fmt.Println("")
-	fmt.Println("\t" + log.Text)
-	fmt.Println("")
+	utils.Print("")
+	utils.Print("\t" + log.Text)
+	utils.Print("")
 }
 
 // JSON print object as json
@@ -121,7 +164,12 @@ func JSON(structure interface{}) {
 		utils.HandleError(err)
 	}
 
-	fmt.Println(string(resp))
+	utils.Print(string(resp))
+}
+
+// Envelope print JSON data wrapped with its metadata
+func Envelope(data interface{}, meta models.ListMeta) {
+	JSON(models.JSONEnvelope{Data: data, Meta: meta})
 }
 
 // ConfigInfo print config
@@ -131,23 +179,259 @@ func ConfigInfo(info models.ConfigInfo, jsonFlag bool) {
 		return
 	}
 
-	rows := [][]string{{info.Name, info.InitialFetchAt, info.LastFetchAt, info.CreatedAt, info.Environment, info.Project}}
-	Table([]string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows, TableOptions())
+	headers := []string{"name", "initial fetch", "last fetch", "created at", "environment", "project", "locked"}
+	row := []string{info.Name, timestamp(info.InitialFetchAt), timestamp(info.LastFetchAt), timestamp(info.CreatedAt), info.Environment, info.Project,
+		strconv.FormatBool(info.Locked)}
+	// only show the description column when the config has one
+	if info.Description != "" {
+		headers = append(headers, "description")
+		row = append(row, info.Description)
+	}
+	Table(headers, [][]string{row}, TableOptions())
+}
+
+// the formats in which configs can be printed
+const (
+	TableConfigsFormat = "table"
+	JSONConfigsFormat  = "json"
+	CSVConfigsFormat   = "csv"
+)
+
+// ConfigsFormats the formats in which configs can be printed
+var ConfigsFormats = []string{TableConfigsFormat, JSONConfigsFormat, CSVConfigsFormat}
+
+// ConfigsCSV print configs as RFC 4180 CSV with a header row. The columns match ConfigsInfo's table, while timestamps
+// are printed as returned by the API so that they can be parsed by other tools.
+func ConfigsCSV(info []models.ConfigInfo) {
+	out, err := FormatConfigsCSV(info)
+	if err != nil {
+		utils.HandleError(err, "Unable to format configs as CSV")
+	}
+	utils.PrintRaw(out)
+}
+
+// FormatConfigsCSV formats configs as RFC 4180 CSV with a header row
+func FormatConfigsCSV(info []models.ConfigInfo) (string, error) {
+	columns := configsColumns(info)
+
+	var out strings.Builder
+	w := csv.NewWriter(&out)
+	w.UseCRLF = true
+	var headers []string
+	for _, header := range columns.headers() {
+		headers = append(headers, strings.ReplaceAll(header, " ", "_"))
+	}
+	if err := w.Write(headers); err != nil {
+		return "", err
+	}
+	for _, configInfo := range info {
+		if err := w.Write(columns.row(configInfo, func(t string) string { return t })); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return out.String(), w.Error()
 }
 
-// ConfigsInfo print configs
-func ConfigsInfo(info []models.ConfigInfo, jsonFlag bool) {
+// ConfigsInfo print configs, followed by a summary line when summary is true
+func ConfigsInfo(info []models.ConfigInfo, jsonFlag bool, summary bool) {
 	if jsonFlag {
 		JSON(info)
 		return
 	}
 
+	columns := configsColumns(info)
 	var rows [][]string
 	for _, configInfo := range info {
-		rows = append(rows, []string{configInfo.Name, configInfo.InitialFetchAt, configInfo.LastFetchAt, configInfo.CreatedAt,
-			configInfo.Environment, configInfo.Project})
+		rows = append(rows, columns.row(configInfo, timestamp))
+	}
+
+	headers := columns.headers()
+	options := TableOptions()
+	if columns.counts {
+		// the secrets column immediately follows the columns that are always included
+		options.RightAlignColumns = []int{len(configColumns{}.headers()) + 1}
+	}
+	Table(headers, rows, options)
+
+	if summary && len(info) > 0 {
+		utils.Print(ConfigsSummary(info))
+	}
+}
+
+// ConfigsPlain print configs as tab-separated rows without a header, in the same column order as ConfigsInfo's table
+func ConfigsPlain(info []models.ConfigInfo) {
+	columns := configsColumns(info)
+	for _, configInfo := range info {
+		utils.Print(PlainConfigRow(configInfo, columns.counts, columns.author))
+	}
+}
+
+// ConfigNames print each config's name on its own line
+func ConfigNames(info []models.ConfigInfo) {
+	for _, configInfo := range info {
+		utils.Print(configInfo.Name)
+	}
+}
+
+// PlainConfigRow formats the config as a tab-separated row. Each row is formatted independently, so rows can be
+// printed as soon as their config is received.
+func PlainConfigRow(info models.ConfigInfo, withCount bool, withAuthor bool) string {
+	columns := configColumns{counts: withCount, author: withAuthor}
+	return strings.Join(columns.row(info, timestamp), "\t")
+}
+
+// configColumns the optional columns included when printing configs
+type configColumns struct {
+	counts bool
+	author bool
+}
+
+// configsColumns only includes the secrets and author columns when they were fetched
+func configsColumns(info []models.ConfigInfo) configColumns {
+	var columns configColumns
+	for _, configInfo := range info {
+		columns.counts = columns.counts || configInfo.SecretCount != nil
+		columns.author = columns.author || configInfo.LastModifiedBy != nil
+	}
+	return columns
+}
+
+func (c configColumns) headers() []string {
+	headers := []string{"name", "initial fetch", "last fetch", "created at", "environment", "project", "locked"}
+	if c.counts {
+		headers = append(headers, "secrets")
+	}
+	if c.author {
+		headers = append(headers, "last modified by")
+	}
+	return headers
+}
+
+// row formats the config's columns, using formatTime for its timestamps
+func (c configColumns) row(info models.ConfigInfo, formatTime func(string) string) []string {
+	row := []string{info.Name, formatTime(info.InitialFetchAt), formatTime(info.LastFetchAt), formatTime(info.CreatedAt), info.Environment,
+		info.Project, strconv.FormatBool(info.Locked)}
+	if c.counts {
+		count := ""
+		if info.SecretCount != nil {
+			count = strconv.Itoa(*info.SecretCount)
+		}
+		row = append(row, count)
+	}
+	if c.author {
+		author := ""
+		if info.LastModifiedBy != nil {
+			author = *info.LastModifiedBy
+		}
+		row = append(row, author)
+	}
+	return row
+}
+
+// ConfigsSummary summarizes the configs, e.g. "6 configs (3 dev, 1 stg, 2 prd)". Environments are listed in the order
+// they first appear, and the number of projects is included when there's more than one.
+func ConfigsSummary(info []models.ConfigInfo) string {
+	var environments []string
+	envCounts := map[string]int{}
+	projects := map[string]bool{}
+	for _, configInfo := range info {
+		if _, ok := envCounts[configInfo.Environment]; !ok {
+			environments = append(environments, configInfo.Environment)
+		}
+		envCounts[configInfo.Environment]++
+		projects[configInfo.Project] = true
+	}
+
+	var envSummaries []string
+	for _, environment := range environments {
+		envSummaries = append(envSummaries, fmt.Sprintf("%d %s", envCounts[environment], environment))
+	}
+
+	noun := "configs"
+	if len(info) == 1 {
+		noun = "config"
+	}
+	summary := fmt.Sprintf("%d %s (%s)", len(info), noun, strings.Join(envSummaries, ", "))
+	if len(projects) > 1 {
+		summary += fmt.Sprintf(" across %d projects", len(projects))
+	}
+	return summary
+}
+
+// ConfigSecretsDiff print the secrets that differ between two configs. Values are only printed when revealed.
+func ConfigSecretsDiff(from string, to string, changes []models.ConfigSecretDiff, reveal bool, jsonFlag bool) {
+	if !reveal {
+		for i := range changes {
+			changes[i].From = nil
+			changes[i].To = nil
+		}
+	}
+
+	if jsonFlag {
+		if changes == nil {
+			changes = []models.ConfigSecretDiff{}
+		}
+		JSON(map[string]interface{}{"from": from, "to": to, "changes": changes})
+		return
+	}
+
+	if len(changes) == 0 {
+		utils.Print(fmt.Sprintf("Configs %s and %s have the same secrets", from, to))
+		return
+	}
+
+	for _, change := range changes {
+		printSecretChange(change)
+	}
+}
+
+// SecretChanges print the changes made by setting secrets. Values are only printed when revealed.
+func SecretChanges(changes []models.ConfigSecretDiff, reveal bool, jsonFlag bool) {
+	if !reveal {
+		for i := range changes {
+			changes[i].From = nil
+			changes[i].To = nil
+		}
+	}
+
+	if jsonFlag {
+		if changes == nil {
+			changes = []models.ConfigSecretDiff{}
+		}
+		JSON(changes)
+		return
+	}
+
+	for _, change := range changes {
+		printSecretChange(change)
+	}
+}
+
+// printSecretChange prints the change as a line of a diff, along with its values when they're populated
+func printSecretChange(change models.ConfigSecretDiff) {
+	switch change.Change {
+	case models.SecretAdded:
+		if change.To != nil {
+			utils.Print(color.Green.Render(fmt.Sprintf("+ %s = %s", change.Name, *change.To)))
+		} else {
+			utils.Print(color.Green.Render("+ " + change.Name))
+		}
+	case models.SecretRemoved:
+		if change.From != nil {
+			utils.Print(color.Red.Render(fmt.Sprintf("- %s = %s", change.Name, *change.From)))
+		} else {
+			utils.Print(color.Red.Render("- " + change.Name))
+		}
+	case models.SecretChanged:
+		utils.Print(color.Yellow.Render("~ " + change.Name))
+		if change.From != nil && change.To != nil {
+			utils.Print(color.Red.Render("    - " + *change.From))
+			utils.Print(color.Green.Render("    + " + *change.To))
+		}
+	case models.SecretUnchanged:
+		utils.Print(fmt.Sprintf("  %s (unchanged)", change.Name))
 	}
-	Table([]string{"name", "initial fetch", "last fetch", "created at", "environment", "project"}, rows, TableOptions())
 }
 
 // EnvironmentsInfo print environments
@@ -159,8 +443,8 @@ func EnvironmentsInfo(info []models.EnvironmentInfo, jsonFlag bool) {
 
 	var rows [][]string
 	for _, environmentInfo := range info {
-		rows = append(rows, []string{environmentInfo.ID, environmentInfo.Name, environmentInfo.InitialFetchAt,
-			environmentInfo.CreatedAt, environmentInfo.Project})
+		rows = append(rows, []string{environmentInfo.ID, environmentInfo.Name, timestamp(environmentInfo.InitialFetchAt),
+			timestamp(environmentInfo.CreatedAt), environmentInfo.Project})
 	}
 	Table([]string{"id", "name", "initial fetch", "created at", "project"}, rows, TableOptions())
 }
@@ -172,7 +456,7 @@ func EnvironmentInfo(info models.EnvironmentInfo, jsonFlag bool) {
 		return
 	}
 
-	rows := [][]string{{info.ID, info.Name, info.InitialFetchAt, info.CreatedAt, info.Project}}
+	rows := [][]string{{info.ID, info.Name, timestamp(info.InitialFetchAt), timestamp(info.CreatedAt), info.Project}}
 	Table([]string{"id", "name", "initial fetch", "created at", "project"}, rows, TableOptions())
 }
 
@@ -185,7 +469,7 @@ func ProjectsInfo(info []models.ProjectInfo, jsonFlag bool) {
 
 	var rows [][]string
 	for _, projectInfo := range info {
-		rows = append(rows, []string{projectInfo.ID, projectInfo.Name, projectInfo.Description, projectInfo.CreatedAt})
+		rows = append(rows, []string{projectInfo.ID, projectInfo.Name, projectInfo.Description, timestamp(projectInfo.CreatedAt)})
 	}
 	Table([]string{"id", "name", "description", "created at"}, rows, TableOptions())
 }
@@ -197,12 +481,47 @@ func ProjectInfo(info models.ProjectInfo, jsonFlag bool) {
 		return
 	}
 
-	rows := [][]string{{info.ID, info.Name, info.Description, info.CreatedAt}}
+	rows := [][]string{{info.ID, info.Name, info.Description, timestamp(info.CreatedAt)}}
 	Table([]string{"id", "name", "description", "created at"}, rows, TableOptions())
 }
 
+// PlainSecretValues returns the values of the named secrets, in order. Missing secrets and restricted values are empty
+// so that each value stays at the position of its name.
+func PlainSecretValues(secrets map[string]models.ComputedSecret, names []string, raw bool) []string {
+	vals := []string{}
+	for _, name := range names {
+		secret := secrets[name]
+		value := secret.ComputedValue
+		if raw {
+			value = secret.RawValue
+		}
+		if value != nil {
+			vals = append(vals, *value)
+		} else {
+			vals = append(vals, "")
+		}
+	}
+	return vals
+}
+
+// SecretsOptions customize how Secrets prints secrets
+type SecretsOptions struct {
+	JSON bool
+	// print only the values, one per line
+	Plain bool
+	// include the raw values
+	Raw bool
+	// copy the computed values to the clipboard
+	Copy       bool
+	Visibility bool
+	// include whether each secret is the environment's default (see controllers.AddSecretSources)
+	Source bool
+	// omit the trailing newline when printing plain values
+	NoNewline bool
+}
+
 // Secrets print secrets
-func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string, jsonFlag bool, plain bool, raw bool, copy bool, visibility bool) {
+func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string, options SecretsOptions) {
 	if len(secretsToPrint) == 0 {
 		for name := range secrets {
 			secretsToPrint = append(secretsToPrint, name)
@@ -210,7 +529,7 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		sort.Strings(secretsToPrint)
 	}
 
-	if copy {
+	if options.Copy {
 		vals := []string{}
 		for _, name := range secretsToPrint {
 			if secrets[name] != (models.ComputedSecret{}) {
@@ -227,7 +546,7 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		}
 	}
 
-	if jsonFlag {
+	if options.JSON {
 		secretsMap := map[string]map[string]interface{}{}
 		for _, name := range secretsToPrint {
 			if secrets[name] != (models.ComputedSecret{}) {
@@ -242,7 +561,7 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 					secretsMap[name]["computed"] = nil
 				}
 
-				if raw {
+				if options.Raw {
 					secretsMap[name]["rawVisibility"] = secrets[name].RawVisibility
 					if secrets[name].RawValue != nil {
 						secretsMap[name]["raw"] = *secrets[name].RawValue
@@ -250,6 +569,10 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 						secretsMap[name]["raw"] = nil
 					}
 				}
+
+				if options.Source {
+					secretsMap[name]["source"] = secrets[name].Source
+				}
 			}
 		}
 
@@ -264,39 +587,30 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		}
 	}
 
-	if plain {
-		vals := []string{}
-		for _, secret := range matchedSecrets {
-			if raw {
-				if secret.RawValue != nil {
-					vals = append(vals, *secret.RawValue)
-				} else {
-					vals = append(vals, "")
-				}
-			} else {
-				if secret.ComputedValue != nil {
-					vals = append(vals, *secret.ComputedValue)
-				} else {
-					vals = append(vals, "")
-				}
-			}
+	if options.Plain {
+		vals := PlainSecretValues(secrets, secretsToPrint, options.Raw)
+		if options.NoNewline {
+			utils.PrintRaw(strings.Join(vals, "\n"))
+		} else {
+			utils.Print(strings.Join(vals, "\n"))
 		}
-
-		fmt.Println(strings.Join(vals, "\n"))
 		return
 	}
 
 	headers := []string{"name"}
-	if visibility {
+	if options.Visibility {
 		headers = append(headers, "visibility")
 	}
 	headers = append(headers, "value")
-	if raw {
-		if visibility {
+	if options.Raw {
+		if options.Visibility {
 			headers = append(headers, "raw visibility")
 		}
 		headers = append(headers, "raw value")
 	}
+	if options.Source {
+		headers = append(headers, "source")
+	}
 	headers = append(headers, "note")
 
 	var rows [][]string
@@ -309,22 +623,25 @@ func Secrets(secrets map[string]models.ComputedSecret, secretsToPrint []string,
 		}
 
 		row := []string{secret.Name}
-		if visibility {
+		if options.Visibility {
 			row = append(row, secret.ComputedVisibility)
 		}
 		row = append(row, computedValue)
-		if raw {
+		if options.Raw {
 			var rawValue string
 			if secret.RawValue != nil {
 				rawValue = *secret.RawValue
 			} else {
 				rawValue = "[RESTRICTED]"
 			}
-			if visibility {
+			if options.Visibility {
 				row = append(row, secret.RawVisibility)
 			}
 			row = append(row, rawValue)
 		}
+		if options.Source {
+			row = append(row, secret.Source)
+		}
 
 		row = append(row, secret.Note)
 
@@ -392,7 +709,7 @@ func ConfigServiceTokensInfo(tokens []models.ConfigServiceToken, number int, jso
 
 	rows := [][]string{}
 	for _, token := range tokens {
-		rows = append(rows, []string{token.Name, token.Slug, token.Project, token.Environment, token.Config, token.CreatedAt, token.ExpiresAt, token.Access})
+		rows = append(rows, []string{token.Name, token.Slug, token.Project, token.Environment, token.Config, timestamp(token.CreatedAt), timestamp(token.ExpiresAt), token.Access})
 	}
 	Table([]string{"name", "slug", "project", "environment", "config", "created at", "expires at", "access"}, rows, TableOptions())
 }
@@ -416,7 +733,7 @@ func ConfigServiceToken(token models.ConfigServiceToken, jsonFlag bool, plain bo
 	}
 
 	if plain {
-		fmt.Println(token.Token)
+		utils.Print(token.Token)
 		return
 	}
 
@@ -425,7 +742,7 @@ func ConfigServiceToken(token models.ConfigServiceToken, jsonFlag bool, plain bo
 		return
 	}
 
-	rows := [][]string{{token.Name, token.Token, token.Slug, token.Project, token.Environment, token.Config, token.CreatedAt, token.ExpiresAt, token.Access}}
+	rows := [][]string{{token.Name, token.Token, token.Slug, token.Project, token.Environment, token.Config, timestamp(token.CreatedAt), timestamp(token.ExpiresAt), token.Access}}
 	Table([]string{"name", "token", "slug", "project", "environment", "config", "created at", "expires at", "access"}, rows, TableOptions())
 }
 
@@ -435,6 +752,20 @@ func ActorInfo(info models.ActorInfo, jsonFlag bool) {
 		return
 	}
 
-	rows := [][]string{{info.Name, info.Type, fmt.Sprintf("%s (%s)", info.Workplace.Name, info.Workplace.Slug), info.TokenPreview, info.Slug, info.CreatedAt, info.LastSeenAt}}
+	rows := [][]string{{info.Name, info.Type, fmt.Sprintf("%s (%s)", info.Workplace.Name, info.Workplace.Slug), info.TokenPreview, info.Slug, timestamp(info.CreatedAt), timestamp(info.LastSeenAt)}}
 	Table([]string{"name", "type", "workplace", "token preview", "slug", "created at", "last seen at"}, rows, TableOptions())
 }
+
+// DoctorChecks print the results of the doctor's checks
+func DoctorChecks(checks []models.DoctorCheck, jsonFlag bool) {
+	if jsonFlag {
+		JSON(checks)
+		return
+	}
+
+	var rows [][]string
+	for _, check := range checks {
+		rows = append(rows, []string{check.Name, check.Status, check.Message})
+	}
+	Table([]string{"check", "status", "details"}, rows, TableOptions())
+}
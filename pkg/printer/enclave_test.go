@@ -0,0 +1,91 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package printer
+
+import (
+	"testing"
+
+	"github.com/DopplerHQ/cli/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigsSummary(t *testing.T) {
+	configs := []models.ConfigInfo{
+		{Name: "dev", Environment: "dev", Project: "backend"},
+		{Name: "dev_personal", Environment: "dev", Project: "backend"},
+		{Name: "stg", Environment: "stg", Project: "backend"},
+		{Name: "prd", Environment: "prd", Project: "backend"},
+		{Name: "prd_eu", Environment: "prd", Project: "backend"},
+		{Name: "dev_ci", Environment: "dev", Project: "backend"},
+	}
+	assert.Equal(t, "6 configs (3 dev, 1 stg, 2 prd)", ConfigsSummary(configs))
+
+	assert.Equal(t, "1 config (1 dev)", ConfigsSummary(configs[:1]))
+
+	configs = append(configs, models.ConfigInfo{Name: "dev", Environment: "dev", Project: "frontend"})
+	assert.Equal(t, "7 configs (4 dev, 1 stg, 2 prd) across 2 projects", ConfigsSummary(configs))
+}
+
+func TestPlainSecretValues(t *testing.T) {
+	computed := "computed"
+	raw := "${RAW}"
+	secrets := map[string]models.ComputedSecret{
+		"A":          {Name: "A", ComputedValue: &computed, RawValue: &raw},
+		"RESTRICTED": {Name: "RESTRICTED"},
+	}
+
+	assert.Equal(t, []string{"computed", "", ""}, PlainSecretValues(secrets, []string{"A", "MISSING", "RESTRICTED"}, false))
+	assert.Equal(t, []string{"", "${RAW}"}, PlainSecretValues(secrets, []string{"MISSING", "A"}, true))
+}
+
+func TestFormatConfigsCSV(t *testing.T) {
+	count := 3
+	author := "Doe, Jane"
+	out, err := FormatConfigsCSV([]models.ConfigInfo{
+		{Name: "dev", Environment: "dev", Project: "backend", CreatedAt: "2024-01-01T00:00:00.000Z", LastFetchAt: "2024-02-01T00:00:00.000Z", SecretCount: &count, LastModifiedBy: &author},
+		{Name: `say "hi"`, Environment: "prd", Project: "backend", Locked: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "name,initial_fetch,last_fetch,created_at,environment,project,locked,secrets,last_modified_by\r\n"+
+		"dev,,2024-02-01T00:00:00.000Z,2024-01-01T00:00:00.000Z,dev,backend,false,3,\"Doe, Jane\"\r\n"+
+		"\"say \"\"hi\"\"\",,,,prd,backend,true,,\r\n", out)
+
+	// the optional columns are omitted when they weren't fetched
+	out, err = FormatConfigsCSV([]models.ConfigInfo{{Name: "dev"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "name,initial_fetch,last_fetch,created_at,environment,project,locked\r\ndev,,,,,,false\r\n", out)
+}
+
+func TestPlainConfigRow(t *testing.T) {
+	count := 3
+	author := "jane@example.com"
+	info := models.ConfigInfo{Name: "dev", Environment: "dev", Project: "backend", SecretCount: &count, LastModifiedBy: &author}
+
+	assert.Equal(t, "dev\t\t\t\tdev\tbackend\tfalse", PlainConfigRow(info, false, false))
+	assert.Equal(t, "dev\t\t\t\tdev\tbackend\tfalse\t3\tjane@example.com", PlainConfigRow(info, true, true))
+	assert.Equal(t, "dev\t\t\t\tdev\tbackend\tfalse\t\t", PlainConfigRow(models.ConfigInfo{Name: "dev", Environment: "dev", Project: "backend"}, true, true))
+}
+
+func TestConfigLogChange(t *testing.T) {
+	value := func(v string) *string { return &v }
+
+	assert.Equal(t, models.ConfigSecretDiff{Name: "NEW", Change: models.SecretAdded, To: value("secret")},
+		configLogChange(models.LogDiff{Name: "NEW", Added: "secret"}))
+	assert.Equal(t, models.ConfigSecretDiff{Name: "DELETED", Change: models.SecretRemoved, From: value("old")},
+		configLogChange(models.LogDiff{Name: "DELETED", Removed: "old"}))
+	assert.Equal(t, models.ConfigSecretDiff{Name: "UPDATED", Change: models.SecretChanged, From: value("old"), To: value("new")},
+		configLogChange(models.LogDiff{Name: "UPDATED", Removed: "old", Added: "new"}))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const defaultPager = "less -FRX"
+
+// StartPager captures everything printed to stdout until the returned function is called. If the captured output
+// is taller than the terminal, it's piped through the user's pager; otherwise it's printed as is.
+// Paging is skipped entirely when stdout isn't a TTY, or when --no-pager, --json, or --silent are specified.
+func StartPager() func() {
+	if utils.NoPager || utils.OutputJSON || utils.Silent || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return func() {}
+	}
+
+	_, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		utils.LogDebugError(err)
+		return func() {}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		utils.LogDebugError(err)
+		return func() {}
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := io.Copy(&output, r); err != nil {
+			utils.LogDebugError(err)
+		}
+	}()
+
+	return func() {
+		if err := w.Close(); err != nil {
+			utils.LogDebugError(err)
+		}
+		<-done
+		os.Stdout = stdout
+
+		if bytes.Count(output.Bytes(), []byte("\n")) < height || !runPager(output.Bytes(), stdout) {
+			if _, err := stdout.Write(output.Bytes()); err != nil {
+				utils.LogDebugError(err)
+			}
+		}
+	}
+}
+
+// runPager pipes the output through the user's pager, returning whether it succeeded
+func runPager(output []byte, stdout *os.File) bool {
+	pager := os.Getenv("DOPPLER_PAGER")
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = defaultPager
+	}
+
+	args := strings.Fields(pager)
+	if len(args) == 0 {
+		return false
+	}
+
+	cmd := exec.Command(args[0], args[1:]...) // #nosec G204 nosemgrep: semgrep_configs.prohibit-exec-command
+	cmd.Stdin = bytes.NewReader(output)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	// ensure colors survive when a bare 'less' is specified
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	utils.LogDebug(fmt.Sprintf("Using pager %s", pager))
+	if err := cmd.Start(); err != nil {
+		utils.LogDebugError(err)
+		return false
+	}
+	// the output has already been displayed, so a failure here (e.g. the user quitting early) isn't fatal
+	if err := cmd.Wait(); err != nil {
+		utils.LogDebugError(err)
+	}
+	return true
+}
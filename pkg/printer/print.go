@@ -35,6 +35,8 @@ type tableOptions struct {
 	ShowBorder      bool
 	SeparateHeader  bool
 	SeparateColumns bool
+	// column numbers (starting from 1) whose values are right aligned, e.g. counts
+	RightAlignColumns []int
 }
 
 var maxTableWidth = 80
@@ -57,7 +59,6 @@ func TableOptions() tableOptions {
 // Table print table
 func Table(headers []string, rows [][]string, options tableOptions) {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
 	t.SetStyle(table.StyleLight)
 
 	t.SetTitle(options.Title)
@@ -71,6 +72,12 @@ func Table(headers []string, rows [][]string, options tableOptions) {
 	}
 	t.AppendHeader(tableHeaders)
 
+	var columnConfigs []table.ColumnConfig
+	for _, number := range options.RightAlignColumns {
+		columnConfigs = append(columnConfigs, table.ColumnConfig{Number: number, Align: text.AlignRight})
+	}
+	t.SetColumnConfigs(columnConfigs)
+
 	numCols := numColumns(rows)
 	maxColWidths := maxColWidths(rows, numCols)
 	colWidths := optimalColWidths(maxColWidths, numCols)
@@ -83,7 +90,9 @@ func Table(headers []string, rows [][]string, options tableOptions) {
 		t.AppendRow(tableRow)
 	}
 
-	t.Render()
+	if out := t.Render(); out != "" {
+		utils.Print(out)
+	}
 }
 
 // ChangeLog print change log
@@ -111,14 +120,14 @@ func ChangeLog(changes map[string]models.ChangeLog, max int, jsonFlag bool) {
 			break
 		}
 		if i != 0 {
-			fmt.Println("")
+			utils.Print("")
 		}
 
 		vString := version.Normalize(v.String())
-		fmt.Println(color.Cyan.Sprintf("CLI %s", vString))
+		utils.Print(color.Cyan.Sprintf("CLI %s", vString))
 		cl := changes[vString]
 		for _, change := range cl.Changes {
-			fmt.Println(fmt.Sprintf("· %s", change))
+			utils.Print(fmt.Sprintf("· %s", change))
 		}
 	}
 }
@@ -15,7 +15,11 @@ limitations under the License.
 */
 package printer
 
-import "math"
+import (
+	"math"
+
+	"github.com/DopplerHQ/cli/pkg/utils"
+)
 
 const colWidthBuffer = 3
 
@@ -64,3 +68,8 @@ func optimalColWidths(maxColWidths []int, numCols int) []int {
 
 	return colWidths
 }
+
+// timestamp formats an API timestamp for display in the configured time zone
+func timestamp(value string) string {
+	return utils.FormatTimestamp(value, utils.TimeZone)
+}
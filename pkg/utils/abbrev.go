@@ -0,0 +1,50 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import "sort"
+
+// MinAbbrevLength the minimum length of an abbreviated ID, matching git's default for short hashes
+const MinAbbrevLength = 7
+
+// AbbreviateIDs maps each ID to its shortest prefix (of at least minLength characters) that doesn't prefix any other ID
+// in the set. IDs that prefix another ID (or are duplicated) can't be abbreviated and map to themselves.
+func AbbreviateIDs(ids []string, minLength int) map[string]string {
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+
+	abbreviations := map[string]string{}
+	for i, id := range sorted {
+		// in sorted order, the IDs sharing the longest prefix with this ID are its neighbors
+		length := minLength
+		if i > 0 {
+			length = Max(length, commonPrefixLength(id, sorted[i-1])+1)
+		}
+		if i < len(sorted)-1 {
+			length = Max(length, commonPrefixLength(id, sorted[i+1])+1)
+		}
+		abbreviations[id] = id[:Min(length, len(id))]
+	}
+	return abbreviations
+}
+
+func commonPrefixLength(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
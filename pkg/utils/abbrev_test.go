@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbbreviateIDs(t *testing.T) {
+	ids := []string{"abcdef123456", "abcdef999999", "abcdxyz00000", "zzzzzzzzzzzz", "abc"}
+	assert.Equal(t, map[string]string{
+		// distinguished from each other at the seventh character
+		"abcdef123456": "abcdef1",
+		"abcdef999999": "abcdef9",
+		// unique within the minimum length
+		"abcdxyz00000": "abcdxyz",
+		"zzzzzzzzzzzz": "zzzzzzz",
+		// shorter than the minimum length
+		"abc": "abc",
+	}, AbbreviateIDs(ids, MinAbbrevLength))
+
+	// an ID prefixing another ID can't be abbreviated
+	assert.Equal(t, map[string]string{"abcd": "abcd", "abcde": "abcde"}, AbbreviateIDs([]string{"abcde", "abcd"}, 2))
+	assert.Equal(t, map[string]string{"ab": "a", "cd": "c"}, AbbreviateIDs([]string{"ab", "cd"}, 1))
+	assert.Empty(t, AbbreviateIDs(nil, MinAbbrevLength))
+}
@@ -15,6 +15,8 @@ limitations under the License.
 */
 package utils
 
+import "time"
+
 // Debug whether we're running in debug mode
 var Debug = false
 
@@ -26,3 +28,27 @@ var Silent = false
 
 // OutputJSON whether to print OutputJSON
 var OutputJSON = false
+
+// JSONEnvelope whether to wrap JSON list output in an envelope containing metadata
+var JSONEnvelope = false
+
+// PlainErrors whether to omit color from error, warning, and debug messages. Color is also omitted when stderr isn't a terminal
+var PlainErrors = false
+
+// ErrorFormat the format in which ErrExit prints errors (TextErrorFormat or JSONErrorFormat)
+var ErrorFormat = TextErrorFormat
+
+// NoPager whether to disable paging of long output
+var NoPager = false
+
+// PageSize the number of items requested per page when commands page through a listing (e.g. a config's logs)
+var PageSize = 100
+
+// MaxPageSize the largest page size that may be requested
+const MaxPageSize = 1000
+
+// Concurrency the max number of operations (like requests) that commands may run in parallel. 1 runs them sequentially
+var Concurrency = 8
+
+// TimeZone the time zone in which timestamps are displayed in tables. JSON output always contains the API's original values
+var TimeZone = time.Local
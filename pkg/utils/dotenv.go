@@ -0,0 +1,301 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseDotEnv parses the contents of a .env file. Values may be unquoted, single quoted (taken literally),
+// or double quoted (supporting escape sequences and spanning multiple lines).
+// When expand is true, ${NAME} and $NAME references in unquoted and double quoted values are replaced with
+// values defined earlier in the file, and referencing an undefined variable is an error.
+// Defining a key more than once is a DuplicateKeyError unless allowDuplicates is true, in which case a warning
+// is logged and the last definition is used.
+func ParseDotEnv(data string, expand bool, allowDuplicates bool) (map[string]string, error) {
+	p := dotEnvParser{data: data, line: 1, expand: expand, allowDuplicates: allowDuplicates, values: map[string]string{}, keyLines: map[string]int{}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.values, nil
+}
+
+// ExpandDotEnvReferences replaces ${NAME} and $NAME references in value with the values in vars, the same way
+// ParseDotEnv does for unquoted values when expansion is enabled. Referencing a name not in vars is an error.
+func ExpandDotEnvReferences(value string, vars map[string]string) (string, error) {
+	p := dotEnvParser{expand: true, values: vars}
+	return p.interpolate(value, false)
+}
+
+// DuplicateKeyError a key is defined more than once
+type DuplicateKeyError struct {
+	Key       string
+	FirstLine int
+	Line      int
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("line %d: duplicate key %s (first defined on line %d)", e.Line, e.Key, e.FirstLine)
+}
+
+type dotEnvParser struct {
+	data string
+	pos  int
+	line int
+	// the line on which the entry being parsed begins
+	entryLine       int
+	expand          bool
+	allowDuplicates bool
+	values          map[string]string
+	// the line on which each key was first defined
+	keyLines map[string]int
+}
+
+func (p *dotEnvParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.entryLine, fmt.Sprintf(format, a...))
+}
+
+func (p *dotEnvParser) parse() error {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			p.skipLine()
+		default:
+			if err := p.parseEntry(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipLine advances to the start of the next line
+func (p *dotEnvParser) skipLine() {
+	end := strings.IndexByte(p.data[p.pos:], '\n')
+	if end == -1 {
+		p.pos = len(p.data)
+		return
+	}
+	p.pos += end + 1
+	p.line++
+}
+
+func (p *dotEnvParser) parseEntry() error {
+	p.entryLine = p.line
+	lineEnd := strings.IndexByte(p.data[p.pos:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(p.data)
+	} else {
+		lineEnd += p.pos
+	}
+
+	equals := strings.IndexByte(p.data[p.pos:lineEnd], '=')
+	if equals == -1 {
+		return p.errorf("expected KEY=VALUE")
+	}
+	key := strings.TrimSpace(p.data[p.pos : p.pos+equals])
+	key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return p.errorf("invalid key %q", key)
+	}
+	if firstLine, ok := p.keyLines[key]; ok {
+		duplicateErr := &DuplicateKeyError{Key: key, FirstLine: firstLine, Line: p.entryLine}
+		if !p.allowDuplicates {
+			return duplicateErr
+		}
+		LogWarning(fmt.Sprintf("%s. Using the last value", duplicateErr))
+	} else {
+		p.keyLines[key] = p.entryLine
+	}
+	p.pos += equals + 1
+
+	// skip whitespace between the equals sign and the value
+	for p.pos < len(p.data) && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.pos++
+	}
+
+	var value string
+	var err error
+	if p.pos < len(p.data) && (p.data[p.pos] == '"' || p.data[p.pos] == '\'') {
+		value, err = p.parseQuotedValue(p.data[p.pos])
+	} else {
+		value, err = p.parseUnquotedValue()
+	}
+	if err != nil {
+		return err
+	}
+
+	p.values[key] = value
+	return nil
+}
+
+func (p *dotEnvParser) parseQuotedValue(quote byte) (string, error) {
+	p.pos++
+
+	var raw strings.Builder
+	escaped := false
+	closed := false
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '\n' {
+			p.line++
+		}
+		if escaped {
+			escaped = false
+		} else if c == '\\' && quote == '"' {
+			escaped = true
+		} else if c == quote {
+			closed = true
+			break
+		}
+		raw.WriteByte(c)
+	}
+	if !closed {
+		return "", p.errorf("unterminated quoted value")
+	}
+
+	// only whitespace and comments may follow the closing quote
+	rest := p.restOfLine()
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", p.errorf("unexpected characters after quoted value")
+	}
+
+	if quote == '\'' {
+		return raw.String(), nil
+	}
+	value, err := p.interpolate(raw.String(), true)
+	if err != nil {
+		return "", p.errorf("%s", err)
+	}
+	return value, nil
+}
+
+func (p *dotEnvParser) parseUnquotedValue() (string, error) {
+	value := p.restOfLine()
+	// strip inline comments, which must be preceded by whitespace
+	if i := strings.Index(value, " #"); i != -1 {
+		value = value[:i]
+	}
+	value, err := p.interpolate(strings.TrimSpace(value), false)
+	if err != nil {
+		return "", p.errorf("%s", err)
+	}
+	return value, nil
+}
+
+// restOfLine consumes and returns the remainder of the current line, excluding the newline
+func (p *dotEnvParser) restOfLine() string {
+	start := p.pos
+	end := strings.IndexByte(p.data[start:], '\n')
+	if end == -1 {
+		p.pos = len(p.data)
+		return strings.TrimSuffix(p.data[start:], "\r")
+	}
+	p.pos = start + end + 1
+	p.line++
+	return strings.TrimSuffix(p.data[start:start+end], "\r")
+}
+
+// interpolate processes escape sequences (for double quoted values) and variable references (when expansion is enabled)
+func (p *dotEnvParser) interpolate(raw string, unescape bool) (string, error) {
+	var value strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+
+		if unescape && c == '\\' && i+1 < len(raw) {
+			i++
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '"', '\\', '$':
+				value.WriteByte(raw[i])
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(raw[i])
+			}
+			continue
+		}
+
+		if !p.expand || c != '$' {
+			value.WriteByte(c)
+			continue
+		}
+
+		name, length := dotEnvReference(raw[i:])
+		if length == 0 {
+			value.WriteByte(c)
+			continue
+		}
+		referenced, ok := p.values[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable %s", name)
+		}
+		value.WriteString(referenced)
+		i += length - 1
+	}
+	return value.String(), nil
+}
+
+// dotEnvReference parses a ${NAME} or $NAME reference at the start of s, returning the name and the reference's length
+func dotEnvReference(s string) (string, int) {
+	if strings.HasPrefix(s, "${") {
+		end := strings.IndexByte(s, '}')
+		if end == -1 || !isEnvName(s[2:end]) {
+			return "", 0
+		}
+		return s[2:end], end + 1
+	}
+
+	end := 1
+	for end < len(s) && isEnvNameChar(s[end], end == 1) {
+		end++
+	}
+	if end == 1 {
+		return "", 0
+	}
+	return s[1:end], end
+}
+
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isEnvNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	data := `# comment
+export HOST=localhost
+PORT = 8080 # inline comment
+EMPTY=
+SINGLE='${HOST} stays literal'
+DOUBLE="line one\nline \"two\""
+MULTILINE="a
+b"
+URL=http://${HOST}:$PORT/path
+ESCAPED="\${HOST}"
+`
+
+	secrets, err := ParseDotEnv(data, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HOST":      "localhost",
+		"PORT":      "8080",
+		"EMPTY":     "",
+		"SINGLE":    "${HOST} stays literal",
+		"DOUBLE":    "line one\nline \"two\"",
+		"MULTILINE": "a\nb",
+		"URL":       "http://${HOST}:$PORT/path",
+		"ESCAPED":   "${HOST}",
+	}, secrets)
+
+	secrets, err = ParseDotEnv(data, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/path", secrets["URL"])
+	assert.Equal(t, "${HOST} stays literal", secrets["SINGLE"])
+	assert.Equal(t, "${HOST}", secrets["ESCAPED"])
+}
+
+func TestParseDotEnvErrors(t *testing.T) {
+	_, err := ParseDotEnv("A=1\nURL=${HOST}\n", true, false)
+	assert.EqualError(t, err, "line 2: undefined variable HOST")
+
+	// references must be defined earlier in the file
+	_, err = ParseDotEnv("URL=$HOST\nHOST=localhost\n", true, false)
+	assert.EqualError(t, err, "line 1: undefined variable HOST")
+
+	_, err = ParseDotEnv("A=1\nINVALID\n", false, false)
+	assert.EqualError(t, err, "line 2: expected KEY=VALUE")
+
+	_, err = ParseDotEnv("A=\"unterminated\n", false, false)
+	assert.EqualError(t, err, "line 1: unterminated quoted value")
+}
+
+func TestParseDotEnvCRLF(t *testing.T) {
+	data := "HOST=localhost\r\nSINGLE='a\r\nb'\r\nDOUBLE=\"a\r\nb\"\r\nESCAPED=\"a\\r\\nb\"\r\n"
+
+	secrets, err := ParseDotEnv(NormalizeLineEndings(data), false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HOST":   "localhost",
+		"SINGLE": "a\nb",
+		"DOUBLE": "a\nb",
+		// escape sequences are unaffected
+		"ESCAPED": "a\r\nb",
+	}, secrets)
+
+	// without normalization, line breaks within quoted values are preserved as written
+	secrets, err = ParseDotEnv(data, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", secrets["HOST"])
+	assert.Equal(t, "a\r\nb", secrets["SINGLE"])
+	assert.Equal(t, "a\r\nb", secrets["DOUBLE"])
+}
+
+func TestParseDotEnvDuplicateKeys(t *testing.T) {
+	data := "FOO=1\nBAR=2\n\nexport FOO=\"multi\nline\"\nFOO=3\n"
+
+	_, err := ParseDotEnv(data, false, false)
+	assert.EqualError(t, err, "line 4: duplicate key FOO (first defined on line 1)")
+	var duplicateErr *DuplicateKeyError
+	assert.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, "FOO", duplicateErr.Key)
+
+	// the last definition wins
+	secrets, err := ParseDotEnv(data, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"FOO": "3", "BAR": "2"}, secrets)
+}
+
+func TestExpandDotEnvReferences(t *testing.T) {
+	vars := map[string]string{"HOST": "localhost", "PORT": "8080"}
+
+	value, err := ExpandDotEnvReferences("http://${HOST}:$PORT/$", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/$", value)
+
+	_, err = ExpandDotEnvReferences("${USER}", vars)
+	assert.EqualError(t, err, "undefined variable USER")
+}
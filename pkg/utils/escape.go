@@ -0,0 +1,161 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the formats supported by FormatEnvLine
+const (
+	DotEnvEscapeFormat      = "dotenv"
+	DockerEscapeFormat      = "docker"
+	ShellExportEscapeFormat = "shell-export"
+	SystemdEscapeFormat     = "systemd"
+	NginxEscapeFormat       = "nginx"
+	TFVarsEscapeFormat      = "tfvars"
+)
+
+// EscapeFormats the formats supported by FormatEnvLine
+var EscapeFormats = []string{DotEnvEscapeFormat, DockerEscapeFormat, ShellExportEscapeFormat, SystemdEscapeFormat, NginxEscapeFormat, TFVarsEscapeFormat}
+
+// EscapeDotEnvValue returns the value as a double quoted dotenv value. Backslashes, double quotes, and dollar signs
+// are escaped so the value is never interpolated, and newlines are escaped so each entry occupies a single line.
+func EscapeDotEnvValue(value string) string {
+	var escaped strings.Builder
+	escaped.WriteByte('"')
+	for _, c := range value {
+		switch c {
+		case '\\':
+			escaped.WriteString(`\\`)
+		case '"':
+			escaped.WriteString(`\"`)
+		case '$':
+			escaped.WriteString(`\$`)
+		case '\n':
+			escaped.WriteString(`\n`)
+		case '\r':
+			escaped.WriteString(`\r`)
+		default:
+			escaped.WriteRune(c)
+		}
+	}
+	escaped.WriteByte('"')
+	return escaped.String()
+}
+
+// EscapeDockerValue returns the value for use in a docker --env-file. Docker reads values literally (without
+// support for quoting or escaping) up to the end of the line, so values containing line breaks can't be represented.
+func EscapeDockerValue(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("values containing line breaks can't be represented in the %s format", DockerEscapeFormat)
+	}
+	return value, nil
+}
+
+// EscapeShellValue returns the value single quoted for POSIX shells. Single quoted values are never interpolated,
+// so the only character requiring special handling is the single quote itself.
+func EscapeShellValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// EscapeSystemdValue returns the value for use within a double quoted systemd Environment= assignment. systemd
+// processes C-style escapes in quoted strings and expands % specifiers, but doesn't interpolate $ in this setting.
+func EscapeSystemdValue(value string) string {
+	var escaped strings.Builder
+	for _, c := range value {
+		switch c {
+		case '\\':
+			escaped.WriteString(`\\`)
+		case '"':
+			escaped.WriteString(`\"`)
+		case '%':
+			escaped.WriteString(`%%`)
+		case '\n':
+			escaped.WriteString(`\n`)
+		case '\r':
+			escaped.WriteString(`\r`)
+		default:
+			escaped.WriteRune(c)
+		}
+	}
+	return escaped.String()
+}
+
+// EscapeHCLValue returns the value as a quoted HCL string (e.g. for a Terraform .tfvars file). Backslashes, double
+// quotes, and control characters are escaped, and "${" and "%{" are escaped so the value isn't treated as a template.
+func EscapeHCLValue(value string) string {
+	var escaped strings.Builder
+	escaped.WriteByte('"')
+	for i, c := range value {
+		switch {
+		case c == '\\':
+			escaped.WriteString(`\\`)
+		case c == '"':
+			escaped.WriteString(`\"`)
+		case c == '\n':
+			escaped.WriteString(`\n`)
+		case c == '\r':
+			escaped.WriteString(`\r`)
+		case c == '\t':
+			escaped.WriteString(`\t`)
+		case (c == '$' || c == '%') && strings.HasPrefix(value[i+1:], "{"):
+			escaped.WriteRune(c)
+			escaped.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			escaped.WriteString(fmt.Sprintf(`\u%04x`, c))
+		default:
+			escaped.WriteRune(c)
+		}
+	}
+	escaped.WriteByte('"')
+	return escaped.String()
+}
+
+// delimiterReplacer unescapes the escape sequences supported in delimiters
+var delimiterReplacer = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t", `\0`, "\x00")
+
+// UnescapeDelimiter interprets the escape sequences \n (newline), \t (tab), \0 (null byte), and \\ (backslash) in a
+// delimiter specified on the command line. All other characters are used literally.
+func UnescapeDelimiter(delimiter string) string {
+	return delimiterReplacer.Replace(delimiter)
+}
+
+// FormatEnvLine formats a secret as a single entry in the specified format
+func FormatEnvLine(format string, name string, value string) (string, error) {
+	switch format {
+	case DotEnvEscapeFormat:
+		return fmt.Sprintf("%s=%s", name, EscapeDotEnvValue(value)), nil
+	case DockerEscapeFormat:
+		escaped, err := EscapeDockerValue(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		return fmt.Sprintf("%s=%s", name, escaped), nil
+	case ShellExportEscapeFormat:
+		return fmt.Sprintf("export %s=%s", name, EscapeShellValue(value)), nil
+	case SystemdEscapeFormat:
+		return fmt.Sprintf(`Environment="%s=%s"`, name, EscapeSystemdValue(value)), nil
+	case NginxEscapeFormat:
+		// nginx's env directive passes the variable through from nginx's own environment, so the value isn't included
+		return fmt.Sprintf("env %s;", name), nil
+	case TFVarsEscapeFormat:
+		return fmt.Sprintf("%s = %s", name, EscapeHCLValue(value)), nil
+	default:
+		return "", fmt.Errorf("invalid format %q. Valid formats are %v", format, EscapeFormats)
+	}
+}
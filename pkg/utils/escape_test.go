@@ -0,0 +1,188 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type escapeTestCase struct {
+	name   string
+	value  string
+	dotenv string
+	// empty when the value can't be represented in the docker format
+	docker string
+	shell  string
+}
+
+var escapeTestCases = []escapeTestCase{
+	{name: "empty", value: "", dotenv: `""`, docker: ``, shell: `''`},
+	{name: "plain", value: "abc123", dotenv: `"abc123"`, docker: `abc123`, shell: `'abc123'`},
+	{name: "spaces", value: "  padded value  ", dotenv: `"  padded value  "`, docker: `  padded value  `, shell: `'  padded value  '`},
+	{name: "dollar", value: "pa$$word $HOME ${USER}", dotenv: `"pa\$\$word \$HOME \${USER}"`, docker: `pa$$word $HOME ${USER}`, shell: `'pa$$word $HOME ${USER}'`},
+	{name: "backticks", value: "`whoami` $(id)", dotenv: "\"`whoami` \\$(id)\"", docker: "`whoami` $(id)", shell: "'`whoami` $(id)'"},
+	{name: "double quotes", value: `say "hi"`, dotenv: `"say \"hi\""`, docker: `say "hi"`, shell: `'say "hi"'`},
+	{name: "single quotes", value: `it's`, dotenv: `"it's"`, docker: `it's`, shell: `'it'\''s'`},
+	{name: "quoted value", value: `"quoted"`, dotenv: `"\"quoted\""`, docker: `"quoted"`, shell: `'"quoted"'`},
+	{name: "backslashes", value: `C:\path\n`, dotenv: `"C:\\path\\n"`, docker: `C:\path\n`, shell: `'C:\path\n'`},
+	{name: "newlines", value: "line1\nline2\n", dotenv: `"line1\nline2\n"`, shell: "'line1\nline2\n'"},
+	{name: "crlf", value: "line1\r\nline2", dotenv: `"line1\r\nline2"`, shell: "'line1\r\nline2'"},
+	{name: "equals", value: "a=b==", dotenv: `"a=b=="`, docker: `a=b==`, shell: `'a=b=='`},
+	{name: "hash", value: "value # not a comment", dotenv: `"value # not a comment"`, docker: `value # not a comment`, shell: `'value # not a comment'`},
+	{name: "unicode", value: "héllo 🌍", dotenv: `"héllo 🌍"`, docker: `héllo 🌍`, shell: `'héllo 🌍'`},
+	{name: "everything", value: "$`\"'\\\n=", dotenv: "\"\\$`\\\"'\\\\\\n=\"", shell: "'$`\"'\\''\\\n='"},
+}
+
+func TestEscapeGolden(t *testing.T) {
+	for _, tc := range escapeTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.dotenv, EscapeDotEnvValue(tc.value))
+			assert.Equal(t, tc.shell, EscapeShellValue(tc.value))
+
+			docker, err := EscapeDockerValue(tc.value)
+			if strings.ContainsAny(tc.value, "\r\n") {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.docker, docker)
+			}
+		})
+	}
+}
+
+func TestEscapeDotEnvRoundTrip(t *testing.T) {
+	for _, expand := range []bool{false, true} {
+		var lines []string
+		expected := map[string]string{}
+		for i, tc := range escapeTestCases {
+			name := "SECRET_" + string(rune('A'+i))
+			line, err := FormatEnvLine(DotEnvEscapeFormat, name, tc.value)
+			assert.NoError(t, err)
+			lines = append(lines, line)
+			expected[name] = tc.value
+		}
+
+		parsed, err := ParseDotEnv(strings.Join(lines, "\n"), expand, false)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, parsed)
+	}
+}
+
+func TestMapToEnvFormat(t *testing.T) {
+	secrets := map[string]string{"PLAIN": "value", "QUOTES": `say "hi"`, "BACKSLASH": `C:\dir`, "DOLLAR": "pa$$word", "MULTILINE": "a\nb"}
+
+	// mounted env files (e.g. 'run --mount') keep the format read by existing consumers: only backslashes and double
+	// quotes are escaped, while dollar signs and newlines are written as-is
+	assert.Equal(t, []string{
+		`BACKSLASH="C:\\dir"`,
+		`DOLLAR="pa$$word"`,
+		"MULTILINE=\"a\nb\"",
+		`PLAIN="value"`,
+		`QUOTES="say \"hi\""`,
+	}, MapToEnvFormat(secrets, true))
+
+	// dotenv libraries (e.g. godotenv and python-dotenv, without interpolation) read back the original values
+	parsed, err := ParseDotEnv(strings.Join(MapToEnvFormat(secrets, true), "\n"), false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, secrets, parsed)
+
+	assert.Equal(t, []string{"BACKSLASH=C:\\dir", "DOLLAR=pa$$word", "MULTILINE=a\nb", "PLAIN=value", `QUOTES=say "hi"`}, MapToEnvFormat(secrets, false))
+}
+
+func TestEscapeDockerRoundTrip(t *testing.T) {
+	for _, tc := range escapeTestCases {
+		line, err := FormatEnvLine(DockerEscapeFormat, "SECRET", tc.value)
+		if strings.ContainsAny(tc.value, "\r\n") {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+
+		// docker reads everything after the first equals sign literally
+		parts := strings.SplitN(line, "=", 2)
+		assert.Equal(t, "SECRET", parts[0], tc.name)
+		assert.Equal(t, tc.value, parts[1], tc.name)
+	}
+}
+
+func TestEscapeShellRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	for _, tc := range escapeTestCases {
+		line, err := FormatEnvLine(ShellExportEscapeFormat, "SECRET", tc.value)
+		assert.NoError(t, err, tc.name)
+
+		// printf avoids the trailing newline added by echo
+		out, err := exec.Command(sh, "-c", line+"\nprintf '%s' \"$SECRET\"").Output() // #nosec G204
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.value, string(out), tc.name)
+	}
+}
+
+func TestFormatEnvLineInvalidFormat(t *testing.T) {
+	_, err := FormatEnvLine("xml", "SECRET", "value")
+	assert.Error(t, err)
+}
+
+func TestEscapeSystemdValue(t *testing.T) {
+	assert.Equal(t, `abc123`, EscapeSystemdValue("abc123"))
+	assert.Equal(t, `say \"hi\"`, EscapeSystemdValue(`say "hi"`))
+	assert.Equal(t, `C:\\path\\n`, EscapeSystemdValue(`C:\path\n`))
+	assert.Equal(t, `line1\r\nline2`, EscapeSystemdValue("line1\r\nline2"))
+	// % introduces a specifier, while $ has no special meaning in Environment=
+	assert.Equal(t, `100%% $HOME`, EscapeSystemdValue("100% $HOME"))
+
+	line, err := FormatEnvLine(SystemdEscapeFormat, "SECRET", `a "b" 50%`)
+	assert.NoError(t, err)
+	assert.Equal(t, `Environment="SECRET=a \"b\" 50%%"`, line)
+
+	line, err = FormatEnvLine(NginxEscapeFormat, "SECRET", "value")
+	assert.NoError(t, err)
+	assert.Equal(t, "env SECRET;", line)
+}
+
+func TestUnescapeDelimiter(t *testing.T) {
+	assert.Equal(t, "\n", UnescapeDelimiter(`\n`))
+	assert.Equal(t, "\x00", UnescapeDelimiter(`\0`))
+	assert.Equal(t, "\t", UnescapeDelimiter(`\t`))
+	assert.Equal(t, ",", UnescapeDelimiter(","))
+	assert.Equal(t, " | ", UnescapeDelimiter(" | "))
+	// an escaped backslash isn't combined with the following character
+	assert.Equal(t, `\n`, UnescapeDelimiter(`\\n`))
+	assert.Equal(t, `\x`, UnescapeDelimiter(`\x`))
+}
+
+func TestEscapeHCLValue(t *testing.T) {
+	assert.Equal(t, `""`, EscapeHCLValue(""))
+	assert.Equal(t, `"abc123"`, EscapeHCLValue("abc123"))
+	assert.Equal(t, `"say \"hi\""`, EscapeHCLValue(`say "hi"`))
+	assert.Equal(t, `"C:\\path\\n"`, EscapeHCLValue(`C:\path\n`))
+	assert.Equal(t, `"line1\r\nline2\tend"`, EscapeHCLValue("line1\r\nline2\tend"))
+	// template sequences are escaped, while lone $ and % are literal
+	assert.Equal(t, `"$${HOME} %%{if} $HOME 100%"`, EscapeHCLValue("${HOME} %{if} $HOME 100%"))
+	assert.Equal(t, `"bell\u0007 héllo 🌍"`, EscapeHCLValue("bell\a héllo 🌍"))
+
+	line, err := FormatEnvLine(TFVarsEscapeFormat, "SECRET", "value")
+	assert.NoError(t, err)
+	assert.Equal(t, `SECRET = "value"`, line)
+}
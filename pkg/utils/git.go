@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitPathStatus whether git could commit a path
+type GitPathStatus int
+
+const (
+	// GitPathUnknown the path isn't in a git repo, or git isn't available
+	GitPathUnknown GitPathStatus = iota
+	// GitPathIgnored the path is ignored by git
+	GitPathIgnored
+	// GitPathUntracked the path isn't tracked, but also isn't ignored
+	GitPathUntracked
+	// GitPathTracked the path is tracked by git
+	GitPathTracked
+)
+
+// GitStatusOfPath returns whether the path (which needn't exist) is tracked or ignored by git. This is a best-effort check.
+func GitStatusOfPath(path string) GitPathStatus {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return GitPathUnknown
+	}
+	dir, name := filepath.Split(absPath)
+
+	// exits with 0 if the path is tracked
+	// #nosec G204
+	if err := exec.Command("git", "-C", dir, "ls-files", "--error-unmatch", "--", name).Run(); err == nil {
+		return GitPathTracked
+	}
+
+	// exits with 0 if the path is ignored, 1 if it isn't, and 128 if it's not in a repo
+	// #nosec G204
+	err = exec.Command("git", "-C", dir, "check-ignore", "-q", "--", name).Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return GitPathIgnored
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return GitPathUntracked
+	default:
+		LogDebug(fmt.Sprintf("Unable to check whether %s is ignored by git", path))
+		LogDebugError(err)
+		return GitPathUnknown
+	}
+}
+
+// AddToGitIgnore appends the path to the .gitignore at the root of its git repo, returning the .gitignore's path
+func AddToGitIgnore(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// #nosec G204
+	out, err := exec.Command("git", "-C", filepath.Dir(absPath), "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", errors.New("Unable to find the root of the git repo")
+	}
+	root := strings.TrimSpace(string(out))
+	// git reports the root with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil {
+		absPath = filepath.Join(resolved, filepath.Base(absPath))
+	}
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil {
+		return "", err
+	}
+
+	gitignore := filepath.Join(root, ".gitignore")
+	existing, err := os.ReadFile(gitignore) // #nosec G304
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// anchor the pattern so that only this file is ignored
+	entry := "/" + filepath.ToSlash(relPath) + "\n"
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		entry = "\n" + entry
+	}
+
+	f, err := os.OpenFile(gitignore, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644) // #nosec G302 G304
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return gitignore, f.Close()
+}
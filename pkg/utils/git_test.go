@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitStatusOfPath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	outside := t.TempDir()
+	assert.Equal(t, GitPathUnknown, GitStatusOfPath(filepath.Join(outside, "secrets.json")))
+
+	repo := t.TempDir()
+	assert.NoError(t, exec.Command("git", "-C", repo, "init", "-q").Run()) // #nosec G204
+	assert.NoError(t, os.Mkdir(filepath.Join(repo, "config"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("*.env"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "tracked.json"), []byte("{}"), 0600))
+	assert.NoError(t, exec.Command("git", "-C", repo, "add", "tracked.json").Run()) // #nosec G204
+
+	assert.Equal(t, GitPathIgnored, GitStatusOfPath(filepath.Join(repo, "secrets.env")))
+	assert.Equal(t, GitPathTracked, GitStatusOfPath(filepath.Join(repo, "tracked.json")))
+	// the path needn't exist
+	path := filepath.Join(repo, "config", "secrets.json")
+	assert.Equal(t, GitPathUntracked, GitStatusOfPath(path))
+
+	gitignore, err := AddToGitIgnore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, GitPathIgnored, GitStatusOfPath(path))
+	// other files with the same name remain unignored
+	assert.Equal(t, GitPathUntracked, GitStatusOfPath(filepath.Join(repo, "secrets.json")))
+
+	contents, err := os.ReadFile(gitignore) // #nosec G304
+	assert.NoError(t, err)
+	assert.Equal(t, "*.env\n/config/secrets.json\n", string(contents))
+}
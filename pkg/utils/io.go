@@ -19,6 +19,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -36,12 +37,22 @@ func RestrictedFilePerms() os.FileMode {
 
 // WriteFile atomically writes data to a file named by filename.
 func WriteFile(filename string, data []byte, perm os.FileMode) error {
+	// don't exit on SIGINT until the write has completed (or been cleaned up)
+	return CriticalSection(func() error {
+		return writeFile(filename, data, perm)
+	})
+}
+
+func writeFile(filename string, data []byte, perm os.FileMode) error {
 	temp := fmt.Sprintf("%s.%s", filename, RandomBase64String(8))
 
 	// write to a unique temp file first before performing an atomic move to the actual file name
-	// this prevents a race condition between multiple CLIs reading/writing the same file
+	// this prevents a race condition between multiple CLIs reading/writing the same file, and ensures
+	// readers never see a partially written file
 	LogDebug(fmt.Sprintf("Writing to temp file %s", temp))
-	if err := ioutil.WriteFile(temp, data, os.FileMode(perm)); err != nil {
+	if err := writeTempFile(temp, data, perm); err != nil {
+		// clean up partially written temp file
+		_ = os.Remove(temp)
 		return err
 	}
 
@@ -55,6 +66,36 @@ func WriteFile(filename string, data []byte, perm os.FileMode) error {
 	return nil
 }
 
+// writeTempFile writes data to a new file and flushes it to disk, so that the data is complete once the file is renamed
+func writeTempFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm) // #nosec G304
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	// the umask applies when creating the file, so set the exact perms explicitly
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ExistingFilePerms returns the permissions of the file, or perm if the file doesn't exist
+func ExistingFilePerms(filename string, perm os.FileMode) os.FileMode {
+	if info, err := os.Stat(filename); err == nil {
+		return info.Mode().Perm()
+	}
+	return perm
+}
+
 // WriteTempFile writes data to a unique temp file and returns the file name
 func WriteTempFile(name string, data []byte, perm os.FileMode) (string, error) {
 	// create hidden file in user's home dir to ensure no other users have write access
@@ -91,6 +132,47 @@ func HasDataOnStdIn() (bool, error) {
 	return hasData, nil
 }
 
+// ReadStdInLine reads a single line from stdin, excluding the line ending. Returns nil when no input was piped to stdin.
+// Stdin is read a byte at a time so that any remaining input is left for the command.
+func ReadStdInLine() (*string, error) {
+	hasData, e := HasDataOnStdIn()
+	if e != nil {
+		return nil, e
+	}
+
+	if !hasData {
+		return nil, nil
+	}
+
+	line, e := readLine(os.Stdin)
+	if e != nil {
+		return nil, e
+	}
+	return &line, nil
+}
+
+// readLine reads from the reader up to and including the next newline, returning the line without its line ending
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, e := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return "", e
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
 func GetStdIn() (*string, error) {
 	// read from stdin
 	hasData, e := HasDataOnStdIn()
@@ -21,11 +21,17 @@ package utils
 import (
 	"errors"
 	"os"
+	"strconv"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 const SupportsNamedPipes = true
 
+// errors other than EPIPE that indicate a broken pipe
+var brokenPipeErrors []error
+
 func FileOwnership(path string) (int, int, error) {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -45,3 +51,12 @@ func CreateNamedPipe(path string, mode uint32) error {
 	// only available while the writer (i.e. this program) is alive
 	return syscall.Mkfifo(path, mode)
 }
+
+// SessionID identifies the terminal session the CLI is running in
+func SessionID() string {
+	sid, err := unix.Getsid(0)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(sid)
+}
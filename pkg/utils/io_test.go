@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+
+	assert.NoError(t, WriteFile(path, []byte("A=1\n"), ExistingFilePerms(path, 0600)))
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\n", string(contents))
+
+	if !IsWindows() {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// the permissions of an existing file are preserved, regardless of the umask
+		assert.NoError(t, os.Chmod(path, 0664))
+		assert.NoError(t, WriteFile(path, []byte("A=2\n"), ExistingFilePerms(path, 0600)))
+		info, err = os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0664), info.Mode().Perm())
+	}
+
+	// the temp file is renamed into place
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets")
+	assert.NoError(t, os.Mkdir(path, 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(path, ".env"), []byte("A=1\n"), 0600))
+
+	// a non-empty directory can't be replaced by a file, so the rename fails
+	assert.Error(t, WriteFile(path, []byte("A=2\n"), 0600))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	// the temp file is cleaned up
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestReadLine(t *testing.T) {
+	r := strings.NewReader("dp.st.abc\r\nremaining input\n")
+	line, err := readLine(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "dp.st.abc", line)
+
+	// only the first line is consumed
+	rest, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "remaining input\n", string(rest))
+
+	line, err = readLine(strings.NewReader("no newline"))
+	assert.NoError(t, err)
+	assert.Equal(t, "no newline", line)
+
+	line, err = readLine(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "", line)
+}
@@ -15,10 +15,17 @@ limitations under the License.
 */
 package utils
 
-import "errors"
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
 
 const SupportsNamedPipes = false
 
+// errors other than EPIPE that indicate a broken pipe
+var brokenPipeErrors = []error{windows.ERROR_BROKEN_PIPE, windows.ERROR_NO_DATA}
+
 func FileOwnership(path string) (int, int, error) {
 	return -1, -1, nil
 }
@@ -26,3 +33,8 @@ func FileOwnership(path string) (int, int, error) {
 func CreateNamedPipe(path string, mode uint32) error {
 	return errors.New("This platform does not support named pipes")
 }
+
+// SessionID identifies the terminal session the CLI is running in. Sessions can't be identified on Windows.
+func SessionID() string {
+	return ""
+}
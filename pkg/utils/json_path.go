@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseJSONValue parses a JSON document, preserving the precision of numbers
+func ParseJSONValue(data string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	// the document must consist of a single value
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// ExtractJSONPath returns the field of the value at the specified path. Paths use a subset of jq's syntax: "." is the
+// value itself, ".host" and `.["host"]` are fields of an object, and "[0]" is an element of an array (e.g. ".servers[0].host").
+func ExtractJSONPath(value interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") {
+		return nil, fmt.Errorf("invalid path %q. Paths must start with '.' (e.g. .host)", path)
+	}
+
+	rest := path
+	traversed := ""
+	for rest != "" && rest != "." {
+		parent := traversed
+		if parent == "" {
+			parent = "."
+		}
+		var key string
+		index := -1
+		switch {
+		case strings.HasPrefix(rest, "[") || strings.HasPrefix(rest, ".["):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path %q. Missing closing ']'", path)
+			}
+			segment := rest[1:end]
+			if strings.HasPrefix(segment, `"`) {
+				unquoted, err := strconv.Unquote(segment)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q. Unable to parse quoted field %s", path, segment)
+				}
+				key = unquoted
+			} else {
+				i, err := strconv.Atoi(segment)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid path %q. Array indexes must be non-negative integers", path)
+				}
+				index = i
+			}
+			traversed += rest[:end+1]
+			rest = rest[end+1:]
+		default:
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key = rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid path %q. Expected a field name after '.'", path)
+			}
+			traversed += "." + key
+			rest = rest[end:]
+		}
+
+		if index >= 0 {
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not an array", parent)
+			}
+			if index >= len(array) {
+				return nil, fmt.Errorf("%s does not exist (the array has %d elements)", traversed, len(array))
+			}
+			value = array[index]
+		} else {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not an object", parent)
+			}
+			field, ok := object[key]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", traversed)
+			}
+			value = field
+		}
+	}
+	return value, nil
+}
+
+// FormatJSONValue formats the value for display. Strings are returned as-is, and all other values are pretty printed.
+func FormatJSONValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	// values are displayed rather than embedded in HTML
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractJSONPath(t *testing.T) {
+	value, err := ParseJSONValue(`{"host":"db.internal","port":5432,"replicas":[{"host":"r1"},{"host":"r2"}],"a.b":{"big":12345678901234567890}}`)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: ".host", expected: "db.internal"},
+		{path: ".port", expected: "5432"},
+		{path: ".replicas[1].host", expected: "r2"},
+		{path: ".replicas.[0]", expected: "{\n  \"host\": \"r1\"\n}"},
+		{path: `.["a.b"].big`, expected: "12345678901234567890"},
+		{path: ".", expected: ""},
+	}
+	for _, tc := range testCases {
+		field, err := ExtractJSONPath(value, tc.path)
+		assert.NoError(t, err, tc.path)
+		if tc.path == "." {
+			assert.Equal(t, value, field)
+			continue
+		}
+		formatted, err := FormatJSONValue(field)
+		assert.NoError(t, err, tc.path)
+		assert.Equal(t, tc.expected, formatted, tc.path)
+	}
+
+	_, err = ExtractJSONPath(value, ".missing")
+	assert.EqualError(t, err, ".missing does not exist")
+	_, err = ExtractJSONPath(value, ".host.name")
+	assert.EqualError(t, err, ".host is not an object")
+	_, err = ExtractJSONPath(value, ".replicas[5]")
+	assert.EqualError(t, err, ".replicas[5] does not exist (the array has 2 elements)")
+	_, err = ExtractJSONPath(value, "host")
+	assert.Error(t, err)
+	_, err = ExtractJSONPath(value, ".replicas[x]")
+	assert.Error(t, err)
+}
+
+func TestParseJSONValue(t *testing.T) {
+	_, err := ParseJSONValue("not json")
+	assert.Error(t, err)
+	_, err = ParseJSONValue(`{"a":1} {"b":2}`)
+	assert.Error(t, err)
+	// trailing data that isn't a valid token is rejected too
+	_, err = ParseJSONValue(`{"a":1} }`)
+	assert.Error(t, err)
+	_, err = ParseJSONValue(`{"a":1} garbage`)
+	assert.Error(t, err)
+	_, err = ParseJSONValue(" {\"a\":1}\n")
+	assert.NoError(t, err)
+
+	value, err := ParseJSONValue(`"<tag> & text"`)
+	assert.NoError(t, err)
+	formatted, err := FormatJSONValue(value)
+	assert.NoError(t, err)
+	assert.Equal(t, "<tag> & text", formatted)
+}
@@ -17,30 +17,44 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 
+	"github.com/mattn/go-isatty"
 	"gopkg.in/gookit/color.v1"
 )
 
 // Print output to stdout
 func Print(info string) {
-	fmt.Println(info)
+	if _, err := fmt.Println(info); IsBrokenPipe(err) {
+		ExitBrokenPipe()
+	}
+}
+
+// PrintRaw prints output to stdout exactly as provided, without a trailing newline
+func PrintRaw(info string) {
+	if _, err := fmt.Print(info); IsBrokenPipe(err) {
+		ExitBrokenPipe()
+	}
 }
 
 // Print output to stdout.
 func PrintWarning(s string) {
-	fmt.Println(color.Yellow.Render("Warning:"), s)
+	if _, err := fmt.Println(color.Yellow.Render("Warning:"), s); IsBrokenPipe(err) {
+		ExitBrokenPipe()
+	}
 }
 
 // Log info message to stderr
 func Log(info string) {
-	fmt.Fprintln(os.Stderr, info)
+	logStderr(info)
 }
 
 // LogWarning message to stderr
 func LogWarning(s string) {
-	fmt.Fprintln(os.Stderr, color.Yellow.Render("Warning:"), s)
+	logStderr(color.Yellow.Render("Warning:"), s)
 }
 
 // LogError prints an error message to stderr
@@ -58,7 +72,7 @@ func CanLogInfo() bool {
 func LogDebug(s string) {
 	if CanLogDebug() {
 		// log debug messages to stderr
-		fmt.Fprintln(os.Stderr, color.Blue.Render("Debug:"), s)
+		logStderr(color.Blue.Render("Debug:"), s)
 	}
 }
 
@@ -81,15 +95,19 @@ func HandleError(e error, messages ...string) {
 
 // ErrExit prints the error and exits with the specified code
 func ErrExit(e error, exitCode int, messages ...string) {
-	if OutputJSON {
-		resp, err := json.Marshal(map[string]string{"error": e.Error()})
-		if err != nil {
-			panic(err)
-		}
-		fmt.Fprintln(os.Stderr, string(resp))
+	// errors caused by an interrupt (like canceled requests) aren't reported
+	if Interrupted() {
+		ExitInterrupted()
+	}
+	if IsBrokenPipe(e) {
+		ExitBrokenPipe()
+	}
+
+	if ErrorFormat == JSONErrorFormat || OutputJSON {
+		fmt.Fprintln(os.Stderr, formatJSONError(newJSONError(e, exitCode, messages...)))
 	} else {
 		if len(messages) > 0 && messages[0] != "" {
-			fmt.Fprintln(os.Stderr, messages[0])
+			logStderr(messages[0])
 		}
 
 		if e != nil {
@@ -98,7 +116,7 @@ func ErrExit(e error, exitCode int, messages ...string) {
 
 		if len(messages) > 0 {
 			for _, message := range messages[1:] {
-				fmt.Fprintln(os.Stderr, message)
+				logStderr(message)
 			}
 		}
 	}
@@ -106,6 +124,81 @@ func ErrExit(e error, exitCode int, messages ...string) {
 	os.Exit(exitCode)
 }
 
+// the formats supported by --error-format
+const (
+	TextErrorFormat = "text"
+	JSONErrorFormat = "json"
+)
+
+// ErrorFormats the formats supported by --error-format
+var ErrorFormats = []string{TextErrorFormat, JSONErrorFormat}
+
+type jsonError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// the underlying error, when there is one
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+}
+
+// formatJSONError formats the error as a single line. --error-format json prints the error object itself, while
+// --json output wraps it in an "error" field
+func formatJSONError(jsonErr jsonError) string {
+	var resp []byte
+	var err error
+	if ErrorFormat == JSONErrorFormat {
+		resp, err = json.Marshal(jsonErr)
+	} else {
+		resp, err = json.Marshal(map[string]jsonError{"error": jsonErr})
+	}
+	if err != nil {
+		panic(err)
+	}
+	return string(resp)
+}
+
+// newJSONError describes the error with the first message, like the text output. the underlying error is included
+// separately, and is also used as the message when there's no other message
+func newJSONError(e error, exitCode int, messages ...string) jsonError {
+	jsonErr := jsonError{Code: "error", ExitCode: exitCode}
+	if e != nil {
+		jsonErr.Error = e.Error()
+		jsonErr.Message = e.Error()
+	}
+	if len(messages) > 0 && messages[0] != "" {
+		jsonErr.Message = messages[0]
+	}
+
+	var codedErr interface{ Code() string }
+	if errors.As(e, &codedErr) {
+		jsonErr.Code = codedErr.Code()
+	}
+	var requestErr interface{ RequestID() string }
+	if errors.As(e, &requestErr) {
+		jsonErr.RequestID = requestErr.RequestID()
+	}
+	return jsonErr
+}
+
 func printError(e error) {
-	fmt.Fprintln(os.Stderr, color.Red.Render("Doppler Error:"), e)
+	logStderr(color.Red.Render("Doppler Error:"), e)
+}
+
+// ansiEscapeRegex matches the escape sequences used to color text
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// StripANSI removes color escape sequences from the text
+func StripANSI(s string) string {
+	return ansiEscapeRegex.ReplaceAllString(s, "")
+}
+
+// logStderr prints the values to stderr like fmt.Println, omitting color unless it can be displayed
+func logStderr(a ...interface{}) {
+	message := fmt.Sprintln(a...)
+	// messages may embed colored text, so color is stripped from the full message
+	if PlainErrors || !isatty.IsTerminal(os.Stderr.Fd()) {
+		message = StripANSI(message)
+	}
+	fmt.Fprint(os.Stderr, message)
 }
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStdout returns everything written to stdout by f
+func captureStdout(t *testing.T, f func()) string {
+	return captureFile(t, &os.Stdout, f)
+}
+
+// captureStderr returns everything written to stderr by f
+func captureStderr(t *testing.T, f func()) string {
+	return captureFile(t, &os.Stderr, f)
+}
+
+func captureFile(t *testing.T, file **os.File, f func()) string {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	original := *file
+	*file = w
+	defer func() { *file = original }()
+
+	f()
+	assert.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestPrintRaw(t *testing.T) {
+	for _, value := range []string{"secret", "", "line1\nline2", "trailing\n", "-----BEGIN KEY-----\r\nabc\r\n-----END KEY-----"} {
+		assert.Equal(t, value, captureStdout(t, func() { PrintRaw(value) }))
+		assert.Equal(t, value+"\n", captureStdout(t, func() { Print(value) }))
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	assert.Equal(t, "Doppler Error: failed", StripANSI("\x1b[31mDoppler Error:\x1b[0m failed"))
+	assert.Equal(t, "+ NAME", StripANSI("\x1b[1;32m+ NAME\x1b[0m"))
+	assert.Equal(t, "plain", StripANSI("plain"))
+}
+
+func TestLogStderrStripsColor(t *testing.T) {
+	// stderr is a pipe rather than a terminal, so colors embedded in messages are stripped
+	assert.Equal(t, "Warning: see below\n", captureStderr(t, func() { LogWarning("see \x1b[32mbelow\x1b[0m") }))
+	assert.Equal(t, "done\n", captureStderr(t, func() { Log("\x1b[32mdone\x1b[0m") }))
+}
+
+type requestError struct{}
+
+func (requestError) Error() string     { return "Unauthorized" }
+func (requestError) Code() string      { return "unauthorized" }
+func (requestError) RequestID() string { return "req-123" }
+
+func TestFormatJSONError(t *testing.T) {
+	original := ErrorFormat
+	defer func() { ErrorFormat = original }()
+
+	ErrorFormat = JSONErrorFormat
+	// the message and the underlying error are both included
+	assert.Equal(t, `{"code":"unauthorized","message":"Unable to fetch secrets","error":"Unauthorized","requestId":"req-123","exitCode":1}`, formatJSONError(newJSONError(requestError{}, 1, "Unable to fetch secrets")))
+	// the error is used as the message when there's no message
+	assert.Equal(t, `{"code":"unauthorized","message":"Unauthorized","error":"Unauthorized","requestId":"req-123","exitCode":1}`, formatJSONError(newJSONError(requestError{}, 1, "", "a hint")))
+	assert.Equal(t, `{"code":"error","message":"Invalid config","exitCode":2}`, formatJSONError(newJSONError(nil, 2, "Invalid config")))
+
+	// --json output wraps the error
+	ErrorFormat = TextErrorFormat
+	assert.Equal(t, `{"error":{"code":"error","message":"Invalid config","exitCode":2}}`, formatJSONError(newJSONError(nil, 2, "Invalid config")))
+}
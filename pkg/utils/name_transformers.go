@@ -46,6 +46,8 @@ func MapToEnvFormat(secrets map[string]string, wrapInQuotes bool) []string {
 	var env []string
 	for k, v := range secrets {
 		if wrapInQuotes {
+			// this format is read by existing consumers of mounted env files, so unlike EscapeDotEnvValue, dollar
+			// signs and newlines are written as-is
 			v = strings.ReplaceAll(v, "\\", "\\\\")
 			v = strings.ReplaceAll(v, "\"", "\\\"")
 			env = append(env, fmt.Sprintf("%s=\"%s\"", k, v))
@@ -0,0 +1,43 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import "sync"
+
+// ForEachConcurrently calls f for each index in [0, n), running at most concurrency calls at once, and returns once
+// all calls have completed. With a concurrency of 1 (or less), calls are made in order on the calling goroutine.
+// Callers should store results by index so that output ordering doesn't depend on scheduling.
+func ForEachConcurrently(n int, concurrency int, f func(i int)) {
+	if concurrency <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachConcurrentlySequential(t *testing.T) {
+	var order []int
+	ForEachConcurrently(5, 1, func(i int) {
+		// appending without a lock is only safe because calls are sequential
+		order = append(order, i)
+	})
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+}
+
+func TestForEachConcurrentlyLimit(t *testing.T) {
+	var running, maxRunning int32
+	var mu sync.Mutex
+	results := make([]int, 20)
+
+	ForEachConcurrently(len(results), 3, func(i int) {
+		current := atomic.AddInt32(&running, 1)
+		mu.Lock()
+		if current > maxRunning {
+			maxRunning = current
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		atomic.AddInt32(&running, -1)
+	})
+
+	assert.LessOrEqual(t, maxRunning, int32(3))
+	for i, result := range results {
+		assert.Equal(t, i*i, result)
+	}
+}
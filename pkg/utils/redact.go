@@ -0,0 +1,187 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RedactedValue is written in place of any redacted value
+const RedactedValue = "***"
+
+// RedactFlushDelay is how long a RedactWriter holds back output that may be the start of a value
+const RedactFlushDelay = 100 * time.Millisecond
+
+// RedactWriter replaces any occurrence of the specified values with RedactedValue before writing to the underlying writer.
+// Output that may be the start of a value is held back until it can be disambiguated, so values that span multiple
+// writes are still redacted. Held back output is written once no more data arrives within RedactFlushDelay, so output
+// like a prompt isn't delayed indefinitely. As a result, a value whose writes are further apart than that isn't fully
+// redacted. Flush must be called once all data has been written.
+type RedactWriter struct {
+	out    io.Writer
+	values [][]byte
+	// first bytes of all values, used to quickly skip bytes that can't start a match
+	firstBytes [256]bool
+	pending    []byte
+	mutex      sync.Mutex
+	flushDelay time.Duration
+	timer      *time.Timer
+	// incremented on each write, so a delayed flush is skipped if more data has arrived since it was scheduled
+	generation int
+	// the error from a delayed flush, which is returned by the next call to Write or Flush
+	err error
+}
+
+// NewRedactWriter creates a RedactWriter. Empty values are ignored.
+func NewRedactWriter(out io.Writer, values []string) *RedactWriter {
+	w := &RedactWriter{out: out, flushDelay: RedactFlushDelay}
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		w.values = append(w.values, []byte(value))
+		w.firstBytes[value[0]] = true
+	}
+	// prefer the longest match when one value is a prefix of another
+	sort.SliceStable(w.values, func(i, j int) bool {
+		return len(w.values[i]) > len(w.values[j])
+	})
+	return w
+}
+
+func (w *RedactWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.takeError(); err != nil {
+		return 0, err
+	}
+
+	w.pending = append(w.pending, p...)
+	if err := w.redact(false); err != nil {
+		return 0, err
+	}
+
+	w.generation++
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if len(w.pending) > 0 {
+		generation := w.generation
+		w.timer = time.AfterFunc(w.flushDelay, func() {
+			w.flushAfterDelay(generation)
+		})
+	}
+	return len(p), nil
+}
+
+// Flush writes any held back output
+func (w *RedactWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.generation++
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if err := w.redact(true); err != nil {
+		return err
+	}
+	return w.takeError()
+}
+
+// flushAfterDelay writes any held back output, unless more data has been written since the flush was scheduled
+func (w *RedactWriter) flushAfterDelay(generation int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if generation != w.generation {
+		return
+	}
+	if err := w.redact(true); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// takeError returns and clears the error from a delayed flush
+func (w *RedactWriter) takeError() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// redact writes as much of the pending output as can be safely redacted. When final is false,
+// trailing output that may be the start of a value is kept in the pending buffer.
+func (w *RedactWriter) redact(final bool) error {
+	var out bytes.Buffer
+	i := 0
+	start := 0
+	for i < len(w.pending) {
+		if !w.firstBytes[w.pending[i]] {
+			i++
+			continue
+		}
+
+		remaining := w.pending[i:]
+		if !final && w.isPartialMatch(remaining) {
+			break
+		}
+
+		if match := w.match(remaining); match > 0 {
+			out.Write(w.pending[start:i])
+			out.WriteString(RedactedValue)
+			i += match
+			start = i
+			continue
+		}
+
+		i++
+	}
+	out.Write(w.pending[start:i])
+
+	// copy the unprocessed remainder so the pending buffer doesn't grow unbounded
+	w.pending = append([]byte(nil), w.pending[i:]...)
+
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := w.out.Write(out.Bytes())
+	return err
+}
+
+// isPartialMatch returns whether data is the beginning of a value that's longer than data
+func (w *RedactWriter) isPartialMatch(data []byte) bool {
+	for _, value := range w.values {
+		if len(value) > len(data) && bytes.HasPrefix(value, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the length of the longest value that data begins with, or 0 if there is none
+func (w *RedactWriter) match(data []byte) int {
+	for _, value := range w.values {
+		if bytes.HasPrefix(data, value) {
+			return len(value)
+		}
+	}
+	return 0
+}
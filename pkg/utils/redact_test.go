@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactWriterTestCase struct {
+	name     string
+	values   []string
+	writes   []string
+	expected string
+}
+
+func TestRedactWriter(t *testing.T) {
+	testCases := []redactWriterTestCase{
+		{
+			name:     "No values",
+			values:   []string{},
+			writes:   []string{"hello world"},
+			expected: "hello world",
+		},
+		{
+			name:     "Single write",
+			values:   []string{"s3cr3t"},
+			writes:   []string{"password is s3cr3t!\n"},
+			expected: "password is ***!\n",
+		},
+		{
+			name:     "Multiple occurrences",
+			values:   []string{"abc"},
+			writes:   []string{"abcabc xabcx"},
+			expected: "****** x***x",
+		},
+		{
+			name:     "Value spanning writes",
+			values:   []string{"s3cr3t"},
+			writes:   []string{"password is s3", "cr", "3t!\n"},
+			expected: "password is ***!\n",
+		},
+		{
+			name:     "Partial value at end of output",
+			values:   []string{"s3cr3t"},
+			writes:   []string{"password is s3cr"},
+			expected: "password is s3cr",
+		},
+		{
+			name:     "Partial value followed by other output",
+			values:   []string{"s3cr3t"},
+			writes:   []string{"s3cr", "ets"},
+			expected: "s3crets",
+		},
+		{
+			name:     "Longest value wins",
+			values:   []string{"abc", "abcdef"},
+			writes:   []string{"abc", "def abc"},
+			expected: "*** ***",
+		},
+		{
+			name:     "Overlapping values",
+			values:   []string{"ab", "bcd"},
+			writes:   []string{"abc"},
+			expected: "***c",
+		},
+		{
+			name:     "Empty value ignored",
+			values:   []string{""},
+			writes:   []string{"hello"},
+			expected: "hello",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewRedactWriter(&out, tc.values)
+			for _, data := range tc.writes {
+				n, err := w.Write([]byte(data))
+				assert.NoError(t, err)
+				assert.Equal(t, len(data), n)
+			}
+			assert.NoError(t, w.Flush())
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+// lockedBuffer a buffer that's safe to write to from a delayed flush while the test reads it
+type lockedBuffer struct {
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestRedactWriterFlushDelay(t *testing.T) {
+	var out lockedBuffer
+	w := NewRedactWriter(&out, []string{"s3cr3t"})
+	w.flushDelay = 10 * time.Millisecond
+
+	// output ending in the start of a value (e.g. a prompt) is written once no more data arrives
+	_, err := w.Write([]byte("password is s3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "password is ", out.String())
+	assert.Eventually(t, func() bool { return out.String() == "password is s3" }, time.Second, time.Millisecond)
+
+	// data written before the delay elapses is still redacted
+	w.flushDelay = time.Hour
+	_, err = w.Write([]byte("\ns3cr"))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("3t\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "password is s3\n***\n", out.String())
+}
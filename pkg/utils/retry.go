@@ -31,7 +31,14 @@ func Retry(attempts int, sleep time.Duration, f func() error) error {
 			return s.error
 		}
 
+		retryAfter, hasRetryAfter := err.(RetryAfter)
 		if attempts--; attempts > 0 {
+			if hasRetryAfter {
+				// the server specified the delay, so the backoff isn't increased
+				time.Sleep(retryAfter.delay)
+				return Retry(attempts, sleep, f)
+			}
+
 			// Add some randomness to prevent creating a Thundering Herd
 			jitter := time.Duration(rand.Int63n(int64(sleep))) // #nosec G404
 			sleep = sleep + jitter/2
@@ -39,6 +46,9 @@ func Retry(attempts int, sleep time.Duration, f func() error) error {
 			time.Sleep(sleep)
 			return Retry(attempts, 2*sleep, f)
 		}
+		if hasRetryAfter {
+			return retryAfter.error
+		}
 		return err
 	}
 
@@ -53,3 +63,13 @@ func StopRetryError(err error) StopRetry {
 type StopRetry struct {
 	error
 }
+
+func RetryAfterError(err error, delay time.Duration) RetryAfter {
+	return RetryAfter{err, delay}
+}
+
+// RetryAfter indicates to wait for the specified delay before the next attempt, instead of backing off. wraps an error
+type RetryAfter struct {
+	error
+	delay time.Duration
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// InterruptExitCode the exit code used when the CLI is interrupted (128 + SIGINT)
+const InterruptExitCode = 130
+
+var interruptContext, cancelInterruptContext = context.WithCancel(context.Background())
+var interruptChan chan os.Signal
+var interrupted atomic.Bool
+var exitOnce sync.Once
+
+// held for reading while performing an operation that must not be interrupted, like writing a file
+var criticalSection sync.RWMutex
+
+// the terminal state at startup, restored when interrupted
+var terminalState *term.State
+
+// InterruptContext returns a context that's canceled when the CLI is interrupted
+func InterruptContext() context.Context {
+	return interruptContext
+}
+
+// Interrupted returns whether the CLI has received SIGINT
+func Interrupted() bool {
+	return interrupted.Load()
+}
+
+// HandleInterrupts installs a SIGINT handler that cancels InterruptContext, waits for any in-progress
+// critical sections to complete, restores the terminal, and exits with InterruptExitCode
+func HandleInterrupts() {
+	if state, err := term.GetState(int(os.Stdin.Fd())); err == nil {
+		terminalState = state
+	}
+
+	interruptChan = make(chan os.Signal, 1)
+	signal.Notify(interruptChan, os.Interrupt)
+	go func() {
+		if _, ok := <-interruptChan; ok {
+			ExitInterrupted()
+		}
+	}()
+
+	// by default the runtime kills the process when writing to a closed stdout. handling SIGPIPE instead causes
+	// the write to fail with EPIPE, which is handled by ExitBrokenPipe so that in-flight requests are canceled
+	pipeChan := make(chan os.Signal, 1)
+	signal.Notify(pipeChan, syscall.SIGPIPE)
+	go func() {
+		for range pipeChan {
+		}
+	}()
+}
+
+// StopInterruptHandler stops handling SIGINT, for use when another handler (like a child process) takes over
+func StopInterruptHandler() {
+	if interruptChan != nil {
+		signal.Stop(interruptChan)
+	}
+}
+
+// CriticalSection runs f, delaying any exit due to SIGINT until f has completed
+func CriticalSection(f func() error) error {
+	criticalSection.RLock()
+	defer criticalSection.RUnlock()
+	return f()
+}
+
+// ExitInterrupted cancels any in-flight requests, waits for critical sections to complete, restores the terminal, and exits
+func ExitInterrupted() {
+	interrupted.Store(true)
+	exitGracefully(InterruptExitCode, "Received SIGINT, exiting")
+}
+
+// IsBrokenPipe returns whether the error was caused by writing to a pipe whose reader has exited (e.g. `| head`)
+func IsBrokenPipe(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	for _, pipeErr := range brokenPipeErrors {
+		if errors.Is(err, pipeErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitBrokenPipe cancels any in-flight requests and exits successfully. The reader has stopped consuming output,
+// so there's nothing left to do and no one to report an error to.
+func ExitBrokenPipe() {
+	exitGracefully(0, "Output pipe was closed, exiting")
+}
+
+func exitGracefully(exitCode int, reason string) {
+	exitOnce.Do(func() {
+		cancelInterruptContext()
+
+		criticalSection.Lock()
+		if terminalState != nil {
+			// #nosec G104
+			term.Restore(int(os.Stdin.Fd()), terminalState)
+		}
+		LogDebug(reason)
+		os.Exit(exitCode)
+	})
+	// another goroutine is already exiting
+	select {}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBrokenPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer w.Close()
+
+	// writing after the reader has exited fails with a broken pipe
+	assert.NoError(t, r.Close())
+	_, err = w.Write([]byte("data"))
+	assert.Error(t, err)
+	assert.True(t, IsBrokenPipe(err))
+	assert.True(t, IsBrokenPipe(fmt.Errorf("unable to write output: %w", err)))
+
+	assert.False(t, IsBrokenPipe(nil))
+	assert.False(t, IsBrokenPipe(errors.New("broken pipe")))
+	assert.False(t, IsBrokenPipe(os.ErrClosed))
+}
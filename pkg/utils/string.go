@@ -15,9 +15,19 @@ limitations under the License.
 */
 package utils
 
-import "github.com/google/uuid"
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
 
 func IsValidUUID(s string) bool {
 	_, err := uuid.Parse(s)
 	return err == nil
 }
+
+// NormalizeLineEndings converts Windows line endings (CRLF) to LF, so files authored on Windows don't leave
+// a trailing carriage return on each value
+func NormalizeLineEndings(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
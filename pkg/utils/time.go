@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// embed the time zone database, as it isn't available on all systems (e.g. Windows)
+	_ "time/tzdata"
+)
+
+// TimestampFormat the format of timestamps displayed in tables
+const TimestampFormat = "2006-01-02 15:04:05 MST"
+
+// ParseTimeZone parses a time zone, which may be "local", "UTC", or an IANA time zone name (e.g. America/New_York)
+func ParseTimeZone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q. Specify local, UTC, or a time zone name like America/New_York", name)
+	}
+	return location, nil
+}
+
+// FormatTimestamp formats an RFC 3339 timestamp from the API in the specified time zone. Values that can't be
+// parsed (including empty values) are returned unchanged.
+func FormatTimestamp(timestamp string, location *time.Location) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.In(location).Format(TimestampFormat)
+}
+
+// ParseTimeBound parses a time in RFC 3339 format (e.g. 2024-01-02T15:04:05Z), a date (e.g. 2024-01-02, the start of
+// the day in the specified time zone), or a duration before now (e.g. 24h)
+func ParseTimeBound(value string, now time.Time, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q. Specify an RFC 3339 time (e.g. 2024-01-02T15:04:05Z), a date (e.g. 2024-01-02), or a duration ago (e.g. 24h)", value)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Doppler <support@doppler.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	location, err := ParseTimeZone("local")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Local, location)
+
+	location, err = ParseTimeZone("UTC")
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, location)
+
+	location, err = ParseTimeZone("Asia/Tokyo")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", location.String())
+
+	_, err = ParseTimeZone("Not/AZone")
+	assert.Error(t, err)
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	assert.Equal(t, "2024-01-02 03:04:05 UTC", FormatTimestamp("2024-01-02T03:04:05.678Z", time.UTC))
+	assert.Equal(t, "2024-01-02 12:04:05 JST", FormatTimestamp("2024-01-02T03:04:05.678Z", tokyo))
+	assert.Equal(t, "2024-01-02 12:04:05 JST", FormatTimestamp("2024-01-01T22:04:05-05:00", tokyo))
+
+	// unparseable values are left as-is
+	assert.Equal(t, "", FormatTimestamp("", tokyo))
+	assert.Equal(t, "yesterday", FormatTimestamp("yesterday", tokyo))
+}
+
+func TestParseTimeBound(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	bound, err := ParseTimeBound("2024-01-02T03:04:05Z", now, tokyo)
+	assert.NoError(t, err)
+	assert.True(t, bound.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	bound, err = ParseTimeBound("2024-01-02", now, tokyo)
+	assert.NoError(t, err)
+	assert.True(t, bound.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, tokyo)))
+
+	bound, err = ParseTimeBound("36h", now, tokyo)
+	assert.NoError(t, err)
+	assert.True(t, bound.Equal(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)))
+
+	for _, value := range []string{"", "yesterday", "-1h", "2024-13-01"} {
+		_, err = ParseTimeBound(value, now, tokyo)
+		assert.Error(t, err, value)
+	}
+}
@@ -145,6 +145,9 @@ func RunCommandString(command string, env []string, inFile io.Reader, outFile io
 
 func execCommand(cmd *exec.Cmd, forwardSignals bool) error {
 	// signal handling logic adapted from aws-vault https://github.com/99designs/aws-vault/
+	// the child process is responsible for handling signals
+	StopInterruptHandler()
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan)
 
@@ -177,6 +180,10 @@ func WaitCommand(cmd *exec.Cmd) (int, error) {
 		cmd.Process.Signal(os.Kill) // #nosec G104
 
 		if exitError, ok := err.(*exec.ExitError); ok {
+			// mirror the shell convention for processes terminated by a signal (e.g. 130 for SIGINT)
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				return 128 + int(status.Signal()), exitError
+			}
 			return exitError.ExitCode(), exitError
 		}
 
@@ -265,6 +272,16 @@ func GetIntFlag(cmd *cobra.Command, flag string, bits int) int {
 	return int(number)
 }
 
+// GetIntFlagIfChanged gets the flag's int value, if specified;
+// protects against reading an undefined flag
+func GetIntFlagIfChanged(cmd *cobra.Command, flag string, bits int, def int) int {
+	if !cmd.Flags().Changed(flag) {
+		return def
+	}
+
+	return GetIntFlag(cmd, flag, bits)
+}
+
 // GetDurationFlag gets the flag's duration
 func GetDurationFlag(cmd *cobra.Command, flag string) time.Duration {
 	value, err := time.ParseDuration(cmd.Flag(flag).Value.String())
@@ -318,13 +335,31 @@ func ConfirmationPrompt(message string, defaultValue bool) bool {
 	if err != nil {
 		if err == terminal.InterruptErr {
 			Log("Exiting")
-			os.Exit(1)
+			ExitInterrupted()
 		}
 		HandleError(err)
 	}
 	return confirm
 }
 
+// PasswordPrompt prompt user for a value without echoing it. The prompt is written to stderr so it doesn't mix with the command's output.
+func PasswordPrompt(message string) string {
+	value := ""
+	prompt := &survey.Password{
+		Message: message,
+	}
+
+	err := survey.AskOne(prompt, &value, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	if err != nil {
+		if err == terminal.InterruptErr {
+			Log("Exiting")
+			ExitInterrupted()
+		}
+		HandleError(err)
+	}
+	return value
+}
+
 // SelectPrompt prompt user to select from a list of options
 func SelectPrompt(message string, options []string, defaultOption string) string {
 	prompt := &survey.Select{
@@ -341,7 +376,7 @@ func SelectPrompt(message string, options []string, defaultOption string) string
 	if err != nil {
 		if err == terminal.InterruptErr {
 			Log("Exiting")
-			os.Exit(1)
+			ExitInterrupted()
 		}
 		HandleError(err)
 	}
@@ -434,3 +469,18 @@ func Contains[T comparable](s []T, e T) bool {
 	}
 	return false
 }
+
+// CommonPrefix returns the longest prefix shared by all of the values
+func CommonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
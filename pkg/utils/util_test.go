@@ -18,6 +18,7 @@ package utils
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
 	"testing"
@@ -136,3 +137,45 @@ func TestGetFilePath(t *testing.T) {
 		t.Error(fmt.Sprintf("Got %s, expected %s", path, "/root"))
 	}
 }
+
+func TestWaitCommandExitCode(t *testing.T) {
+	if IsWindows() {
+		t.Skip("requires sh")
+	}
+
+	testCases := map[string]int{
+		"exit 0":        0,
+		"exit 3":        3,
+		"kill -INT $$":  130,
+		"kill -TERM $$": 143,
+	}
+	for command, expected := range testCases {
+		cmd := exec.Command("sh", "-c", command)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		exitCode, _ := WaitCommand(cmd)
+		if exitCode != expected {
+			t.Error(fmt.Sprintf("%s: got exit code %d, expected %d", command, exitCode, expected))
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	testCases := []struct {
+		values   []string
+		expected string
+	}{
+		{values: nil, expected: ""},
+		{values: []string{"configs"}, expected: "configs"},
+		{values: []string{"configs", "configure"}, expected: "config"},
+		{values: []string{"configs", "configure", "console"}, expected: "con"},
+		{values: []string{"secrets", "configs"}, expected: ""},
+		{values: []string{"_a", "_b", "_"}, expected: "_"},
+	}
+	for _, tc := range testCases {
+		if prefix := CommonPrefix(tc.values); prefix != tc.expected {
+			t.Error(fmt.Sprintf("Got %q, expected %q for %v", prefix, tc.expected, tc.values))
+		}
+	}
+}
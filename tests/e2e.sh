@@ -12,6 +12,7 @@ export DOPPLER_CONFIG="e2e"
 # Run tests
 "$DIR/e2e/secrets-download-fallback.sh"
 "$DIR/e2e/secrets-substitute.sh"
+"$DIR/e2e/secrets-upload-stdin.sh"
 "$DIR/e2e/run.sh"
 "$DIR/e2e/run-fallback.sh"
 "$DIR/e2e/run-mount.sh"
@@ -0,0 +1,50 @@
+#!/bin/bash
+
+set -euo pipefail
+
+TEST_NAME="secrets-upload-stdin"
+
+cleanup() {
+  exit_code=$?
+  if [ "$exit_code" -ne 0 ]; then
+    echo "ERROR: '$TEST_NAME' tests failed during execution"
+    afterAll || echo "ERROR: Cleanup failed"
+  fi
+
+  exit "$exit_code"
+}
+trap cleanup EXIT
+trap cleanup INT
+
+beforeAll() {
+  echo "INFO: Executing '$TEST_NAME' tests"
+}
+
+afterAll() {
+  echo "INFO: Completed '$TEST_NAME' tests"
+}
+
+error() {
+  message=$1
+  echo "$message"
+  exit 1
+}
+
+beforeAll
+
+# verify the json download can be piped to upload. the config's own secrets are uploaded, so its values are unchanged
+before="$("$DOPPLER_BINARY" secrets download --no-file --format json)"
+"$DOPPLER_BINARY" secrets download --no-file --format json | "$DOPPLER_BINARY" secrets upload --file - --silent || \
+  error "ERROR: secrets upload failed to read the downloaded secrets from stdin"
+after="$("$DOPPLER_BINARY" secrets download --no-file --format json)"
+[[ "$before" == "$after" ]] || error "ERROR: secrets upload round-trip changed the config's secrets"
+
+# verify the positional argument also accepts stdin
+"$DOPPLER_BINARY" secrets download --no-file --format json | "$DOPPLER_BINARY" secrets upload - --silent || \
+  error "ERROR: secrets upload failed to read '-' from stdin"
+
+# verify upload fails when nothing is piped to stdin
+"$DOPPLER_BINARY" secrets upload --file - --silent </dev/null && \
+  error "ERROR: secrets upload did not fail without input on stdin"
+
+afterAll